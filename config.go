@@ -5,7 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 
 	_ "github.com/lib/pq"
 	"github.com/pkg/errors"
@@ -14,6 +18,8 @@ import (
 type Config struct {
 	Src        string            `json:"src"`
 	GenConfigs []json.RawMessage `json:"generators"`
+	// Schemas are inspected instead of public, and each is generated into its subdirectory
+	Schemas    []string `json:"schemas"`
 	generators []Generator
 	db         *sql.DB
 	root       string
@@ -21,6 +27,8 @@ type Config struct {
 
 type GeneratorConfig struct {
 	Generator string `json:"type"`
+	// entries merged over the other keys; the generator runs once per entry
+	Configs []json.RawMessage `json:"configs"`
 }
 
 func NewConfig(filename string) (*Config, error) {
@@ -45,18 +53,87 @@ func NewConfig(filename string) (*Config, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "config abs path")
 	}
+	ret.root = root
 
 	for _, gc := range ret.GenConfigs {
-		g, err := NewGenerator(db, root, gc)
+		entries, err := expandGeneratorConfig(gc)
 		if err != nil {
 			return nil, errors.Wrap(err, "NewGenerator")
 		}
-		ret.generators = append(ret.generators, g)
+		for _, entry := range entries {
+			g, err := NewGenerator(db, root, entry)
+			if err != nil {
+				return nil, errors.Wrap(err, "NewGenerator")
+			}
+			ret.generators = append(ret.generators, g)
+		}
 	}
 
 	return &ret, nil
 }
 
+// expandGeneratorConfig returns one generator config per entry of "configs". Keys of an
+// entry override the keys shared by the entries. A config without "configs" is returned as is.
+func expandGeneratorConfig(config json.RawMessage) ([]json.RawMessage, error) {
+	var c GeneratorConfig
+	if err := json.Unmarshal(config, &c); err != nil {
+		return nil, fmt.Errorf("generator config error: %s", err)
+	}
+	if c.Configs == nil {
+		return []json.RawMessage{config}, nil
+	}
+	if len(c.Configs) == 0 {
+		return nil, fmt.Errorf("generator config error: %s configs is empty", c.Generator)
+	}
+
+	var base map[string]json.RawMessage
+	if err := json.Unmarshal(config, &base); err != nil {
+		return nil, fmt.Errorf("generator config error: %s", err)
+	}
+	for k := range base {
+		if strings.EqualFold(k, "configs") {
+			delete(base, k)
+		}
+	}
+
+	ret := make([]json.RawMessage, 0, len(c.Configs))
+	for i, entry := range c.Configs {
+		var keys map[string]json.RawMessage
+		if err := json.Unmarshal(entry, &keys); err != nil {
+			return nil, fmt.Errorf("generator config error: %s configs[%d]: %s", c.Generator, i, err)
+		}
+		merged := make(map[string]json.RawMessage, len(base)+len(keys))
+		for k, v := range base {
+			merged[k] = v
+		}
+		for k, v := range keys {
+			// keys are case-insensitive, so drop the shared key spelled differently
+			for bk := range base {
+				if strings.EqualFold(bk, k) {
+					delete(merged, bk)
+				}
+			}
+			merged[k] = v
+		}
+		var t GeneratorConfig
+		if err := json.Unmarshal(entry, &t); err != nil {
+			return nil, fmt.Errorf("generator config error: %s configs[%d]: %s", c.Generator, i, err)
+		}
+		if t.Generator != "" && t.Generator != c.Generator {
+			return nil, fmt.Errorf("generator config error: %s configs[%d] has type %s", c.Generator, i, t.Generator)
+		}
+		if t.Configs != nil {
+			return nil, fmt.Errorf("generator config error: %s configs[%d] is nested", c.Generator, i)
+		}
+		b, err := json.Marshal(merged)
+		if err != nil {
+			return nil, fmt.Errorf("generator config error: %s", err)
+		}
+		ret = append(ret, b)
+	}
+	return ret, nil
+}
+
 func NewGenerator(db *sql.DB, root string, config json.RawMessage) (Generator, error) {
 	var c GeneratorConfig
 	if err := json.Unmarshal(config, &c); err != nil {
@@ -70,10 +147,72 @@ func NewGenerator(db *sql.DB, root string, config json.RawMessage) (Generator, e
 		return NewProtoBuf(db, root, config)
 	case SphinxTypeName:
 		return NewSphinx(db, root, config)
+	case PydanticTypeName:
+		return NewPydantic(db, root, config)
+	case TypeScriptTypeName:
+		return NewTypeScript(db, root, config)
+	case GoStructTypeName:
+		return NewGoStruct(db, root, config)
+	case GraphQLTypeName:
+		return NewGraphQL(db, root, config)
+	case KotlinTypeName:
+		return NewKotlin(db, root, config)
+	case JSONSchemaTypeName:
+		return NewJSONSchema(db, root, config)
 	default:
 		return nil, fmt.Errorf("unknown generator: %s", c.Generator)
 	}
-	return nil, nil
+}
+
+// applyEnvOverrides overwrites fields of the generator config pointed by v with
+// environment variables named PG2ANY_<TYPE>_<JSON KEY>, e.g. PG2ANY_HIBERNATE_OUTPUT.
+// Environment variables win over the config file. List values are comma separated.
+func applyEnvOverrides(typ string, v interface{}) error {
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		key := strings.Split(rt.Field(i).Tag.Get("json"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		name := "PG2ANY_" + SnakeToUpper(typ) + "_" + SnakeToUpper(key)
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		f := rv.Field(i)
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(val)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+			f.SetBool(b)
+		case reflect.Int:
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+			f.SetInt(int64(n))
+		case reflect.Slice:
+			if f.Type().Elem().Kind() != reflect.String {
+				return fmt.Errorf("%s: unsupported config type", name)
+			}
+			var list []string
+			for _, s := range strings.Split(val, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					list = append(list, s)
+				}
+			}
+			f.Set(reflect.ValueOf(list))
+		default:
+			return fmt.Errorf("%s: unsupported config type", name)
+		}
+	}
+	return nil
 }
 
 func (c *Config) connect() (*sql.DB, error) {
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEnvOverrides(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PG2ANY_HIBERNATE_OUTPUT", dir)
+	t.Setenv("PG2ANY_HIBERNATE_PACKAGE_NAME", "com.example.env")
+	t.Setenv("PG2ANY_HIBERNATE_GENERATE_METAMODEL", "true")
+	t.Setenv("PG2ANY_HIBERNATE_IGNORE_TABLES", "foo, bar")
+
+	raw := json.RawMessage(`{
+  "type": "hibernate",
+  "output": "not/exists",
+  "package_name": "com.example.json",
+  "ignore_tables": ["baz"]
+}`)
+	hc, err := loadHibernateConfig("/", raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hc.Output != dir {
+		t.Errorf("expected: %s, actual: %s", dir, hc.Output)
+	}
+	if hc.PackageName != "com.example.env" {
+		t.Errorf("expected: %s, actual: %s", "com.example.env", hc.PackageName)
+	}
+	if !hc.GenerateMetamodel {
+		t.Error("generate_metamodel should be overridden")
+	}
+	if !reflect.DeepEqual(hc.IgnoreTables, []string{"foo", "bar"}) {
+		t.Errorf("unexpected ignore_tables: %v", hc.IgnoreTables)
+	}
+}
+
+func TestEnvOverridesInvalid(t *testing.T) {
+	t.Setenv("PG2ANY_PROTOBUF_FIELD_NUMBER_BASE", "one")
+	var pbc ProtoBufConfig
+	if err := applyEnvOverrides(ProtoBufTypeName, &pbc); err == nil {
+		t.Error("should be error")
+	}
+}
+
+func TestGeneratorConfigs(t *testing.T) {
+	internal := t.TempDir()
+	public := t.TempDir()
+	raw := json.RawMessage(`{
+  "type": "protobuf",
+  "templates": "templates/protobuf",
+  "configs": [
+    {"output": "` + internal + `", "package_name": "example.internal"},
+    {"output": "` + public + `", "package_name": "example.public"}
+  ]
+}`)
+	entries, err := expandGeneratorConfig(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 configs, actual: %d", len(entries))
+	}
+
+	ins := InspectResult{
+		Tables: []Table{
+			Table{
+				Name:    "users",
+				Columns: []Column{Column{Name: "id", DataType: "integer"}},
+			},
+		},
+	}
+	for _, entry := range entries {
+		gen, err := NewGenerator(nil, ".", entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gen.GetType() != ProtoBufTypeName {
+			t.Errorf("unexpected type: %s", gen.GetType())
+		}
+		if err := gen.Build(ins, BuildOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for dir, pkg := range map[string]string{internal: "example.internal", public: "example.public"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, "UsersMessage.proto"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), "package "+pkg+";") {
+			t.Errorf("expected package %s:\n%s", pkg, b)
+		}
+	}
+}
+
+func TestGeneratorConfigsInvalid(t *testing.T) {
+	tests := []string{
+		`{"type": "protobuf", "configs": []}`,
+		`{"type": "protobuf", "configs": [{"type": "hibernate"}]}`,
+		`{"type": "protobuf", "configs": [{"configs": [{}]}]}`,
+		`{"type": "protobuf", "configs": [1]}`,
+	}
+	for _, tt := range tests {
+		if _, err := expandGeneratorConfig(json.RawMessage(tt)); err == nil {
+			t.Errorf("should be error: %s", tt)
+		}
+	}
+}
+
+func TestGeneratorConfigsSingle(t *testing.T) {
+	raw := json.RawMessage(`{"type": "protobuf", "output": "src/proto"}`)
+	entries, err := expandGeneratorConfig(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || string(entries[0]) != string(raw) {
+		t.Errorf("unexpected configs: %s", entries)
+	}
+}
@@ -1,17 +1,288 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
 	"strconv"
 	"strings"
-	"regexp"
+	"sync"
+	"text/template"
+	"unicode"
+
+	"github.com/pkg/errors"
 )
 
 type Generator interface {
 	GetType() string
-	Build(InspectResult) error
+	Build(InspectResult, BuildOptions) error
+}
+
+// BuildOptions are options given from the command line, shared by all generators.
+type BuildOptions struct {
+	// Stamp is written in the header of generated files if not empty
+	Stamp string
+	// Files creates the generated files, the files are written to the disk if nil
+	Files FileWriter
+	// PostProcess transforms the content of each file before it is written, if not nil
+	PostProcess func(file string, content []byte) ([]byte, error)
+	// Schema is the schema being built, whose files are written to its subdirectory of the
+	// outputs, if not empty
+	Schema string
+}
+
+// outputDir returns the output directory of a generator, the subdirectory of the schema if given.
+func (opts BuildOptions) outputDir(root, output string) string {
+	return filepath.Join(filePathJoinRoot(root, output), opts.Schema)
+}
+
+// FileWriter creates the file of path with the content written by write.
+type FileWriter interface {
+	WriteFile(path string, write func(wr io.Writer) error) error
+}
+
+// writeFile writes the file with opts.Files, or to the disk if not given.
+func (opts BuildOptions) writeFile(path string, write func(wr io.Writer) error) error {
+	if opts.PostProcess != nil {
+		render := write
+		write = func(wr io.Writer) error {
+			var buf bytes.Buffer
+			if err := render(&buf); err != nil {
+				return err
+			}
+			b, err := opts.PostProcess(path, buf.Bytes())
+			if err != nil {
+				return errors.Wrap(err, "post process "+path)
+			}
+			_, err = wr.Write(b)
+			return err
+		}
+	}
+	if opts.Files == nil {
+		return writeFile(path, write)
+	}
+	return opts.Files.WriteFile(path, write)
+}
+
+// fileJob is a file of path whose content is rendered by write. wrap annotates its errors.
+type fileJob struct {
+	path  string
+	write func(wr io.Writer) error
+	wrap  string
+}
+
+// writeFiles renders jobs into memory with at most concurrency workers, or GOMAXPROCS if
+// not positive, then writes them in order, so files and logs are the same as writing them
+// one by one. The error of the first failing job is returned, and later files are not written.
+// write must not modify the generator, as jobs share it.
+func (opts BuildOptions) writeFiles(jobs []fileJob, concurrency int) error {
+	if concurrency < 1 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	bufs := make([]bytes.Buffer, len(jobs))
+	errs := make([]error, len(jobs))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(jobs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				errs[i] = jobs[i].write(&bufs[i])
+			}
+		}()
+	}
+	for i := range jobs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	for i, job := range jobs {
+		if errs[i] != nil {
+			return errors.Wrap(errs[i], job.wrap)
+		}
+		buf := bufs[i].Bytes()
+		if err := opts.writeFile(job.path, func(wr io.Writer) error {
+			_, err := wr.Write(buf)
+			return err
+		}); err != nil {
+			return errors.Wrap(err, job.wrap)
+		}
+	}
+	return nil
+}
+
+// keepExisting returns opts whose existing files are not overwritten unless the file name
+// is listed in overwrites. All files are written if overwrites is empty.
+func (opts BuildOptions) keepExisting(overwrites []string) BuildOptions {
+	if len(overwrites) == 0 {
+		return opts
+	}
+	opts.Files = keepExistingFiles{files: opts.Files, overwrites: overwrites}
+	return opts
+}
+
+type keepExistingFiles struct {
+	files      FileWriter
+	overwrites []string
+}
+
+func (files keepExistingFiles) WriteFile(path string, write func(wr io.Writer) error) error {
+	if _, err := os.Stat(path); err == nil && !contains(files.overwrites, filepath.Base(path)) {
+		log.Printf("skip %s: exists and not in overwrites", path)
+		// write may write other files, so it is executed anyway
+		return write(ioutil.Discard)
+	}
+	return BuildOptions{Files: files.files}.writeFile(path, write)
+}
+
+// stableOutput returns opts whose files are written only if their content changes, if enabled.
+func (opts BuildOptions) stableOutput(enabled bool) BuildOptions {
+	if !enabled {
+		return opts
+	}
+	opts.Files = stableFiles{files: opts.Files}
+	return opts
+}
+
+type stableFiles struct {
+	files FileWriter
+}
+
+func (files stableFiles) WriteFile(path string, write func(wr io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	if old, err := ioutil.ReadFile(path); err == nil && bytes.Equal(normalizeGenerated(old), normalizeGenerated(buf.Bytes())) {
+		log.Printf("unchanged %s", path)
+		return nil
+	}
+	return BuildOptions{Files: files.files}.writeFile(path, func(wr io.Writer) error {
+		_, err := wr.Write(buf.Bytes())
+		return err
+	})
+}
+
+var (
+	generatedLinePattern = regexp.MustCompile(`(?im)^.*generated.*$`)
+	timestampPattern     = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`)
+)
+
+// normalizeGenerated replaces timestamps on lines containing "generated", like the time a
+// file is generated at, so that files of the same schema compare equal.
+func normalizeGenerated(b []byte) []byte {
+	return generatedLinePattern.ReplaceAllFunc(b, func(line []byte) []byte {
+		return timestampPattern.ReplaceAll(line, []byte("<timestamp>"))
+	})
+}
+
+// dryRunFiles executes write into a discard writer and logs the path relative to root
+// and the byte count, so template errors surface without touching the output.
+type dryRunFiles struct {
+	root string
+}
+
+func (files dryRunFiles) WriteFile(path string, write func(wr io.Writer) error) error {
+	wr := &countingWriter{wr: ioutil.Discard}
+	if err := write(wr); err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(files.root, path)
+	if err != nil {
+		rel = path
+	}
+	log.Printf("dry-run: %s (%d bytes)", rel, wr.n)
+	return nil
+}
+
+// checkFiles renders files into memory and records the paths, relative to root, whose content
+// differs from the file on the disk or which don't exist, without writing them.
+type checkFiles struct {
+	root    string
+	drifted []string
+}
+
+func (files *checkFiles) WriteFile(path string, write func(wr io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	if old, err := ioutil.ReadFile(path); err == nil && bytes.Equal(normalizeGenerated(old), normalizeGenerated(buf.Bytes())) {
+		return nil
+	}
+	rel, err := filepath.Rel(files.root, path)
+	if err != nil {
+		rel = path
+	}
+	log.Printf("drifted: %s", rel)
+	files.drifted = append(files.drifted, rel)
+	return nil
+}
+
+type countingWriter struct {
+	wr io.Writer
+	n  int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.wr.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// versionStamp returns a header line recording the pg2any version and the schema hash.
+func versionStamp(ins InspectResult) string {
+	return fmt.Sprintf("pg2any %s, schema %s", toolVersion(), ins.Hash()[:12])
+}
+
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" {
+		return "(devel)"
+	}
+	return info.Main.Version
+}
+
+// templateFuncs returns functions available to all templates, so user templates can
+// transform names instead of relying on precomputed fields.
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"snakeToUpperCamel": SnakeToUpperCamel,
+		"snakeToLowerCamel": SnakeToLowerCamel,
+		"snakeToUpper":      SnakeToUpper,
+		"pluralize":         pluralize,
+		"lower":             strings.ToLower,
+		"upper":             strings.ToUpper,
+		"title":             strings.Title,
+	}
+}
+
+// pluralize returns the plural of the English noun s by the regular rules, like users of user,
+// categories of category and boxes of box.
+func pluralize(s string) string {
+	lower := strings.ToLower(s)
+	switch {
+	case s == "":
+		return s
+	case strings.HasSuffix(lower, "s") || strings.HasSuffix(lower, "x") || strings.HasSuffix(lower, "z") ||
+		strings.HasSuffix(lower, "ch") || strings.HasSuffix(lower, "sh"):
+		return s + "es"
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return s[:len(s)-1] + "ies"
+	}
+	return s + "s"
 }
 
 func DirExists(dir string) error {
@@ -55,6 +326,102 @@ func SnakeToLowerCamel(src string) string {
 	return strings.Join(ret, "")
 }
 
+// camelToSnake converts lowerCamel or UpperCamel names like userName to user_name.
+// Snake case names are returned as is.
+func camelToSnake(src string) string {
+	var ret []rune
+	for i, r := range src {
+		if unicode.IsUpper(r) {
+			if i > 0 && src[i-1] != '_' {
+				ret = append(ret, '_')
+			}
+			r = unicode.ToLower(r)
+		}
+		ret = append(ret, r)
+	}
+	return string(ret)
+}
+
+// renamedColumn returns the name col of table is generated as, renamed by renames keyed by "table.column".
+// Renames may be in snake case or camel case like userName.
+func renamedColumn(renames map[string]string, table string, col Column) string {
+	if name, ok := renames[table+"."+col.Name]; ok {
+		return camelToSnake(name)
+	}
+	return col.Name
+}
+
+// checkColumnRenames returns an error if a renamed column of table has the same name as another column,
+// ignoring case, which would be a duplicate field or accessor.
+func checkColumnRenames(renames map[string]string, table Table) error {
+	if len(renames) == 0 {
+		return nil
+	}
+	type field struct {
+		column  string
+		renamed bool
+	}
+	fields := make(map[string]field)
+	for _, col := range table.Columns {
+		name := renamedColumn(renames, table.Name, col)
+		key := strings.ToLower(SnakeToLowerCamel(name))
+		f := field{column: col.Name, renamed: name != col.Name}
+		// columns not renamed keep their current behavior
+		if other, ok := fields[key]; ok && (f.renamed || other.renamed) {
+			return fmt.Errorf("column_renames: %s.%s and %s.%s are both generated as %s",
+				table.Name, other.column, table.Name, col.Name, SnakeToLowerCamel(name))
+		}
+		fields[key] = f
+	}
+	return nil
+}
+
+// arrayDims returns number of array dimensions of col, 0 for scalar.
+// attndims is not always recorded (e.g. CREATE TABLE AS), so arrays are at least 1.
+func arrayDims(col Column) int {
+	if !col.Array {
+		return 0
+	}
+	if col.ArrayDims < 1 {
+		return 1
+	}
+	return col.ArrayDims
+}
+
+var storageNames = map[string]string{
+	"p": "PLAIN",
+	"e": "EXTERNAL",
+	"m": "MAIN",
+	"x": "EXTENDED",
+}
+
+var compressionNames = map[string]string{
+	"p": "pglz",
+	"l": "lz4",
+}
+
+// storageNote describes TOAST storage and compression of col. PLAIN columns return empty.
+func storageNote(col Column) string {
+	name, ok := storageNames[col.Storage]
+	if !ok || col.Storage == "p" {
+		return ""
+	}
+	ret := "storage: " + name
+	if col.Compression.Valid {
+		c, ok := compressionNames[col.Compression.String]
+		if !ok {
+			c = col.Compression.String
+		}
+		ret += ", compression: " + c
+	}
+	return ret
+}
+
+// customStorage reports whether storage or compression of col differ from the defaults.
+func customStorage(col Column) bool {
+	return (col.TypeStorage != "" && col.Storage != col.TypeStorage) || col.Compression.Valid
+}
+
 func isNumber(v string) bool {
 	if _, err := strconv.Atoi(v); err == nil {
 		return true
@@ -74,6 +441,98 @@ func contains(s []string, e string) bool {
 	return false
 }
 
+// containsColumn reports whether s lists the column either as "column" or "table.column".
+func containsColumn(s []string, table, column string) bool {
+	return contains(s, column) || contains(s, table+"."+column)
+}
+
+// overrideType returns the type configured in overrides for the data type of col without [].
+func overrideType(overrides map[string]string, col Column) (string, bool) {
+	t, ok := overrides[strings.TrimSuffix(col.DataType, "[]")]
+	return t, ok
+}
+
+// UnionDef is a tagged union held by a json/jsonb column. The Discriminator field of the
+// object names the variant, whose type is Variants[value].
+type UnionDef struct {
+	Discriminator string            `json:"discriminator"`
+	Variants      map[string]string `json:"variants"`
+	// Numbers are protobuf field numbers of the variants keyed by discriminator value, which
+	// keep their numbers as variants are added
+	Numbers map[string]int `json:"numbers"`
+}
+
+// VariantValues returns the discriminator values in order, so generated code is stable.
+func (def UnionDef) VariantValues() []string {
+	ret := make([]string, 0, len(def.Variants))
+	for v := range def.Variants {
+		ret = append(ret, v)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// checkJsonUnions validates unions keyed by "table.column".
+func checkJsonUnions(unions map[string]UnionDef) error {
+	for key, def := range unions {
+		if !strings.Contains(key, ".") {
+			return fmt.Errorf("json_unions %s: key must be table.column", key)
+		}
+		if def.Discriminator == "" {
+			return fmt.Errorf("json_unions %s: discriminator is empty", key)
+		}
+		if len(def.Variants) == 0 {
+			return fmt.Errorf("json_unions %s: variants are empty", key)
+		}
+		names := make(map[string]string)
+		for _, v := range def.VariantValues() {
+			if def.Variants[v] == "" {
+				return fmt.Errorf("json_unions %s: type of variant %s is empty", key, v)
+			}
+			// variants are named like page_view or PageView by generators
+			for _, name := range []string{protoBufVariantName(v), SnakeToUpperCamel(protoBufVariantName(v))} {
+				if other, ok := names[name]; ok && other != v {
+					return fmt.Errorf("json_unions %s: variants %s and %s have the same name %s", key, other, v, name)
+				}
+				names[name] = v
+			}
+		}
+		if len(def.Numbers) == 0 {
+			continue
+		}
+		numbers := make(map[int]string)
+		for _, v := range def.VariantValues() {
+			n, ok := def.Numbers[v]
+			if !ok {
+				return fmt.Errorf("json_unions %s: number of variant %s is missing", key, v)
+			}
+			if n < 1 || n > protoBufMaxFieldNumber || skipReservedFieldNumber(n) != n {
+				return fmt.Errorf("json_unions %s: number of variant %s is out of range: %d", key, v, n)
+			}
+			if other, ok := numbers[n]; ok {
+				return fmt.Errorf("json_unions %s: variants %s and %s have the same number %d", key, other, v, n)
+			}
+			numbers[n] = v
+		}
+		for v := range def.Numbers {
+			if _, ok := def.Variants[v]; !ok {
+				return fmt.Errorf("json_unions %s: number of unknown variant %s", key, v)
+			}
+		}
+	}
+	return nil
+}
+
+// piiCommentMarker marks a column as holding sensitive data from its comment.
+const piiCommentMarker = "@pii"
+
+func isPii(piiColumns []string, table string, col Column) bool {
+	if containsColumn(piiColumns, table, col.Name) {
+		return true
+	}
+	return strings.Contains(col.Comment.String, piiCommentMarker)
+}
+
 func partContainsRegex(s []string, target string) bool {
 	for _, a := range s {
 		r := regexp.MustCompile(a)
@@ -84,6 +543,316 @@ func partContainsRegex(s []string, target string) bool {
 	return false
 }
 
+// skipTable reports whether table is left out of generation. If include is given, tables not
+// matching its glob patterns are skipped, then tables matching ignore (see matchIgnore).
+func skipTable(include, ignore []string, table string) bool {
+	if len(include) > 0 && !matchGlob(include, table) {
+		return true
+	}
+	return matchIgnore(ignore, table)
+}
+
+// matchIgnore reports whether target matches one of the patterns. Globs (see ignoreGlob) match
+// the whole name, others are regular expressions matching a part of the name as before, like
+// ^flyway_ or plain names. Patterns are checked by checkIgnores when the config is loaded.
+func matchIgnore(patterns []string, target string) bool {
+	for _, p := range patterns {
+		if glob, ok := ignoreGlob(p); ok {
+			if ok, _ := path.Match(glob, target); ok {
+				return true
+			}
+			continue
+		}
+		if regexp.MustCompile(p).MatchString(target) {
+			return true
+		}
+	}
+	return false
+}
+
+var regWildcardName = regexp.MustCompile(`^[0-9A-Za-z_]*[*?][0-9A-Za-z_*?]*$`)
+
+// ignoreGlob returns the glob of an ignore pattern. Patterns prefixed with glob:, names with
+// only * and ? wildcards like audit_* or *_tmp, and patterns which are not regular expressions
+// but valid globs are globs.
+func ignoreGlob(p string) (string, bool) {
+	if strings.HasPrefix(p, globPrefix) {
+		return strings.TrimPrefix(p, globPrefix), true
+	}
+	if regWildcardName.MatchString(p) {
+		return p, true
+	}
+	if _, err := regexp.Compile(p); err == nil {
+		return "", false
+	}
+	if _, err := path.Match(p, ""); err == nil {
+		return p, true
+	}
+	return "", false
+}
+
+func checkIgnores(patterns []string) error {
+	for _, p := range patterns {
+		if glob, ok := ignoreGlob(p); ok {
+			if _, err := path.Match(glob, ""); err != nil {
+				return fmt.Errorf("%s: %s", p, err)
+			}
+			continue
+		}
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("%s: %s", p, err)
+		}
+	}
+	return nil
+}
+
+// globPrefix marks glob patterns in lists of regular expressions like ignore_tables.
+const globPrefix = "glob:"
+
+// matchGlob reports whether target matches one of the glob patterns like order_*.
+func matchGlob(patterns []string, target string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func checkGlobs(patterns []string) error {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("%s: %s", p, err)
+		}
+	}
+	return nil
+}
+
+var regCharacterLength = regexp.MustCompile(`^(character varying|varchar|character|char)\((\d+)\)`)
+
+// characterLength returns the length of character types like "character varying(255)".
+func characterLength(dataType string) (int, bool) {
+	m := regCharacterLength.FindStringSubmatch(dataType)
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(m[2])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+var regNumeric = regexp.MustCompile(`^numeric\((\d+)(?:,\s*(\d+))?\)`)
+
+// numericPrecisionScale returns precision and scale of types like "numeric(10,2)".
+// ok is false if the precision is not specified.
+func numericPrecisionScale(dataType string) (precision, scale int, ok bool) {
+	m := regNumeric.FindStringSubmatch(dataType)
+	if m == nil {
+		return 0, 0, false
+	}
+	precision, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		scale, _ = strconv.Atoi(m[2])
+	}
+	return precision, scale, true
+}
+
+// maxDomainDepth limits domains over domains, which can't be cyclic in PostgreSQL.
+const maxDomainDepth = 16
+
+// resolveDomain returns col with the data type of its domain replaced by the base type.
+// Arrays of a domain become arrays of the base type.
+func resolveDomain(ins InspectResult, col Column) Column {
+	array := strings.HasSuffix(col.DataType, "[]")
+	for i := 0; i < maxDomainDepth; i++ {
+		typ, err := ins.FindDomain(strings.TrimSuffix(col.DataType, "[]"))
+		if err != nil {
+			break
+		}
+		col.DataType = typ.BaseType
+		if array {
+			col.DataType += "[]"
+		}
+		if p, s, ok := numericPrecisionScale(typ.BaseType); ok {
+			col.NumericPrecision, col.NumericScale = p, s
+		}
+	}
+	return col
+}
+
+// domainComment returns a comment of the domain of col, with its CHECK constraints.
+func domainComment(ins InspectResult, col Column) (string, bool) {
+	typ, err := ins.FindDomain(strings.TrimSuffix(col.DataType, "[]"))
+	if err != nil {
+		return "", false
+	}
+	if !typ.Check.Valid {
+		return "domain " + typ.Name, true
+	}
+	return "domain " + typ.Name + ": " + typ.Check.String, true
+}
+
+// isInterval reports whether col is an interval, which may have fields and precision like "interval day to second(3)".
+func isInterval(col Column) bool {
+	return strings.HasPrefix(col.DataType, "interval")
+}
+
+// intervalDurationNote notes that a duration is fixed length, while months and days of intervals are not.
+const intervalDurationNote = "interval as duration: months and days have fixed lengths"
+
+// numericTypmod returns precision and scale of a numeric column.
+// Columns of snapshots without them are parsed from the data type.
+func numericTypmod(col Column) (precision, scale int, ok bool) {
+	if col.NumericPrecision > 0 {
+		return col.NumericPrecision, col.NumericScale, true
+	}
+	return numericPrecisionScale(strings.TrimSuffix(col.DataType, "[]"))
+}
+
+var (
+	regLiteralDefault = regexp.MustCompile(`^'((?:[^']|'')*)'::[\w ."]+$`)
+	regNumberDefault  = regexp.MustCompile(`^\(?(-?[0-9]+(\.[0-9]+)?)\)?$`)
+)
+
+// literalDefault returns the value of the default of col if it is a literal like
+// 'active'::user_status, 0 or true. Defaults computed by functions like now() or
+// nextval() and expressions of generated columns have no literal.
+func literalDefault(col Column) (string, bool) {
+	if !col.DefaultValue.Valid || col.Generated {
+		return "", false
+	}
+	src := col.DefaultValue.String
+	if m := regLiteralDefault.FindStringSubmatch(src); m != nil {
+		return strings.Replace(m[1], "''", "'", -1), true
+	}
+	if m := regNumberDefault.FindStringSubmatch(src); m != nil {
+		return m[1], true
+	}
+	if src == "true" || src == "false" {
+		return src, true
+	}
+	return "", false
+}
+
+// columnDefault reports whether col has a default worth documenting. Sequences are
+// identities rather than values, and expressions of generated columns aren't defaults.
+func columnDefault(col Column) bool {
+	if !col.DefaultValue.Valid || col.Generated || col.Serial {
+		return false
+	}
+	return !strings.HasPrefix(col.DefaultValue.String, "nextval(")
+}
+
+// maxInt64Digits is the number of decimal digits which always fit in int64.
+const maxInt64Digits = 18
+
+type ForeignKey struct {
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnDelete   string // referential action like CASCADE, SET NULL, RESTRICT, NO ACTION
+	OnUpdate   string
+}
+
+const defaultForeignKeyAction = "NO ACTION"
+
+var (
+	regForeignKeyOnDelete = regexp.MustCompile(`ON DELETE (CASCADE|SET NULL|SET DEFAULT|RESTRICT|NO ACTION)`)
+	regForeignKeyOnUpdate = regexp.MustCompile(`ON UPDATE (CASCADE|SET NULL|SET DEFAULT|RESTRICT|NO ACTION)`)
+)
+
+var regForeignKey = regexp.MustCompile(`^FOREIGN KEY \(([^)]+)\) REFERENCES ([^(]+)\(([^)]+)\)`)
+
+// parseForeignKey parses a constraint definition like
+// FOREIGN KEY (security_code) REFERENCES master_security(security_code)
+func parseForeignKey(src string) (ForeignKey, error) {
+	m := regForeignKey.FindStringSubmatch(src)
+	if m == nil {
+		return ForeignKey{}, fmt.Errorf("not a foreign key: %s", src)
+	}
+	fk := ForeignKey{
+		Columns:    splitIdentifiers(m[1]),
+		RefTable:   unquoteIdentifier(strings.TrimSpace(m[2])),
+		RefColumns: splitIdentifiers(m[3]),
+		OnDelete:   defaultForeignKeyAction,
+		OnUpdate:   defaultForeignKeyAction,
+	}
+	if a := regForeignKeyOnDelete.FindStringSubmatch(src); a != nil {
+		fk.OnDelete = a[1]
+	}
+	if a := regForeignKeyOnUpdate.FindStringSubmatch(src); a != nil {
+		fk.OnUpdate = a[1]
+	}
+	if len(fk.Columns) != len(fk.RefColumns) {
+		return ForeignKey{}, fmt.Errorf("column count mismatch: %s", src)
+	}
+	return fk, nil
+}
+
+// Reference returns referenced column of column.
+func (fk ForeignKey) Reference(column string) (string, bool) {
+	for i, c := range fk.Columns {
+		if c == column {
+			return fk.RefColumns[i], true
+		}
+	}
+	return "", false
+}
+
+func splitIdentifiers(src string) []string {
+	var ret []string
+	for _, s := range strings.Split(src, ",") {
+		ret = append(ret, unquoteIdentifier(strings.TrimSpace(s)))
+	}
+	return ret
+}
+
+// quoteIdentifier quotes s as a SQL identifier, so reserved words like user can be names.
+func quoteIdentifier(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
+
+func unquoteIdentifier(s string) string {
+	if len(s) > 1 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		return strings.Replace(s[1:len(s)-1], `""`, `"`, -1)
+	}
+	return s
+}
+
+// loadCommentsFile reads a JSON object of descriptions keyed by "table" or "table.column".
+func loadCommentsFile(file string) (map[string]string, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var ret map[string]string
+	if err := json.Unmarshal(buf, &ret); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return ret, nil
+}
+
+// writeFile creates the file of path and writes it by write. The file is closed before
+// returning, even on errors, and an error of Close is returned because writes may fail there.
+func writeFile(path string, write func(wr io.Writer) error) (err error) {
+	// subdirectories of schemas are created on demand
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "create directory")
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "create file")
+	}
+	defer func() {
+		if cerr := file.Close(); cerr != nil && err == nil {
+			err = errors.Wrap(cerr, "close file")
+		}
+	}()
+	return write(file)
+}
+
 func filePathJoinRoot(root, file string) string {
 	if filepath.IsAbs(file) {
 		return file
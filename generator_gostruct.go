@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type GoStructConfig struct {
+	Output       string   `json:"output"`
+	Templates    string   `json:"templates"`
+	PackageName  string   `json:"package_name"`
+	IgnoreTables []string `json:"ignore_tables"`
+	// OptimizeLayout orders struct fields by alignment, largest first, to minimize padding
+	OptimizeLayout bool `json:"optimize_layout"`
+	// Format runs gofmt on generated files, true if not given
+	Format *bool `json:"format"`
+	// UsePointers makes nullable columns pointers instead of sql.Null* types
+	UsePointers bool `json:"use_pointers"`
+	// SkipEmptyTables skips tables without columns, which may exist while migrating
+	SkipEmptyTables bool `json:"skip_empty_tables"`
+	// NullableStyle is the type of nullable columns: "pointer", "sql_null" or "value".
+	// Default is "pointer" with UsePointers, otherwise "sql_null".
+	NullableStyle string `json:"nullable_style"`
+	// StableOutput leaves files whose content is unchanged, ignoring generated timestamps
+	StableOutput bool `json:"stable_output"`
+	// IncludeTables restricts generation to tables matching the glob patterns like order_*, before IgnoreTables
+	IncludeTables []string `json:"include_tables"`
+}
+
+type GoStruct struct {
+	db       *sql.DB
+	config   GoStructConfig
+	ins      InspectResult
+	template *template.Template
+	root     string
+	opts     BuildOptions
+}
+
+type GoStructMember struct {
+	Name    string
+	Type    string
+	Tag     string
+	Comment string
+}
+
+type GoStructTypeMember struct {
+	Name    string
+	Comment string
+	Values  []GoStructEnumValue
+}
+
+type GoStructEnumValue struct {
+	Name  string
+	Value string
+}
+
+// GoStructTableNames are constants of a table and its columns.
+type GoStructTableNames struct {
+	Name    string
+	Value   string
+	Columns []GoStructEnumValue
+}
+
+const GoStructTypeName = "gostruct"
+
+const goStructTablesFileName = "tables.go"
+
+// Styles of nullable columns
+const (
+	NullableStylePointer = "pointer"  // *string
+	NullableStyleSQLNull = "sql_null" // sql.NullString
+	NullableStyleValue   = "value"    // string, commented as nullable
+)
+
+// goStructNullTypes are database/sql types of nullable columns.
+var goStructNullTypes = map[string]string{
+	"string":    "sql.NullString",
+	"int32":     "sql.NullInt32",
+	"int64":     "sql.NullInt64",
+	"float32":   "sql.NullFloat64",
+	"float64":   "sql.NullFloat64",
+	"bool":      "sql.NullBool",
+	"time.Time": "sql.NullTime",
+}
+
+// goStructAlignments are alignments of types smaller than a word on 64-bit platforms.
+// Other types, like strings, slices, pointers and time.Time, are aligned to 8 bytes.
+var goStructAlignments = map[string]int{
+	"bool":          1,
+	"sql.NullBool":  1,
+	"int32":         4,
+	"float32":       4,
+	"sql.NullInt32": 4,
+}
+
+// goStructImports are packages providing the qualified types.
+var goStructImports = map[string]string{
+	"sql.":  "database/sql",
+	"json.": "encoding/json",
+	"time.": "time",
+}
+
+func NewGoStruct(db *sql.DB, root string, raw json.RawMessage) (Generator, error) {
+	config, err := loadGoStructConfig(root, raw)
+	if err != nil {
+		return nil, err
+	}
+	ret := GoStruct{
+		db:     db,
+		config: config,
+		root:   root,
+	}
+
+	return &ret, nil
+}
+
+func (gen *GoStruct) GetType() string {
+	return GoStructTypeName
+}
+
+func (gen *GoStruct) Build(ins InspectResult, opts BuildOptions) error {
+	log.Printf("output: %s", opts.outputDir(gen.root, gen.config.Output))
+	log.Printf("templates: %s", filePathJoinRoot(gen.root, gen.config.Templates))
+	gen.ins = ins
+	gen.opts = opts
+	opts = opts.stableOutput(gen.config.StableOutput)
+
+	// Load templates
+	tdir := filepath.Join(filePathJoinRoot(gen.root, gen.config.Templates), "*.tmpl")
+	t := template.Must(template.New("").Funcs(templateFuncs()).ParseGlob(tdir))
+	gen.template = t
+
+	// Build tables and row types of functions
+	for _, table := range gen.ins.TablesAndFunctions() {
+		if skipTable(gen.config.IncludeTables, gen.config.IgnoreTables, table.Name) {
+			continue
+		}
+		if len(table.Columns) == 0 && gen.config.SkipEmptyTables {
+			log.Printf("skip %s: no columns", table.Name)
+			continue
+		}
+		fileName := table.Name + ".go"
+		if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), fileName), func(wr io.Writer) error {
+			return gen.buildTable(wr, table)
+		}); err != nil {
+			return errors.Wrap(err, "build write table")
+		}
+	}
+
+	// Build names of tables and columns
+	if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), goStructTablesFileName), func(wr io.Writer) error {
+		return gen.buildTableNames(wr)
+	}); err != nil {
+		return errors.Wrap(err, "build write table names")
+	}
+
+	// Build types
+	if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), "enums.go"), func(wr io.Writer) error {
+		return gen.buildType(wr, gen.ins.Types)
+	}); err != nil {
+		return errors.Wrap(err, "build write type")
+	}
+
+	return nil
+}
+
+func (gen *GoStruct) buildTable(wr io.Writer, table Table) error {
+	members := gen.members(table)
+	return gen.execute(wr, "struct", map[string]interface{}{
+		"stamp":        gen.opts.Stamp,
+		"package_name": gen.config.PackageName,
+		"now":          time.Now().UTC().Format(time.RFC3339),
+		"comment":      strings.Replace(table.Comment.String, "\n", " ", -1),
+		"name":         SnakeToUpperCamel(table.Name),
+		"imports":      goStructImportsOf(members),
+		"member":       members,
+	})
+}
+
+// execute writes the template formatted by gofmt unless format is false. Output gofmt can't
+// parse is an error quoting it, as it wouldn't compile either.
+func (gen *GoStruct) execute(wr io.Writer, name string, data map[string]interface{}) error {
+	var buf bytes.Buffer
+	if err := gen.template.ExecuteTemplate(&buf, name, data); err != nil {
+		return err
+	}
+	if gen.config.Format != nil && !*gen.config.Format {
+		_, err := wr.Write(buf.Bytes())
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return errors.Wrapf(err, "gofmt %s:\n%s", name, buf.String())
+	}
+	_, err = wr.Write(src)
+	return err
+}
+
+func (gen *GoStruct) members(table Table) []GoStructMember {
+	var ret []GoStructMember
+
+	for i, col := range table.Columns {
+		m := GoStructMember{
+			Name:    SnakeToUpperCamel(col.Name),
+			Type:    gen.convertType(col),
+			Tag:     fmt.Sprintf("`db:%s json:%s`", strconv.Quote(col.Name), strconv.Quote(SnakeToLowerCamel(col.Name))),
+			Comment: strings.Replace(col.Comment.String, "\n", " ", -1),
+		}
+		if !col.NotNull {
+			m.Type = gen.nullable(m.Type)
+			if gen.nullableStyle() == NullableStyleValue {
+				m.Comment = strings.TrimSpace(m.Comment + " (nullable)")
+			}
+		}
+		if gen.config.OptimizeLayout {
+			// fields are reordered, so keep the position of the column
+			if m.Comment != "" {
+				m.Comment = fmt.Sprintf("column %d: %s", i+1, m.Comment)
+			} else {
+				m.Comment = fmt.Sprintf("column %d", i+1)
+			}
+		}
+		ret = append(ret, m)
+	}
+	if gen.config.OptimizeLayout {
+		sort.SliceStable(ret, func(a, b int) bool {
+			return goStructAlignment(ret[a].Type) > goStructAlignment(ret[b].Type)
+		})
+	}
+	return ret
+}
+
+func goStructAlignment(typ string) int {
+	if align, ok := goStructAlignments[typ]; ok {
+		return align
+	}
+	return 8
+}
+
+// nullable returns the type of a nullable column of typ.
+// Slices, json.RawMessage and interface{} can already be nil.
+func (gen *GoStruct) nullable(typ string) string {
+	if strings.HasPrefix(typ, "[]") || typ == "json.RawMessage" || typ == "interface{}" {
+		return typ
+	}
+	switch gen.nullableStyle() {
+	case NullableStyleValue:
+		// NULL is read as the zero value
+		return typ
+	case NullableStyleSQLNull:
+		if t, ok := goStructNullTypes[typ]; ok {
+			return t
+		}
+	}
+	return "*" + typ
+}
+
+func (gen *GoStruct) nullableStyle() string {
+	if gen.config.NullableStyle != "" {
+		return gen.config.NullableStyle
+	}
+	if gen.config.UsePointers {
+		return NullableStylePointer
+	}
+	return NullableStyleSQLNull
+}
+
+// goStructImportsOf returns packages used by types of members.
+func goStructImportsOf(members []GoStructMember) []string {
+	var ret []string
+	for _, m := range members {
+		for prefix, pkg := range goStructImports {
+			if strings.Contains(m.Type, prefix) && !contains(ret, pkg) {
+				ret = append(ret, pkg)
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// buildTableNames writes constants of table and column names for query builders,
+// like TableUsers = "users" and UsersColumnEmail = "email".
+func (gen *GoStruct) buildTableNames(wr io.Writer) error {
+	var tables []GoStructTableNames
+	for _, table := range gen.ins.Tables {
+		if skipTable(gen.config.IncludeTables, gen.config.IgnoreTables, table.Name) {
+			continue
+		}
+		if len(table.Columns) == 0 && gen.config.SkipEmptyTables {
+			continue
+		}
+		name := SnakeToUpperCamel(table.Name)
+		t := GoStructTableNames{Name: "Table" + name, Value: strconv.Quote(table.Name)}
+		for _, col := range table.Columns {
+			t.Columns = append(t.Columns, GoStructEnumValue{
+				Name:  name + "Column" + SnakeToUpperCamel(col.Name),
+				Value: strconv.Quote(col.Name),
+			})
+		}
+		tables = append(tables, t)
+	}
+
+	return gen.execute(wr, "tables", map[string]interface{}{
+		"stamp":        gen.opts.Stamp,
+		"package_name": gen.config.PackageName,
+		"now":          time.Now().UTC().Format(time.RFC3339),
+		"tables":       tables,
+	})
+}
+
+func (gen *GoStruct) buildType(wr io.Writer, types []Type) error {
+	var members []GoStructTypeMember
+	for _, typ := range types {
+		name := SnakeToUpperCamel(typ.Name)
+		m := GoStructTypeMember{
+			Name:    name,
+			Comment: strings.Replace(typ.Comment.String, "\n", " ", -1),
+		}
+		for _, val := range typ.Values {
+			v := SnakeToUpperCamel(val)
+			if isNumber(val) {
+				v = "Value" + v
+			}
+			m.Values = append(m.Values, GoStructEnumValue{Name: name + v, Value: strconv.Quote(val)})
+		}
+		members = append(members, m)
+	}
+
+	return gen.execute(wr, "enum", map[string]interface{}{
+		"stamp":        gen.opts.Stamp,
+		"package_name": gen.config.PackageName,
+		"now":          time.Now().UTC().Format(time.RFC3339),
+		"members":      members,
+	})
+}
+
+func (gen *GoStruct) convertType(col Column) string {
+	col = resolveDomain(gen.ins, col)
+	if strings.HasSuffix(col.DataType, "[]") {
+		col.DataType = strings.Replace(col.DataType, "[]", "", 1)
+		return "[]" + gen.convertType(col)
+	}
+
+	switch col.DataType {
+	case "text", "uuid":
+		return "string"
+	case "smallint", "int2", "int", "integer", "smallserial", "serial":
+		return "int32"
+	case "bigint", "bigserial":
+		return "int64"
+	case "float":
+		return "float32"
+	case "double", "double precision":
+		return "float64"
+	case "numeric":
+		// keep precision
+		return "string"
+	case "boolean":
+		return "bool"
+	case "date", "timestamp":
+		return "time.Time"
+	case "json", "jsonb":
+		return "json.RawMessage"
+	case "bytea":
+		return "[]byte"
+	case "int2vector", "oidvector":
+		// space separated numbers like "1 2"
+		return "string"
+	default:
+		// "timestamp with time zone", "timestamp without time zone", "timestamp(n) with time zone"
+		if strings.HasSuffix(col.DataType, "time zone") {
+			return "time.Time"
+		}
+		if strings.HasPrefix(col.DataType, "numeric") {
+			return "string"
+		}
+		if strings.HasPrefix(col.DataType, "character") {
+			return "string"
+		}
+
+		typ, err := gen.ins.FindType(col.DataType)
+		if err == nil {
+			return SnakeToUpperCamel(typ.Name)
+		}
+	}
+	return "interface{}"
+}
+
+func loadGoStructConfig(root string, raw json.RawMessage) (GoStructConfig, error) {
+	var gc GoStructConfig
+	if err := json.Unmarshal(raw, &gc); err != nil {
+		return gc, fmt.Errorf("gostruct config error: %s", err)
+	}
+	if err := applyEnvOverrides(GoStructTypeName, &gc); err != nil {
+		return gc, fmt.Errorf("gostruct config error: %s", err)
+	}
+	output := filePathJoinRoot(root, gc.Output)
+	if err := DirExists(output); err != nil {
+		return gc, fmt.Errorf("gostruct output is not exists: %s", gc.Output)
+	}
+	if err := checkGlobs(gc.IncludeTables); err != nil {
+		return gc, fmt.Errorf("gostruct include_tables: %s", err)
+	}
+	if err := checkIgnores(gc.IgnoreTables); err != nil {
+		return gc, fmt.Errorf("gostruct ignore_tables: %s", err)
+	}
+	if gc.PackageName == "" {
+		return gc, fmt.Errorf("gostruct package_name is required")
+	}
+	switch gc.NullableStyle {
+	case "", NullableStylePointer, NullableStyleSQLNull, NullableStyleValue:
+	default:
+		return gc, fmt.Errorf("gostruct nullable_style must be pointer, sql_null or value: %s", gc.NullableStyle)
+	}
+	return gc, nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestGoStructConvertType(t *testing.T) {
+	gen := GoStruct{
+		ins: InspectResult{
+			Types:   []Type{Type{Name: "order_status", Values: []string{"open"}}},
+			Domains: []Type{Type{Name: "email", BaseType: "character varying(255)"}},
+		},
+	}
+	ff := [][]string{
+		[]string{"text", "string"},
+		[]string{"smallint", "int32"},
+		[]string{"int2", "int32"},
+		[]string{"smallserial", "int32"},
+		[]string{"integer", "int32"},
+		[]string{"bigint", "int64"},
+		[]string{"boolean", "bool"},
+		[]string{"timestamp with time zone", "time.Time"},
+		[]string{"uuid", "string"},
+		[]string{"numeric(10,2)", "string"},
+		[]string{"jsonb", "json.RawMessage"},
+		[]string{"text[]", "[]string"},
+		[]string{"order_status", "OrderStatus"},
+		[]string{"email", "string"},
+		[]string{"email[]", "[]string"},
+	}
+	for _, d := range ff {
+		col := Column{
+			DataType: d[0],
+		}
+		if actual := gen.convertType(col); actual != d[1] {
+			t.Errorf("%s: expected %s, actual: %s", d[0], d[1], actual)
+		}
+	}
+}
+
+func TestGoStructNullable(t *testing.T) {
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "nickname", DataType: "text"},
+			Column{Name: "deleted_at", DataType: "timestamp with time zone"},
+			Column{Name: "tags", DataType: "text[]"},
+		},
+	}
+	ff := []struct {
+		pointers bool
+		expected []string
+	}{
+		{false, []string{"sql.NullString", "sql.NullTime", "[]string"}},
+		{true, []string{"*string", "*time.Time", "[]string"}},
+	}
+	for _, f := range ff {
+		gen := GoStruct{config: GoStructConfig{UsePointers: f.pointers}}
+		for i, m := range gen.members(table) {
+			if m.Type != f.expected[i] {
+				t.Errorf("pointers %t, %s: expected %s, actual: %s", f.pointers, m.Name, f.expected[i], m.Type)
+			}
+		}
+	}
+}
+
+func TestGoStructNullableStyle(t *testing.T) {
+	table := Table{
+		Name:    "users",
+		Columns: []Column{Column{Name: "nickname", DataType: "text"}},
+	}
+	ff := [][]string{
+		[]string{"pointer", "*string", ""},
+		[]string{"sql_null", "sql.NullString", ""},
+		[]string{"value", "string", "(nullable)"},
+	}
+	for _, f := range ff {
+		gen := GoStruct{config: GoStructConfig{NullableStyle: f[0], UsePointers: true}}
+		m := gen.members(table)[0]
+		if m.Type != f[1] || m.Comment != f[2] {
+			t.Errorf("%s: expected %s %q, actual: %s %q", f[0], f[1], f[2], m.Type, m.Comment)
+		}
+	}
+
+	if _, err := loadGoStructConfig(".", []byte(`{"output": ".", "package_name": "model", "nullable_style": "maybe"}`)); err == nil {
+		t.Error("unknown nullable style should be error")
+	}
+}
+
+func TestGoStructOutput(t *testing.T) {
+	gen := GoStruct{
+		config:   GoStructConfig{PackageName: "model"},
+		template: template.Must(template.ParseGlob("templates/gostruct/*.tmpl")),
+	}
+	table := Table{
+		Name: "user_accounts",
+		Columns: []Column{
+			Column{Name: "id", DataType: "bigint", NotNull: true},
+			Column{Name: "created_at", DataType: "timestamp with time zone", NotNull: true},
+		},
+	}
+	var buf bytes.Buffer
+	if err := gen.buildTable(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{
+		"package model\n",
+		"import (\n\t\"time\"\n)\n",
+		"type UserAccounts struct {\n",
+		"\tId        int64     `db:\"id\" json:\"id\"`\n",
+		"\tCreatedAt time.Time `db:\"created_at\" json:\"createdAt\"`\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+	for _, unused := range []string{"database/sql", "encoding/json"} {
+		if strings.Contains(out, unused) {
+			t.Errorf("unused import %s:\n%s", unused, out)
+		}
+	}
+}
+
+func TestGoStructFormat(t *testing.T) {
+	unformatted := template.Must(template.New("").Parse(`{{ define "x" }}package model
+type   T struct{
+A int ` + "`db:\"a\"`" + `
+}
+{{ end }}`))
+	gen := GoStruct{template: unformatted}
+	var buf bytes.Buffer
+	if err := gen.execute(&buf, "x", nil); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "package model\n\ntype T struct {\n\tA int `db:\"a\"`\n}\n"; buf.String() != expected {
+		t.Errorf("expected %q, actual: %q", expected, buf.String())
+	}
+
+	format := false
+	gen.config.Format = &format
+	buf.Reset()
+	if err := gen.execute(&buf, "x", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "type   T struct{") {
+		t.Errorf("output should be left unformatted:\n%s", buf.String())
+	}
+
+	gen = GoStruct{template: template.Must(template.New("").Parse(`{{ define "x" }}package model
+type T struct {{ end }}`))}
+	err := gen.execute(&buf, "x", nil)
+	if err == nil || !strings.Contains(err.Error(), "type T struct") {
+		t.Errorf("error should quote the offending source: %v", err)
+	}
+}
+
+func TestGoStructOptimizeLayout(t *testing.T) {
+	gen := GoStruct{
+		config:   GoStructConfig{PackageName: "model", OptimizeLayout: true},
+		template: template.Must(template.ParseGlob("templates/gostruct/*.tmpl")),
+	}
+	table := Table{
+		Name: "events",
+		Columns: []Column{
+			Column{Name: "active", DataType: "boolean", NotNull: true},
+			Column{Name: "id", DataType: "bigint", NotNull: true},
+			Column{Name: "count", DataType: "integer", NotNull: true},
+			Column{Name: "name", DataType: "text", NotNull: true, Comment: sql.NullString{String: "display name", Valid: true}},
+			Column{Name: "deleted", DataType: "boolean", NotNull: true},
+		},
+	}
+	var names []string
+	for _, m := range gen.members(table) {
+		names = append(names, m.Name+":"+m.Comment)
+	}
+	expected := "Id:column 2,Name:column 4: display name,Count:column 3,Active:column 1,Deleted:column 5"
+	if actual := strings.Join(names, ","); actual != expected {
+		t.Errorf("expected %s, actual: %s", expected, actual)
+	}
+
+	var buf bytes.Buffer
+	if err := gen.buildTable(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "\tId      int64  `db:\"id\" json:\"id\"`           // column 2\n"; !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in:\n%s", expected, buf.String())
+	}
+}
+
+func TestGoStructTableNames(t *testing.T) {
+	gen := GoStruct{
+		config:   GoStructConfig{PackageName: "model", IgnoreTables: []string{"^schema_migrations$"}},
+		template: template.Must(template.ParseGlob("templates/gostruct/*.tmpl")),
+		ins: InspectResult{Tables: []Table{
+			Table{Name: "users", Columns: []Column{
+				Column{Name: "id", DataType: "bigint"},
+				Column{Name: "email", DataType: "text"},
+			}},
+			Table{Name: "schema_migrations", Columns: []Column{Column{Name: "version", DataType: "text"}}},
+		}},
+	}
+	var buf bytes.Buffer
+	if err := gen.buildTableNames(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{
+		"\tTableUsers       = \"users\"\n",
+		"\tUsersColumnId    = \"id\"\n",
+		"\tUsersColumnEmail = \"email\"\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "SchemaMigrations") {
+		t.Errorf("ignored table is generated:\n%s", out)
+	}
+}
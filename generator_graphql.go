@@ -0,0 +1,277 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type GraphQLConfig struct {
+	Output       string   `json:"output"`
+	Templates    string   `json:"templates"`
+	IgnoreTables []string `json:"ignore_tables"`
+	// SkipEmptyTables skips tables without columns, which may exist while migrating
+	SkipEmptyTables bool `json:"skip_empty_tables"`
+	// Federation writes @key directives of primary keys and the Apollo Federation preamble
+	Federation bool `json:"federation"`
+	// StableOutput leaves files whose content is unchanged, ignoring generated timestamps
+	StableOutput bool `json:"stable_output"`
+	// IncludeTables restricts generation to tables matching the glob patterns like order_*, before IgnoreTables
+	IncludeTables []string `json:"include_tables"`
+}
+
+type GraphQL struct {
+	db       *sql.DB
+	config   GraphQLConfig
+	ins      InspectResult
+	template *template.Template
+	root     string
+	opts     BuildOptions
+}
+
+type GraphQLObject struct {
+	Name    string
+	Comment string
+	Members []GraphQLMember
+	// Key is the fields of the @key directive, like "tenantId orderId"
+	Key string
+}
+
+type GraphQLMember struct {
+	Name    string
+	Type    string
+	Comment string
+}
+
+type GraphQLTypeMember struct {
+	Name    string
+	Comment string
+	Values  []string
+}
+
+const GraphQLTypeName = "graphql"
+
+const graphQLSchemaFileName = "schema.graphql"
+
+// graphQLFederationLink imports the directives of Apollo Federation 2 used by the schema.
+const graphQLFederationLink = `extend schema @link(url: "https://specs.apollo.dev/federation/v2.0", import: ["@key"])`
+
+// graphQLDateTime is the custom scalar of date and time columns.
+const graphQLDateTime = "DateTime"
+
+func NewGraphQL(db *sql.DB, root string, raw json.RawMessage) (Generator, error) {
+	config, err := loadGraphQLConfig(root, raw)
+	if err != nil {
+		return nil, err
+	}
+	ret := GraphQL{
+		db:     db,
+		config: config,
+		root:   root,
+	}
+
+	return &ret, nil
+}
+
+func (gen *GraphQL) GetType() string {
+	return GraphQLTypeName
+}
+
+func (gen *GraphQL) Build(ins InspectResult, opts BuildOptions) error {
+	log.Printf("output: %s", opts.outputDir(gen.root, gen.config.Output))
+	log.Printf("templates: %s", filePathJoinRoot(gen.root, gen.config.Templates))
+	gen.ins = ins
+	gen.opts = opts
+	opts = opts.stableOutput(gen.config.StableOutput)
+
+	// Load templates
+	tdir := filepath.Join(filePathJoinRoot(gen.root, gen.config.Templates), "*.tmpl")
+	t := template.Must(template.New("").Funcs(templateFuncs()).ParseGlob(tdir))
+	gen.template = t
+
+	// All types are written to one file, so the SDL validates as a unit
+	if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), graphQLSchemaFileName), func(wr io.Writer) error {
+		return gen.buildSchema(wr)
+	}); err != nil {
+		return errors.Wrap(err, "build write schema")
+	}
+
+	return nil
+}
+
+func (gen *GraphQL) buildSchema(wr io.Writer) error {
+	var objects []GraphQLObject
+	scalar := false
+	// Build tables and row types of functions
+	for _, table := range gen.ins.TablesAndFunctions() {
+		if skipTable(gen.config.IncludeTables, gen.config.IgnoreTables, table.Name) {
+			continue
+		}
+		if len(table.Columns) == 0 && gen.config.SkipEmptyTables {
+			log.Printf("skip %s: no columns", table.Name)
+			continue
+		}
+		obj := GraphQLObject{
+			Name:    SnakeToUpperCamel(table.Name),
+			Comment: graphQLDescription(table.Comment.String),
+			Members: gen.members(table),
+			Key:     gen.key(table),
+		}
+		for _, m := range obj.Members {
+			if strings.Contains(m.Type, graphQLDateTime) {
+				scalar = true
+			}
+		}
+		objects = append(objects, obj)
+	}
+
+	// Build types
+	var enums []GraphQLTypeMember
+	for _, typ := range gen.ins.Types {
+		m := GraphQLTypeMember{
+			Name:    SnakeToUpperCamel(typ.Name),
+			Comment: graphQLDescription(typ.Comment.String),
+		}
+		for _, val := range typ.Values {
+			if isNumber(val) {
+				m.Values = append(m.Values, "VALUE_"+SnakeToUpper(val))
+			} else {
+				m.Values = append(m.Values, SnakeToUpper(val))
+			}
+		}
+		enums = append(enums, m)
+	}
+
+	return gen.template.ExecuteTemplate(wr, "schema", map[string]interface{}{
+		"stamp":   gen.opts.Stamp,
+		"now":     time.Now().UTC().Format(time.RFC3339),
+		"link":    gen.link(),
+		"scalar":  scalar,
+		"objects": objects,
+		"enums":   enums,
+	})
+}
+
+// key returns the fields of the primary key of table for @key, empty without federation.
+func (gen *GraphQL) key(table Table) string {
+	if !gen.config.Federation {
+		return ""
+	}
+	var fields []string
+	for _, col := range table.Columns {
+		if col.PrimaryKey {
+			fields = append(fields, SnakeToLowerCamel(col.Name))
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+func (gen *GraphQL) link() string {
+	if !gen.config.Federation {
+		return ""
+	}
+	return graphQLFederationLink
+}
+
+func (gen *GraphQL) members(table Table) []GraphQLMember {
+	var ret []GraphQLMember
+
+	for _, col := range table.Columns {
+		elem := col
+		elem.DataType = strings.TrimSuffix(col.DataType, "[]")
+		typ := gen.convertType(elem)
+		if col.PrimaryKey {
+			typ = "ID"
+		}
+		if elem.DataType != col.DataType {
+			// PostgreSQL can't declare elements not null, but nulls in arrays are rare
+			typ = "[" + typ + "!]"
+		}
+		if col.NotNull {
+			typ += "!"
+		}
+		m := GraphQLMember{
+			Name:    SnakeToLowerCamel(col.Name),
+			Type:    typ,
+			Comment: graphQLDescription(col.Comment.String),
+		}
+		ret = append(ret, m)
+	}
+	return ret
+}
+
+// graphQLDescription escapes s for a block string description.
+func graphQLDescription(s string) string {
+	return strings.Replace(s, `"""`, `\"""`, -1)
+}
+
+func (gen *GraphQL) convertType(col Column) string {
+	// http://spec.graphql.org/October2021/#sec-Scalars
+	col = resolveDomain(gen.ins, col)
+	if strings.HasSuffix(col.DataType, "[]") {
+		col.DataType = strings.Replace(col.DataType, "[]", "", 1)
+		return "[" + gen.convertType(col) + "]"
+	}
+
+	switch col.DataType {
+	case "text":
+		return "String"
+	case "uuid":
+		return "ID"
+	case "smallint", "int2", "int", "integer", "smallserial", "serial":
+		return "Int"
+	case "bigint", "bigserial":
+		// Int is 32-bit
+		return "String"
+	case "float", "double", "double precision", "numeric":
+		return "Float"
+	case "boolean":
+		return "Boolean"
+	case "date", "timestamp":
+		return graphQLDateTime
+	default:
+		// "timestamp with time zone", "timestamp without time zone", "timestamp(n) with time zone"
+		if strings.HasSuffix(col.DataType, "time zone") {
+			return graphQLDateTime
+		}
+		if strings.HasPrefix(col.DataType, "numeric") {
+			return "Float"
+		}
+
+		typ, err := gen.ins.FindType(col.DataType)
+		if err == nil {
+			return SnakeToUpperCamel(typ.Name)
+		}
+	}
+	// character, json and others
+	return "String"
+}
+
+func loadGraphQLConfig(root string, raw json.RawMessage) (GraphQLConfig, error) {
+	var gc GraphQLConfig
+	if err := json.Unmarshal(raw, &gc); err != nil {
+		return gc, fmt.Errorf("graphql config error: %s", err)
+	}
+	if err := applyEnvOverrides(GraphQLTypeName, &gc); err != nil {
+		return gc, fmt.Errorf("graphql config error: %s", err)
+	}
+	output := filePathJoinRoot(root, gc.Output)
+	if err := DirExists(output); err != nil {
+		return gc, fmt.Errorf("graphql output is not exists: %s", gc.Output)
+	}
+	if err := checkGlobs(gc.IncludeTables); err != nil {
+		return gc, fmt.Errorf("graphql include_tables: %s", err)
+	}
+	if err := checkIgnores(gc.IgnoreTables); err != nil {
+		return gc, fmt.Errorf("graphql ignore_tables: %s", err)
+	}
+	return gc, nil
+}
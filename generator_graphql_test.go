@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestGraphQLMembers(t *testing.T) {
+	gen := GraphQL{
+		ins: InspectResult{
+			Types:   []Type{Type{Name: "user_status", Values: []string{"active"}}},
+			Domains: []Type{Type{Name: "positive_int", BaseType: "integer"}},
+		},
+	}
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer", NotNull: true, PrimaryKey: true},
+			Column{Name: "display_name", DataType: "text"},
+			Column{Name: "age", DataType: "integer", NotNull: true},
+			Column{Name: "tags", DataType: "text[]", Array: true, NotNull: true},
+			Column{Name: "scores", DataType: "double precision[]", Array: true},
+			Column{Name: "status", DataType: "user_status", NotNull: true},
+			Column{Name: "created_at", DataType: "timestamp with time zone"},
+			Column{Name: "points", DataType: "positive_int", NotNull: true},
+			Column{Name: "rank", DataType: "smallint"},
+		},
+	}
+	expected := [][]string{
+		[]string{"id", "ID!"},
+		[]string{"displayName", "String"},
+		[]string{"age", "Int!"},
+		[]string{"tags", "[String!]!"},
+		[]string{"scores", "[Float!]"},
+		[]string{"status", "UserStatus!"},
+		[]string{"createdAt", "DateTime"},
+		[]string{"points", "Int!"},
+		[]string{"rank", "Int"},
+	}
+	for i, m := range gen.members(table) {
+		if m.Name != expected[i][0] || m.Type != expected[i][1] {
+			t.Errorf("expected %s: %s, actual: %s: %s", expected[i][0], expected[i][1], m.Name, m.Type)
+		}
+	}
+}
+
+func TestGraphQLSchema(t *testing.T) {
+	gen := GraphQL{
+		ins: InspectResult{
+			Tables: []Table{
+				Table{
+					Name:    "orders",
+					Columns: []Column{Column{Name: "id", DataType: "uuid", NotNull: true}},
+				},
+			},
+			Types: []Type{Type{Name: "order_status", Values: []string{"open", "on_hold"}}},
+		},
+		template: template.Must(template.ParseGlob("templates/graphql/*.tmpl")),
+	}
+	var buf bytes.Buffer
+	if err := gen.buildSchema(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{
+		"type Orders {\n  id: ID!\n}",
+		"enum OrderStatus {\n  OPEN\n  ON_HOLD\n}",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "scalar DateTime") {
+		t.Errorf("unused scalar is declared:\n%s", out)
+	}
+}
+
+func TestGraphQLFederation(t *testing.T) {
+	gen := GraphQL{
+		config: GraphQLConfig{Federation: true},
+		ins: InspectResult{
+			Tables: []Table{
+				Table{
+					Name:    "users",
+					Columns: []Column{Column{Name: "id", DataType: "bigint", NotNull: true, PrimaryKey: true}},
+				},
+				Table{
+					Name: "tenant_orders",
+					Columns: []Column{
+						Column{Name: "tenant_id", DataType: "bigint", NotNull: true, PrimaryKey: true},
+						Column{Name: "order_id", DataType: "bigint", NotNull: true, PrimaryKey: true},
+					},
+				},
+				Table{
+					Name:    "logs",
+					Columns: []Column{Column{Name: "message", DataType: "text"}},
+				},
+			},
+		},
+		template: template.Must(template.ParseGlob("templates/graphql/*.tmpl")),
+	}
+	var buf bytes.Buffer
+	if err := gen.buildSchema(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{
+		"\n\nextend schema @link(url: \"https://specs.apollo.dev/federation/v2.0\", import: [\"@key\"])\n",
+		"type Users @key(fields: \"id\") {\n  id: ID!\n}\n",
+		"type TenantOrders @key(fields: \"tenantId orderId\") {\n",
+		"type Logs {\n  message: String\n}\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+
+	gen.config.Federation = false
+	buf.Reset()
+	if err := gen.buildSchema(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); strings.Contains(out, "@key") || strings.Contains(out, "@link") {
+		t.Errorf("federation directives without federation:\n%s", out)
+	}
+}
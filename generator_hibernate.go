@@ -5,11 +5,12 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"log"
-	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -29,6 +30,69 @@ type HibernateConfig struct {
 	IgnoreColumns        []string `json:"ignore_columns"`
 	GenerateMetamodel    bool     `json:"generate_metamodel"`
 	VersionFieldColumn   string   `json:"version_field_column"`
+	PiiColumns           []string `json:"pii_columns"`
+	PiiConverter         string   `json:"pii_converter"`
+	DynamicUpdate        bool     `json:"dynamic_update"`
+	DynamicInsert        bool     `json:"dynamic_insert"`
+	// ImplementSerializable adds serialVersionUID to entities
+	ImplementSerializable bool `json:"implement_serializable"`
+	// PreferBigInteger maps integral numeric wider than long to BigInteger
+	PreferBigInteger bool `json:"prefer_big_integer"`
+	// GenerateCheck adds table level CHECK constraints as @Check (Hibernate 6)
+	GenerateCheck bool `json:"generate_check"`
+	// ColumnTransformers are keyed by "table.column"
+	ColumnTransformers map[string]ColumnTransformer `json:"column_transformers"`
+	// SoftDeleteColumn is a timestamp or boolean column marking deleted rows
+	SoftDeleteColumn string `json:"soft_delete_column"`
+	// Formulas are read-only computed properties per table
+	Formulas map[string][]FormulaDef `json:"formulas"`
+	// SkipEmptyTables skips tables without columns, which may exist while migrating
+	SkipEmptyTables bool `json:"skip_empty_tables"`
+	// EnumValueComments documents enum values, keyed by enum type and value
+	EnumValueComments map[string]map[string]string `json:"enum_value_comments"`
+	// CacheableTables are tables (names or globs like country_*) of entities using the second-level cache
+	CacheableTables []string `json:"cacheable_tables"`
+	// CacheStrategy is a CacheConcurrencyStrategy like READ_WRITE (default) or READ_ONLY
+	CacheStrategy string `json:"cache_strategy"`
+	// GeneratedColumns ("column" or "table.column") are computed by defaults or triggers on insert
+	GeneratedColumns []string `json:"generated_columns"`
+	// GenerateViews writes read-only entities of views and materialized views
+	GenerateViews bool `json:"generate_views"`
+	// TypeOverrides maps data types like citext to Java types, taking precedence over built-in mappings
+	TypeOverrides map[string]string `json:"type_overrides"`
+	// TemplateData is passed to every template as .extra
+	TemplateData map[string]interface{} `json:"template_data"`
+	// ColumnRenames are names of fields and accessors keyed by "table.column"
+	ColumnRenames map[string]string `json:"column_renames"`
+	// GenerateTypeRegistry writes package-info.java registering generated user types with @TypeDefs
+	GenerateTypeRegistry bool `json:"generate_type_registry"`
+	// InferNonInsertable treats columns whose values are managed by the database as not insertable
+	InferNonInsertable bool `json:"infer_non_insertable"`
+	// StableOutput leaves files whose content is unchanged, ignoring generated timestamps
+	StableOutput bool `json:"stable_output"`
+	// IncludeTables restricts generation to tables matching the glob patterns like order_*, before IgnoreTables
+	IncludeTables []string `json:"include_tables"`
+	// Concurrency is the number of entities built at once, GOMAXPROCS if zero
+	Concurrency int `json:"concurrency"`
+}
+
+// HibernateTypeDef is a user type registered by @TypeDef.
+type HibernateTypeDef struct {
+	Name  string // DB type name
+	Class string
+}
+
+const hibernateTypeRegistryFileName = "package-info.java"
+
+type FormulaDef struct {
+	Name string `json:"name"` // property name in snake case
+	SQL  string `json:"sql"`
+	Type string `json:"type"` // Java type
+}
+
+type ColumnTransformer struct {
+	Read  string `json:"read"`
+	Write string `json:"write"`
 }
 
 type Hibernate struct {
@@ -37,12 +101,53 @@ type Hibernate struct {
 	ins      InspectResult
 	template *template.Template
 	root     string
+	opts     BuildOptions
 }
 
 type HibernateMember struct {
 	Name    string
 	Type    string
 	Comment string
+	Init    string // initializer of the literal default
+}
+
+// HibernateIndex is an @Index of @Table.
+type HibernateIndex struct {
+	Name       string
+	ColumnList string
+}
+
+// HibernateIdField is a field of the @IdClass of a composite primary key.
+type HibernateIdField struct {
+	Name string
+	Func string
+	Type string
+}
+
+// HibernateCompositeAttribute is an attribute of a composite type, read from the text of a row
+// literal like (street,12345) by Parse, a Java expression of the text s.
+type HibernateCompositeAttribute struct {
+	Name  string
+	Func  string
+	Type  string
+	Parse string
+}
+
+// hibernateCompositeParsers are Java expressions converting the text s of a composite attribute.
+// The text of other types isn't read, so composites having them have no user type.
+var hibernateCompositeParsers = map[string]string{
+	"String":     "s",
+	"Short":      "Short.valueOf(s)",
+	"Integer":    "Integer.valueOf(s)",
+	"Long":       "Long.valueOf(s)",
+	"Float":      "Float.valueOf(s)",
+	"Double":     "Double.valueOf(s)",
+	"BigDecimal": "new BigDecimal(s)",
+	"BigInteger": "new BigInteger(s)",
+	"Boolean":    `"t".equals(s)`,
+	"UUID":       "UUID.fromString(s)",
+	"LocalDate":  "LocalDate.parse(s)",
+	"LocalTime":  "LocalTime.parse(s)",
 }
 
 type HibernateMetamodel struct {
@@ -60,6 +165,12 @@ type HibernateAccessor struct {
 
 const HibernateTypeName = "hibernate"
 
+const defaultHibernatePiiConverter = "PiiConverter"
+
+const defaultHibernateCacheStrategy = "READ_WRITE"
+
+var hibernateCacheStrategies = []string{"NONE", "READ_ONLY", "NONSTRICT_READ_WRITE", "READ_WRITE", "TRANSACTIONAL"}
+
 func NewHibernate(db *sql.DB, root string, raw json.RawMessage) (Generator, error) {
 	config, err := loadHibernateConfig(root, raw)
 	if err != nil {
@@ -78,119 +189,423 @@ func (gen *Hibernate) GetType() string {
 	return HibernateTypeName
 }
 
-func (gen *Hibernate) Build(ins InspectResult) error {
-	log.Printf("output: %s", filePathJoinRoot(gen.root, gen.config.Output))
+func (gen *Hibernate) Build(ins InspectResult, opts BuildOptions) error {
+	log.Printf("output: %s", opts.outputDir(gen.root, gen.config.Output))
 	log.Printf("templates: %s", filePathJoinRoot(gen.root, gen.config.Templates))
 	gen.ins = ins
+	gen.opts = opts
+	opts = opts.stableOutput(gen.config.StableOutput).keepExisting(gen.config.Overwrites)
 
 	// Load templates
 	tdir := filepath.Join(filePathJoinRoot(gen.root, gen.config.Templates), "*.tmpl")
-	t := template.Must(template.ParseGlob(tdir))
+	t := template.Must(template.New("").Funcs(templateFuncs()).ParseGlob(tdir))
 	gen.template = t
 
 	// Build tables
-	for _, table := range gen.ins.Tables {
-		if partContainsRegex(gen.config.IgnoreTables, table.Name) {
+	var jobs []fileJob
+	for _, table := range gen.tables() {
+		if skipTable(gen.config.IncludeTables, gen.config.IgnoreTables, table.Name) {
 			continue
 		}
+		if len(table.Columns) == 0 && gen.config.SkipEmptyTables {
+			log.Printf("skip %s: no columns", table.Name)
+			continue
+		}
+		gen.warnTable(table)
 
+		table := table
 		fileName := SnakeToUpperCamel(table.Name) + ".java"
-		file, err := os.Create(filepath.Join(filePathJoinRoot(gen.root, gen.config.Output), fileName))
-		if err != nil {
-			return errors.Wrap(err, "build create file")
-		}
-		if err := gen.buildTable(file, table); err != nil {
-			file.Close()
-			return errors.Wrap(err, "build write table")
+		jobs = append(jobs, fileJob{
+			path:  filepath.Join(opts.outputDir(gen.root, gen.config.Output), fileName),
+			write: func(wr io.Writer) error { return gen.buildTable(wr, table) },
+			wrap:  "build write table",
+		})
+
+		if pks := compositeKey(table); pks != nil {
+			idFileName := hibernateIdClassName(table) + ".java"
+			jobs = append(jobs, fileJob{
+				path:  filepath.Join(opts.outputDir(gen.root, gen.config.Output), idFileName),
+				write: func(wr io.Writer) error { return gen.buildIdClass(wr, table, pks) },
+				wrap:  "build write id class",
+			})
 		}
 
 		if gen.config.GenerateMetamodel {
 			// generate meta model class file
 			metaFileName := SnakeToUpperCamel(table.Name) + "_.java"
-			metaFile, err := os.Create(filepath.Join(filePathJoinRoot(gen.root, gen.config.Output), metaFileName))
-			if err != nil {
-				file.Close()
-				return errors.Wrap(err, "create metamodel file")
-			}
-			if err := gen.buildMetamodel(metaFile, table); err != nil {
-				file.Close()
-				metaFile.Close()
-				return errors.Wrap(err, "build write metamodel")
-			}
-			metaFile.Close()
+			jobs = append(jobs, fileJob{
+				path:  filepath.Join(opts.outputDir(gen.root, gen.config.Output), metaFileName),
+				write: func(wr io.Writer) error { return gen.buildMetamodel(wr, table) },
+				wrap:  "build write metamodel",
+			})
 		}
-		file.Close()
+	}
+	if err := opts.writeFiles(jobs, gen.config.Concurrency); err != nil {
+		return err
 	}
 
 	// Build types
 	for _, typ := range gen.ins.Types {
 		fileName := SnakeToUpperCamel(typ.Name) + ".java"
-		file, err := os.Create(filepath.Join(filePathJoinRoot(gen.root, gen.config.Output), fileName))
-		if err != nil {
-			return errors.Wrap(err, "build create file")
+		utFileName := SnakeToUpperCamel(typ.Name) + "UserType.java"
+		if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), fileName), func(wr io.Writer) error {
+			return opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), utFileName), func(utwr io.Writer) error {
+				return gen.buildType(wr, utwr, typ)
+			})
+		}); err != nil {
+			return errors.Wrap(err, "build write type")
 		}
+	}
 
-		utFileName := SnakeToUpperCamel(typ.Name) + "UserType.java"
-		utFile, err := os.Create(filepath.Join(filePathJoinRoot(gen.root, gen.config.Output), utFileName))
-		if err != nil {
-			file.Close()
-			return errors.Wrap(err, "build usertype file")
+	if gen.config.GenerateTypeRegistry && len(gen.ins.Types) > 0 {
+		if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), hibernateTypeRegistryFileName), func(wr io.Writer) error {
+			return gen.buildTypeRegistry(wr)
+		}); err != nil {
+			return errors.Wrap(err, "build write type registry")
 		}
+	}
 
-		if err := gen.buildType(file, utFile, typ); err != nil {
-			file.Close()
-			utFile.Close()
-			return errors.Wrap(err, "build write type")
+	// Build composite types
+	for _, typ := range gen.ins.Composites {
+		fileName := SnakeToUpperCamel(typ.Name) + ".java"
+		if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), fileName), func(wr io.Writer) error {
+			return gen.buildComposite(wr, typ)
+		}); err != nil {
+			return errors.Wrap(err, "build write composite")
+		}
+		attrs, ok := gen.compositeAttributes(typ)
+		if !ok {
+			continue
+		}
+		utFileName := SnakeToUpperCamel(typ.Name) + "UserType.java"
+		if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), utFileName), func(wr io.Writer) error {
+			return gen.buildCompositeUserType(wr, typ, attrs)
+		}); err != nil {
+			return errors.Wrap(err, "build write composite user type")
 		}
-		file.Close()
 	}
 
 	return nil
 }
 
+// tables returns tables, followed by views if generate_views.
+func (gen *Hibernate) tables() []Table {
+	if !gen.config.GenerateViews {
+		return gen.ins.Tables
+	}
+	ret := make([]Table, 0, len(gen.ins.Tables)+len(gen.ins.Views))
+	ret = append(ret, gen.ins.Tables...)
+	return append(ret, gen.ins.Views...)
+}
+
 func (gen *Hibernate) buildTable(wr io.Writer, table Table) error {
+	if err := checkColumnRenames(gen.config.ColumnRenames, table); err != nil {
+		return err
+	}
 	return gen.template.ExecuteTemplate(wr, "class", map[string]interface{}{
-		"package_name": gen.config.PackageName,
+		"extra":        gen.config.TemplateData,
+		"stamp":        gen.opts.Stamp,
+		"package_name": gen.packageName(),
 		"now":          time.Now().UTC().Format(time.RFC3339),
 		"table":        table,
 		"name":         SnakeToUpperCamel(table.Name),
 		"member":       gen.members(table),
 		"accessor":     gen.accessor(table),
+		"anotations":   gen.classAnotations(table),
+		"serial":       gen.serialVersionUID(table),
+		"unique":       uniqueIndexes(table),
+		"indexes":      hibernateIndexes(table),
+		"checks":       table.TableChecks,
+	})
+}
+
+// uniqueIndexes returns unique indexes of table, written as @UniqueConstraint.
+func uniqueIndexes(table Table) []Index {
+	var ret []Index
+	for _, idx := range table.Indexs {
+		if idx.Unique {
+			ret = append(ret, idx)
+		}
+	}
+	return ret
+}
+
+// hibernateIndexes returns @Index of indexes of table other than unique ones, so schema
+// validation matches the database.
+func hibernateIndexes(table Table) []HibernateIndex {
+	var ret []HibernateIndex
+	for _, idx := range table.Indexs {
+		if idx.Unique {
+			continue
+		}
+		var names []string
+		for _, col := range idx.Columns {
+			names = append(names, col.Name)
+		}
+		ret = append(ret, HibernateIndex{Name: idx.Name, ColumnList: strings.Join(names, ", ")})
+	}
+	return ret
+}
+
+// compositeKey returns the primary key columns of table if there are more than one,
+// which Hibernate maps by an @IdClass.
+func compositeKey(table Table) []Column {
+	var pks []Column
+	for _, col := range table.Columns {
+		if col.PrimaryKey {
+			pks = append(pks, col)
+		}
+	}
+	if len(pks) < 2 {
+		return nil
+	}
+	return pks
+}
+
+func hibernateIdClassName(table Table) string {
+	return SnakeToUpperCamel(table.Name) + "Id"
+}
+
+// buildIdClass writes the @IdClass of the composite primary key pks of table. Its fields have
+// the names and types of the @Id fields of the entity, as Hibernate requires.
+func (gen *Hibernate) buildIdClass(wr io.Writer, table Table, pks []Column) error {
+	var fields []HibernateIdField
+	for _, col := range pks {
+		fields = append(fields, HibernateIdField{
+			Name: SnakeToLowerCamel(gen.fieldName(table, col)),
+			Func: SnakeToUpperCamel(gen.fieldName(table, col)),
+			Type: gen.fieldType(table, col),
+		})
+	}
+	return gen.template.ExecuteTemplate(wr, "id_class", map[string]interface{}{
+		"extra":        gen.config.TemplateData,
+		"stamp":        gen.opts.Stamp,
+		"package_name": gen.packageName(),
+		"table":        table,
+		"name":         hibernateIdClassName(table),
+		"member":       fields,
+	})
+}
+
+func hasPrimaryKey(table Table) bool {
+	for _, col := range table.Columns {
+		if col.PrimaryKey {
+			return true
+		}
+	}
+	return false
+}
+
+// buildComposite writes a value class of the composite type typ. It isn't @Embeddable, since
+// Hibernate 5 maps the attributes of an embeddable to columns of the owning table, while a column
+// of a composite type holds them in one value, read and written by the user type of the class.
+func (gen *Hibernate) buildComposite(wr io.Writer, typ Type) error {
+	table := Table{Name: typ.Name, Comment: typ.Comment, Columns: typ.Attributes}
+	var fields []HibernateIdField
+	for _, col := range typ.Attributes {
+		fields = append(fields, HibernateIdField{
+			Name: SnakeToLowerCamel(col.Name),
+			Func: SnakeToUpperCamel(col.Name),
+			Type: gen.fieldType(table, col),
+		})
+	}
+	return gen.template.ExecuteTemplate(wr, "composite", map[string]interface{}{
+		"extra":        gen.config.TemplateData,
+		"stamp":        gen.opts.Stamp,
+		"package_name": gen.packageName(),
+		"name":         SnakeToUpperCamel(typ.Name),
+		"type":         typ,
+		"member":       fields,
+	})
+}
+
+// compositeAttributes returns the attributes of the composite type typ, if the text of all of
+// them can be read (see hibernateCompositeParsers).
+func (gen *Hibernate) compositeAttributes(typ Type) ([]HibernateCompositeAttribute, bool) {
+	if len(typ.Attributes) == 0 {
+		return nil, false
+	}
+	var ret []HibernateCompositeAttribute
+	for _, col := range typ.Attributes {
+		t := gen.convertType(col)
+		parse, ok := hibernateCompositeParsers[t]
+		if !ok || col.Array {
+			return nil, false
+		}
+		ret = append(ret, HibernateCompositeAttribute{
+			Name:  SnakeToLowerCamel(col.Name),
+			Func:  SnakeToUpperCamel(col.Name),
+			Type:  t,
+			Parse: parse,
+		})
+	}
+	return ret, true
+}
+
+// buildCompositeUserType writes the UserType reading and writing columns of the composite type
+// typ as row literals like ("1 Main St",12345).
+func (gen *Hibernate) buildCompositeUserType(wr io.Writer, typ Type, attrs []HibernateCompositeAttribute) error {
+	return gen.template.ExecuteTemplate(wr, "composite_usertype", map[string]interface{}{
+		"extra":        gen.config.TemplateData,
+		"stamp":        gen.opts.Stamp,
+		"package_name": gen.packageName(),
+		"name":         SnakeToUpperCamel(typ.Name),
+		"snake":        qualifiedTypeName(typ),
+		"member":       attrs,
 	})
 }
 
+// qualifiedTypeName returns the name of typ qualified by its schema, if it is known.
+func qualifiedTypeName(typ Type) string {
+	if typ.Schema == "" {
+		return typ.Name
+	}
+	return typ.Schema + "." + typ.Name
+}
+
+// softDeleteAnotations returns @Where to filter deleted rows and @SQLDelete to mark rows deleted
+// instead of deleting, if table has the soft delete column.
+func (gen *Hibernate) softDeleteAnotations(table Table) []string {
+	if gen.config.SoftDeleteColumn == "" {
+		return nil
+	}
+	var col *Column
+	var pks []string
+	hasVersion := false
+	for i, c := range table.Columns {
+		if c.Name == gen.config.SoftDeleteColumn {
+			col = &table.Columns[i]
+		}
+		if c.PrimaryKey {
+			pks = append(pks, quoteIdentifier(c.Name)+" = ?")
+		}
+		if c.Name == gen.config.VersionFieldColumn {
+			hasVersion = true
+		}
+	}
+	if col == nil {
+		return nil
+	}
+
+	clause := col.Name + " IS NULL"
+	set := quoteIdentifier(col.Name) + " = now()"
+	if col.DataType == "boolean" {
+		clause = col.Name + " = false"
+		set = quoteIdentifier(col.Name) + " = true"
+	}
+	ret := []string{fmt.Sprintf("@Where(clause = %s)", strconv.Quote(clause))}
+	if len(pks) > 0 {
+		// Hibernate binds the id, then the version
+		if hasVersion {
+			pks = append(pks, quoteIdentifier(gen.config.VersionFieldColumn)+" = ?")
+		}
+		// qualified like @Table, so search_path can't pick a table of another schema
+		schema := table.Schema
+		if schema == "" {
+			schema = "public"
+		}
+		sql := fmt.Sprintf("UPDATE %s.%s SET %s WHERE %s", quoteIdentifier(schema), quoteIdentifier(table.Name),
+			set, strings.Join(pks, " AND "))
+		ret = append(ret, fmt.Sprintf("@SQLDelete(sql = %s)", strconv.Quote(sql)))
+	}
+	return ret
+}
+
+// serialVersionUID returns a serialVersionUID literal computed from the field signature,
+// so it only changes when fields change.
+func (gen *Hibernate) serialVersionUID(table Table) string {
+	if !gen.config.ImplementSerializable {
+		return ""
+	}
+	h := fnv.New64a()
+	io.WriteString(h, SnakeToUpperCamel(table.Name))
+	for _, m := range gen.members(table) {
+		io.WriteString(h, ";"+m.Type+" "+m.Name)
+	}
+	return fmt.Sprintf("%dL", int64(h.Sum64()))
+}
+
+// classAnotations returns annotations put on the entity class.
+func (gen *Hibernate) classAnotations(table Table) []string {
+	var ret []string
+	if table.IsView {
+		ret = append(ret, "@Immutable")
+	}
+	if gen.config.DynamicUpdate {
+		ret = append(ret, "@DynamicUpdate")
+	}
+	if gen.config.DynamicInsert {
+		ret = append(ret, "@DynamicInsert")
+	}
+	if matchGlob(gen.config.CacheableTables, table.Name) {
+		strategy := gen.config.CacheStrategy
+		if strategy == "" {
+			strategy = defaultHibernateCacheStrategy
+		}
+		ret = append(ret, "@Cacheable", fmt.Sprintf("@Cache(usage = CacheConcurrencyStrategy.%s)", strategy))
+	}
+	if compositeKey(table) != nil {
+		ret = append(ret, fmt.Sprintf("@IdClass(%s.class)", hibernateIdClassName(table)))
+	}
+	ret = append(ret, gen.softDeleteAnotations(table)...)
+	if gen.config.GenerateCheck {
+		for _, check := range table.TableChecks {
+			ret = append(ret, fmt.Sprintf("@Check(constraints = %s)", strconv.Quote(check.Expression)))
+		}
+	}
+	return ret
+}
+
 func (gen *Hibernate) buildMetamodel(wr io.Writer, table Table) error {
 	return gen.template.ExecuteTemplate(wr, "metamodel", map[string]interface{}{
-		"package_name": gen.config.PackageName,
+		"extra":        gen.config.TemplateData,
+		"stamp":        gen.opts.Stamp,
+		"package_name": gen.packageName(),
 		"name":         SnakeToUpperCamel(table.Name),
 		"member":       gen.metamodel(table),
 	})
 }
 
-func (gen *Hibernate) members(table Table) []HibernateMember {
-	var ret []HibernateMember
-	hasPrimary := false
-
+// warnTable logs problems of table before entities are built concurrently, to log in order.
+func (gen *Hibernate) warnTable(table Table) {
+	if !table.IsView && !hasPrimaryKey(table) {
+		log.Printf("WARN: %s doesn't has primary key", table.Name)
+	}
 	for _, col := range table.Columns {
-		t := gen.convertType(col)
-		if col.Array {
-			t = fmt.Sprintf("%s[]", t)
-		}
-		if col.PrimaryKey {
-			hasPrimary = true
+		if col.ForeignKeySrc.Valid {
+			if _, err := parseForeignKey(col.ForeignKeySrc.String); err != nil {
+				log.Printf("WARN: %s.%s is mapped without relation: %s", table.Name, col.Name, err)
+			}
 		}
+	}
+}
 
+func (gen *Hibernate) members(table Table) []HibernateMember {
+	var ret []HibernateMember
+	for _, col := range table.Columns {
 		m := HibernateMember{
-			Name:    SnakeToLowerCamel(col.Name),
-			Type:    t,
+			Name:    SnakeToLowerCamel(gen.fieldName(table, col)),
+			Type:    gen.fieldType(table, col),
 			Comment: strings.Replace(col.Comment.String, "\n", "", -1),
 		}
+		if c, ok := domainComment(gen.ins, col); ok {
+			m.Comment = strings.TrimSpace(m.Comment + " (" + strings.Replace(c, "\n", " ", -1) + ")")
+		}
+		if m.Type == "Duration" {
+			m.Comment = strings.TrimSpace(m.Comment + " (" + intervalDurationNote + ")")
+		}
+		if init, ok := gen.initializer(col, m.Type); ok {
+			m.Init = init
+		}
 		ret = append(ret, m)
 	}
-	if !hasPrimary {
-		log.Printf("WARN: %s doesn't has primary key", table.Name)
+	for _, f := range gen.config.Formulas[table.Name] {
+		ret = append(ret, HibernateMember{
+			Name:    SnakeToLowerCamel(f.Name),
+			Type:    f.Type,
+			Comment: "formula: " + f.SQL,
+		})
 	}
-
 	return ret
 }
 
@@ -204,11 +619,14 @@ func (gen *Hibernate) metamodel(table Table) []HibernateMetamodel {
 		if col.Array {
 			typ = typ + "[]"
 		}
+		if fk, ok := gen.relation(table, col); ok {
+			typ = SnakeToUpperCamel(fk.RefTable)
+		}
 
 		m := HibernateMetamodel{
 			Attr:    attr,
 			ClsName: SnakeToUpperCamel(table.Name),
-			Name:    decapitalize(SnakeToUpperCamel(col.Name)),
+			Name:    decapitalize(SnakeToUpperCamel(gen.fieldName(table, col))),
 			Type:    typ,
 		}
 		ret = append(ret, m)
@@ -237,32 +655,54 @@ func (gen *Hibernate) accessor(table Table) []string {
 	var ret []string
 
 	for _, col := range table.Columns {
-		getter, err := gen.getter(col)
+		getter, err := gen.getter(table, col)
 		if err != nil {
 			log.Fatal(err)
 		}
 		ret = append(ret, getter)
 
-		setter, err := gen.setter(col)
+		setter, err := gen.setter(table, col)
 		if err != nil {
 			log.Fatal(err)
 		}
 		ret = append(ret, setter)
 	}
+
+	// formulas are read only
+	for _, f := range gen.config.Formulas[table.Name] {
+		getter, err := gen.formulaGetter(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ret = append(ret, getter)
+	}
 	return ret
 }
 
-func (gen *Hibernate) getter(col Column) (string, error) {
+func (gen *Hibernate) formulaGetter(f FormulaDef) (string, error) {
 	var ret bytes.Buffer
-	t := gen.convertType(col)
-	if col.Array {
-		t = fmt.Sprintf("%s[]", t)
+	data := map[string]interface{}{
+		"extra":      gen.config.TemplateData,
+		"func":       SnakeToUpperCamel(f.Name),
+		"name":       SnakeToLowerCamel(f.Name),
+		"type":       f.Type,
+		"anotations": []string{fmt.Sprintf("@Formula(%s)", strconv.Quote(f.SQL))},
+	}
+	if err := gen.template.ExecuteTemplate(&ret, "getter", data); err != nil {
+		return "", errors.Wrap(err, "formula getter: "+f.Name)
 	}
+
+	return ret.String(), nil
+}
+
+func (gen *Hibernate) getter(table Table, col Column) (string, error) {
+	var ret bytes.Buffer
 	data := map[string]interface{}{
-		"func":       SnakeToUpperCamel(col.Name),
-		"name":       SnakeToLowerCamel(col.Name),
-		"type":       t,
-		"anotations": gen.anotations(col),
+		"extra":      gen.config.TemplateData,
+		"func":       SnakeToUpperCamel(gen.fieldName(table, col)),
+		"name":       SnakeToLowerCamel(gen.fieldName(table, col)),
+		"type":       gen.fieldType(table, col),
+		"anotations": gen.anotations(table, col),
 	}
 	if err := gen.template.ExecuteTemplate(&ret, "getter", data); err != nil {
 		return "", errors.Wrap(err, "getter: "+col.Name)
@@ -271,13 +711,191 @@ func (gen *Hibernate) getter(col Column) (string, error) {
 	return ret.String(), nil
 }
 
+// parseForignTable returns the referenced table and column of a constraint definition like
+// FOREIGN KEY (security_code) REFERENCES master_security(security_code).
+// Composite foreign keys return the column referenced by the first column.
 func parseForignTable(src string) (string, string) {
-	// FOREIGN KEY (security_code) REFERENCES master_security(security_code)
-	return "", ""
+	fk, err := parseForeignKey(src)
+	if err != nil {
+		return "", ""
+	}
+	return fk.RefTable, fk.RefColumns[0]
+}
+
+// fieldName returns the snake case name of the field of col, which may be renamed by column_renames.
+func (gen *Hibernate) fieldName(table Table, col Column) string {
+	return renamedColumn(gen.config.ColumnRenames, table.Name, col)
+}
+
+// fieldType returns the Java type of col, which is the referenced entity for relations.
+func (gen *Hibernate) fieldType(table Table, col Column) string {
+	if fk, ok := gen.relation(table, col); ok {
+		return SnakeToUpperCamel(fk.RefTable)
+	}
+	t := gen.convertType(col)
+	if col.Array {
+		t = fmt.Sprintf("%s[]", t)
+	}
+	return t
+}
+
+// foreignKey returns the foreign key of col if the referenced table is generated as an entity.
+func (gen *Hibernate) foreignKey(col Column) (ForeignKey, bool) {
+	if !col.ForeignKeySrc.Valid {
+		return ForeignKey{}, false
+	}
+	fk, err := parseForeignKey(col.ForeignKeySrc.String)
+	if err != nil {
+		return ForeignKey{}, false
+	}
+	if !gen.entityExists(fk.RefTable) {
+		return ForeignKey{}, false
+	}
+	return fk, true
+}
+
+// relation returns the foreign key mapped as @ManyToOne or @OneToOne on col.
+// The relation of a composite foreign key is held by its first column,
+// and primary keys stay scalar to keep @Id simple.
+func (gen *Hibernate) relation(table Table, col Column) (ForeignKey, bool) {
+	fk, ok := gen.foreignKey(col)
+	if !ok || col.PrimaryKey || fk.Columns[0] != col.Name {
+		return ForeignKey{}, false
+	}
+	return fk, true
+}
+
+// joined reports whether col is a following column of a composite foreign key,
+// which is written through the relation of the first column.
+func (gen *Hibernate) joined(table Table, col Column) bool {
+	fk, ok := gen.foreignKey(col)
+	if !ok || fk.Columns[0] == col.Name {
+		return false
+	}
+	for _, c := range table.Columns {
+		if c.Name == fk.Columns[0] {
+			_, ok := gen.relation(table, c)
+			return ok
+		}
+	}
+	return false
+}
+
+// oneToOne reports whether the columns of fk are unique in table,
+// in which case each row references a distinct row.
+func oneToOne(table Table, fk ForeignKey) bool {
+	if len(fk.Columns) == 1 {
+		for _, c := range table.Columns {
+			if c.Name == fk.Columns[0] && c.Unique {
+				return true
+			}
+		}
+	}
+	for _, idx := range uniqueIndexes(table) {
+		if len(idx.Columns) != len(fk.Columns) {
+			continue
+		}
+		match := true
+		for _, c := range idx.Columns {
+			if !contains(fk.Columns, c.Name) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// relationAnotations returns @ManyToOne or @OneToOne and join columns of the relation fk.
+func (gen *Hibernate) relationAnotations(table Table, col Column, fk ForeignKey) []string {
+	ret := []string{"@ManyToOne(fetch = FetchType.LAZY)"}
+	if oneToOne(table, fk) {
+		ret = []string{"@OneToOne(fetch = FetchType.LAZY)"}
+	}
+
+	var joins []string
+	for i, name := range fk.Columns {
+		args := []string{
+			fmt.Sprintf(`name="%s"`, name),
+			fmt.Sprintf(`referencedColumnName="%s"`, fk.RefColumns[i]),
+		}
+		if name == col.Name {
+			args = append(args, fmt.Sprintf("nullable=%t", !col.NotNull))
+			if !gen.insertable(table, col) {
+				args = append(args, "insertable=false")
+			}
+			if !gen.updatable(table, col) {
+				args = append(args, "updatable=false")
+			}
+		}
+		joins = append(joins, fmt.Sprintf("@JoinColumn(%s)", strings.Join(args, ", ")))
+	}
+	if len(joins) == 1 {
+		ret = append(ret, joins[0])
+	} else {
+		ret = append(ret, "@JoinColumns({\n        "+strings.Join(joins, ",\n        ")+"\n    })")
+	}
+
+	if fk.OnDelete == "CASCADE" {
+		ret = append(ret, "@OnDelete(action = OnDeleteAction.CASCADE)")
+	}
+	return ret
 }
 
 var regNextval = regexp.MustCompile(`^nextval\('.+_seq'::regclass\)`)
 
+// initializer returns the Java expression of the literal default of col, of the field type typ.
+func (gen *Hibernate) initializer(col Column, typ string) (string, bool) {
+	v, ok := literalDefault(col)
+	if !ok || col.Array {
+		return "", false
+	}
+	switch typ {
+	case "String":
+		return strconv.Quote(v), true
+	case "Short":
+		if _, err := strconv.ParseInt(v, 10, 16); err == nil {
+			return "(short) " + v, true
+		}
+	case "Integer":
+		if _, err := strconv.ParseInt(v, 10, 32); err == nil {
+			return v, true
+		}
+	case "Long":
+		if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return v + "L", true
+		}
+	case "Float", "Double":
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			if typ == "Float" {
+				return v + "f", true
+			}
+			if !strings.Contains(v, ".") {
+				return v + ".0", true
+			}
+			return v, true
+		}
+	case "BigDecimal", "BigInteger":
+		if isNumber(v) {
+			return fmt.Sprintf("new %s(%s)", typ, strconv.Quote(v)), true
+		}
+	case "Boolean":
+		if v == "true" || v == "false" {
+			return v, true
+		}
+	default:
+		for _, t := range gen.ins.Types {
+			if t.Name == col.DataType && contains(t.Values, v) {
+				return typ + "." + hibernateEnumConstant(v), true
+			}
+		}
+	}
+	return "", false
+}
+
 func isSequence(col Column) bool {
 	if col.PrimaryKey && regNextval.MatchString(col.DefaultValue.String) {
 		return true
@@ -285,7 +903,17 @@ func isSequence(col Column) bool {
 	return false
 }
 
-func (gen *Hibernate) anotations(col Column) []string {
+// isSerialType reports whether the data type of col is a serial pseudo type, as written in snapshots.
+// Inspected serial columns have the underlying type and Serial.
+func isSerialType(col Column) bool {
+	switch col.DataType {
+	case "smallserial", "serial", "bigserial":
+		return true
+	}
+	return false
+}
+
+func (gen *Hibernate) anotations(table Table, col Column) []string {
 	var ret []string
 	if col.PrimaryKey {
 		ret = append(ret, "@Id")
@@ -293,17 +921,16 @@ func (gen *Hibernate) anotations(col Column) []string {
 	if col.Unique {
 		ret = append(ret, "@UniqueConstraint")
 	}
-	if col.ForignTable.Valid {
-		// a := `@JoinColumns({ @JoinColumn(name="userid", referencedColumnName="id") })`
-		// ret = append(ret, "// ForignTable = "+col.ForignTable.String)
+	if fk, ok := gen.relation(table, col); ok {
+		return append(ret, gen.relationAnotations(table, col, fk)...)
 	}
-	if col.Serial || isSequence(col) {
+	if generatedKey(col) {
 		ret = append(ret, "@GeneratedValue(strategy=GenerationType.IDENTITY)")
 	}
 
 	if gen.enumExists(col.DataType) {
 		ret = append(ret, fmt.Sprintf(`@Type(type = "%s.%sUserType")`,
-			gen.config.PackageName,
+			gen.packageName(),
 			SnakeToUpperCamel(col.DataType)))
 	}
 
@@ -311,22 +938,65 @@ func (gen *Hibernate) anotations(col Column) []string {
 		ret = append(ret, `@Type(type = "JsonUserType")`)
 	}
 
+	if typ, err := gen.ins.FindComposite(col.DataType); err == nil {
+		if _, ok := gen.compositeAttributes(typ); ok {
+			ret = append(ret, fmt.Sprintf(`@Type(type = "%s.%sUserType")`, gen.packageName(), SnakeToUpperCamel(typ.Name)))
+		} else if _, ok := gen.config.ColumnTransformers[table.Name+"."+col.Name]; !ok {
+			// the String of the row literal is cast on writes
+			ret = append(ret, fmt.Sprintf(`@ColumnTransformer(write = "?::%s")`, qualifiedTypeName(typ)))
+		}
+	}
+
+	if isInterval(col) && !col.Array {
+		// Hibernate maps Duration to bigint
+		ret = append(ret, `@Type(type = "IntervalUserType")`)
+	}
+
 	if col.Array {
 		t := strings.Title(gen.convertType(col))
 		ret = append(ret, fmt.Sprintf(`@Type(type = "%sArrayUserType")`, t))
 	}
 
+	if isPii(gen.config.PiiColumns, table.Name, col) {
+		converter := gen.config.PiiConverter
+		if converter == "" {
+			converter = defaultHibernatePiiConverter
+		}
+		ret = append(ret, fmt.Sprintf("@Convert(converter = %s.class)", converter))
+	}
+
+	if ct, ok := gen.config.ColumnTransformers[table.Name+"."+col.Name]; ok {
+		var args []string
+		if ct.Read != "" {
+			args = append(args, "read = "+strconv.Quote(ct.Read))
+		}
+		if ct.Write != "" {
+			args = append(args, "write = "+strconv.Quote(ct.Write))
+		}
+		ret = append(ret, fmt.Sprintf("@ColumnTransformer(%s)", strings.Join(args, ", ")))
+	}
+
 	if gen.config.VersionFieldColumn == col.Name {
 		ret = append(ret, fmt.Sprintf("@javax.persistence.Version"))
 	}
 
+	if col.Generated {
+		ret = append(ret, "@Generated(GenerationTime.ALWAYS)")
+	} else if containsColumn(gen.config.GeneratedColumns, table.Name, col.Name) {
+		ret = append(ret, "@Generated(GenerationTime.INSERT)")
+	}
+
+	if columnDefault(col) {
+		ret = append(ret, fmt.Sprintf("@ColumnDefault(%s)", strconv.Quote(col.DefaultValue.String)))
+	}
+
 	column_args := make([]string, 0)
 	column_args = append(column_args, fmt.Sprintf(`name="%s"`, col.Name))
 	column_args = append(column_args, fmt.Sprintf("nullable=%t", !col.NotNull))
-	if contains(gen.config.NotInsertableColumns, col.Name) {
+	if !gen.insertable(table, col) {
 		column_args = append(column_args, "insertable=false")
 	}
-	if contains(gen.config.NotUpdatableColumns, col.Name) {
+	if !gen.updatable(table, col) {
 		column_args = append(column_args, "updatable=false")
 	}
 
@@ -335,7 +1005,46 @@ func (gen *Hibernate) anotations(col Column) []string {
 	return ret
 }
 
-func (gen *Hibernate) setter(col Column) (string, error) {
+// insertable reports whether col is written by INSERT. Columns computed by the database,
+// GENERATED ALWAYS identity columns and following columns of composite foreign keys are not.
+// With infer_non_insertable, generated keys and columns defaulting to the current time are not either.
+func (gen *Hibernate) insertable(table Table, col Column) bool {
+	if col.Generated || col.IdentityKind == IdentityAlways || gen.joined(table, col) ||
+		containsColumn(gen.config.GeneratedColumns, table.Name, col.Name) {
+		return false
+	}
+	if gen.config.InferNonInsertable && (generatedKey(col) || currentTimeDefault(col)) {
+		return false
+	}
+	return !contains(gen.config.NotInsertableColumns, col.Name)
+}
+
+var currentTimeDefaults = []string{"now()", "CURRENT_TIMESTAMP", "CURRENT_DATE", "CURRENT_TIME", "LOCALTIMESTAMP", "LOCALTIME"}
+
+// currentTimeDefault reports whether col defaults to the time of the transaction, like created_at.
+func currentTimeDefault(col Column) bool {
+	return col.DefaultValue.Valid && contains(currentTimeDefaults, col.DefaultValue.String)
+}
+
+// generatedKey reports whether values of col are generated by a sequence or identity.
+func generatedKey(col Column) bool {
+	return col.Serial || isSequence(col) || isSerialType(col) || col.IdentityKind != ""
+}
+
+// updatable reports whether col is written by UPDATE.
+// GENERATED ALWAYS identity columns can only be updated to DEFAULT, and
+// generated keys are not updated with infer_non_insertable.
+func (gen *Hibernate) updatable(table Table, col Column) bool {
+	if col.Generated || col.IdentityKind == IdentityAlways || gen.joined(table, col) {
+		return false
+	}
+	if gen.config.InferNonInsertable && generatedKey(col) {
+		return false
+	}
+	return !contains(gen.config.NotUpdatableColumns, col.Name)
+}
+
+func (gen *Hibernate) setter(table Table, col Column) (string, error) {
 	var ret bytes.Buffer
 	var constraint string
 	if col.Constraint.String == "c" {
@@ -343,18 +1052,19 @@ func (gen *Hibernate) setter(col Column) (string, error) {
 	}
 
 	var scope = "public"
-	if contains(gen.config.NotInsertableColumns, col.Name) && contains(gen.config.NotUpdatableColumns, col.Name) {
+	if !gen.insertable(table, col) && !gen.updatable(table, col) {
 		scope = "private"
 	}
-
-	t := gen.convertType(col)
-	if col.Array {
-		t = fmt.Sprintf("%s[]", t)
+	if table.IsView {
+		// Hibernate sets properties of views, but applications can't
+		scope = "private"
 	}
+
 	data := map[string]interface{}{
-		"func":       SnakeToUpperCamel(col.Name),
-		"name":       SnakeToLowerCamel(col.Name),
-		"type":       t,
+		"extra":      gen.config.TemplateData,
+		"func":       SnakeToUpperCamel(gen.fieldName(table, col)),
+		"name":       SnakeToLowerCamel(gen.fieldName(table, col)),
+		"type":       gen.fieldType(table, col),
 		"scope":      scope,
 		"constraint": constraint,
 	}
@@ -365,23 +1075,53 @@ func (gen *Hibernate) setter(col Column) (string, error) {
 	return ret.String(), nil
 }
 
+// buildTypeRegistry writes @TypeDefs of the user types written by buildType.
+func (gen *Hibernate) buildTypeRegistry(wr io.Writer) error {
+	var defs []HibernateTypeDef
+	for _, typ := range gen.ins.Types {
+		defs = append(defs, HibernateTypeDef{
+			Name:  typ.Name,
+			Class: SnakeToUpperCamel(typ.Name) + "UserType",
+		})
+	}
+	return gen.template.ExecuteTemplate(wr, "package_info", map[string]interface{}{
+		"extra":        gen.config.TemplateData,
+		"stamp":        gen.opts.Stamp,
+		"package_name": gen.packageName(),
+		"types":        defs,
+	})
+}
+
 func (gen *Hibernate) buildType(wr, utwr io.Writer, typ Type) error {
 	var mem []string
 	dt := "String"
 
+	comments := gen.config.EnumValueComments[typ.Name]
 	for _, val := range typ.Values {
+		var m string
 		if isNumber(val) {
-			mem = append(mem, fmt.Sprintf("VALUE_%s(%s)", SnakeToUpper(val), val))
+			m = fmt.Sprintf("%s(%s)", hibernateEnumConstant(val), val)
 			dt = "Integer"
 		} else {
-			mem = append(mem, fmt.Sprintf(`%s("%s")`, SnakeToUpper(val), val))
+			m = fmt.Sprintf(`%s("%s")`, hibernateEnumConstant(val), val)
+		}
+		if c, ok := comments[val]; ok {
+			m = "/** " + strings.Replace(c, "*/", "* /", -1) + " */\n   " + m
 		}
+		mem = append(mem, m)
 	}
 
-	members := strings.Join(mem, ", ") + ";"
+	// values are written one per line if they have comments
+	sep := ", "
+	if len(comments) > 0 {
+		sep = ",\n   "
+	}
+	members := strings.Join(mem, sep) + ";"
 
 	if err := gen.template.ExecuteTemplate(wr, "enum", map[string]interface{}{
-		"package_name": gen.config.PackageName,
+		"extra":        gen.config.TemplateData,
+		"stamp":        gen.opts.Stamp,
+		"package_name": gen.packageName(),
 		"now":          time.Now().UTC().Format(time.RFC3339),
 		"name":         SnakeToUpperCamel(typ.Name),
 		"type":         typ,
@@ -392,7 +1132,9 @@ func (gen *Hibernate) buildType(wr, utwr io.Writer, typ Type) error {
 	}
 
 	if err := gen.template.ExecuteTemplate(utwr, "enum_usertype", map[string]interface{}{
-		"package_name": gen.config.PackageName,
+		"extra":        gen.config.TemplateData,
+		"stamp":        gen.opts.Stamp,
+		"package_name": gen.packageName(),
 		"now":          time.Now().UTC().Format(time.RFC3339),
 		"name":         SnakeToUpperCamel(typ.Name),
 		"snake":        (typ.Name),
@@ -406,6 +1148,35 @@ func (gen *Hibernate) buildType(wr, utwr io.Writer, typ Type) error {
 	return nil
 }
 
+// entityExists reports whether an entity class is generated for the table.
+func (gen *Hibernate) entityExists(tableName string) bool {
+	if skipTable(gen.config.IncludeTables, gen.config.IgnoreTables, tableName) {
+		return false
+	}
+	for _, table := range gen.ins.Tables {
+		if table.Name == tableName {
+			return len(table.Columns) > 0 || !gen.config.SkipEmptyTables
+		}
+	}
+	return false
+}
+
+// hibernateEnumConstant returns the name of the enum constant of val.
+func hibernateEnumConstant(val string) string {
+	if isNumber(val) {
+		return "VALUE_" + SnakeToUpper(val)
+	}
+	return SnakeToUpper(val)
+}
+
+// packageName returns package_name, qualified by the schema being built if any.
+func (gen *Hibernate) packageName() string {
+	if gen.opts.Schema == "" {
+		return gen.config.PackageName
+	}
+	return gen.config.PackageName + "." + gen.opts.Schema
+}
+
 func (gen *Hibernate) enumExists(typeName string) bool {
 	for _, typ := range gen.ins.Types {
 		if typ.Name == typeName {
@@ -416,8 +1187,17 @@ func (gen *Hibernate) enumExists(typeName string) bool {
 }
 
 func (gen *Hibernate) convertType(col Column) string {
+	if t, ok := overrideType(gen.config.TypeOverrides, col); ok {
+		return t
+	}
+	col = resolveDomain(gen.ins, col)
 	// numeric with presidion is double
 	if strings.Contains(col.DataType, "numeric(") {
+		if gen.config.PreferBigInteger {
+			if p, s, ok := numericPrecisionScale(col.DataType); ok && s == 0 && p > maxInt64Digits {
+				return "BigInteger"
+			}
+		}
 		return "BigDecimal"
 	}
 
@@ -429,6 +1209,8 @@ func (gen *Hibernate) convertType(col Column) string {
 	switch t {
 	case "text":
 		return "String"
+	case "smallint", "int2":
+		return "Short"
 	case "int", "integer":
 		return "Integer"
 	case "float":
@@ -437,6 +1219,8 @@ func (gen *Hibernate) convertType(col Column) string {
 		return "Double"
 	case "bigint":
 		return "Long"
+	case "smallserial":
+		return "Short"
 	case "serial":
 		return "Integer"
 	case "bigserial":
@@ -449,13 +1233,31 @@ func (gen *Hibernate) convertType(col Column) string {
 		return "BigDecimal"
 	case "date":
 		return "LocalDate"
+	case "time", "time without time zone":
+		return "LocalTime"
+	case "timetz", "time with time zone":
+		return "OffsetTime"
 	case "json", "jsonb":
 		return "JsonObject"
 	case "timestamp":
 		return "Timestamp"
 	case "boolean":
 		return "Boolean"
+	case "int2vector", "oidvector":
+		// space separated numbers like "1 2"
+		return "String"
 	default:
+		// "interval", "interval(n)", "interval day to second"
+		if strings.HasPrefix(t, "interval") {
+			return "Duration"
+		}
+		// "time(n) without time zone", "time(n) with time zone"
+		if strings.HasPrefix(t, "time(") {
+			if strings.HasSuffix(t, "without time zone") {
+				return "LocalTime"
+			}
+			return "OffsetTime"
+		}
 		// "timestamp with time zone", "timestamp without time zone", "timestamp(n) with time zone"
 		if strings.HasSuffix(t, "time zone") {
 			return "OffsetDateTime"
@@ -471,6 +1273,13 @@ func (gen *Hibernate) convertType(col Column) string {
 		if err == nil {
 			return SnakeToUpperCamel(typ.Name)
 		}
+		if typ, err := gen.ins.FindComposite(t); err == nil {
+			if _, ok := gen.compositeAttributes(typ); !ok {
+				// row literal like (1,"{2,3}") of attributes without user type
+				return "String"
+			}
+			return SnakeToUpperCamel(typ.Name)
+		}
 	}
 	return col.DataType
 }
@@ -480,9 +1289,27 @@ func loadHibernateConfig(root string, raw json.RawMessage) (HibernateConfig, err
 	if err := json.Unmarshal(raw, &hc); err != nil {
 		return hc, fmt.Errorf("hibernate config error: %s", err)
 	}
+	if err := applyEnvOverrides(HibernateTypeName, &hc); err != nil {
+		return hc, fmt.Errorf("hibernate config error: %s", err)
+	}
 	output := filePathJoinRoot(root, hc.Output)
 	if err := DirExists(output); err != nil {
 		return hc, fmt.Errorf("hibernate output is not exists: %s", hc.Output)
 	}
+	if err := checkGlobs(hc.IncludeTables); err != nil {
+		return hc, fmt.Errorf("hibernate include_tables: %s", err)
+	}
+	if err := checkIgnores(hc.IgnoreTables); err != nil {
+		return hc, fmt.Errorf("hibernate ignore_tables: %s", err)
+	}
+	if err := checkGlobs(hc.CacheableTables); err != nil {
+		return hc, fmt.Errorf("hibernate cacheable_tables: %s", err)
+	}
+	if hc.CacheStrategy != "" && !contains(hibernateCacheStrategies, hc.CacheStrategy) {
+		return hc, fmt.Errorf("hibernate cache_strategy is unknown: %s", hc.CacheStrategy)
+	}
+	if hc.Concurrency < 0 {
+		return hc, fmt.Errorf("hibernate concurrency must not be negative: %d", hc.Concurrency)
+	}
 	return hc, nil
 }
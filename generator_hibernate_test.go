@@ -1,10 +1,31 @@
 package main
 
 import (
+	"bytes"
 	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"testing"
+	"text/template"
+	"time"
 )
 
+func renderHibernateClass(t *testing.T, h *Hibernate, table Table) string {
+	h.template = template.Must(template.ParseGlob("templates/hibernate/*.tmpl"))
+	var buf bytes.Buffer
+	if err := h.buildTable(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
 func TestDecapitalize(t *testing.T) {
 	ff := [][]string{
 		[]string{"FooBar", "fooBar"},
@@ -42,6 +63,19 @@ func TestConvertType(t *testing.T) {
 		[]string{"timestamp(3) with time zone", "OffsetDateTime"},
 		[]string{"numeric(10)", "BigDecimal"},
 		[]string{"character(10)", "String"},
+		[]string{"int2vector", "String"},
+		[]string{"oidvector", "String"},
+		[]string{"time", "LocalTime"},
+		[]string{"time without time zone", "LocalTime"},
+		[]string{"time(3) without time zone", "LocalTime"},
+		[]string{"timetz", "OffsetTime"},
+		[]string{"time with time zone", "OffsetTime"},
+		[]string{"time(6) with time zone", "OffsetTime"},
+		[]string{"interval", "Duration"},
+		[]string{"interval year to month", "Duration"},
+		[]string{"smallint", "Short"},
+		[]string{"int2", "Short"},
+		[]string{"smallserial", "Short"},
 		[]string{"fooBar", "fooBar"},
 	}
 	for _, d := range ff {
@@ -54,3 +88,1161 @@ func TestConvertType(t *testing.T) {
 	}
 
 }
+
+func TestTypeOverrides(t *testing.T) {
+	h := Hibernate{
+		config: HibernateConfig{TypeOverrides: map[string]string{"citext": "String", "numeric(10,2)": "Double", "bigint": "BigInteger"}},
+	}
+	ff := [][]string{
+		[]string{"citext", "String"},
+		[]string{"citext[]", "String"},
+		[]string{"numeric(10,2)", "Double"},
+		[]string{"numeric(12,2)", "BigDecimal"},
+		[]string{"bigint", "BigInteger"},
+		[]string{"integer", "Integer"},
+	}
+	for _, d := range ff {
+		if actual := h.convertType(Column{DataType: d[0]}); actual != d[1] {
+			t.Errorf("%s: expected %s, actual: %s", d[0], d[1], actual)
+		}
+	}
+}
+
+func TestColumnRenames(t *testing.T) {
+	h := Hibernate{
+		config: HibernateConfig{ColumnRenames: map[string]string{"users.usr_nm": "userName"}},
+	}
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer", PrimaryKey: true},
+			Column{Name: "usr_nm", DataType: "text"},
+		},
+	}
+	out := renderHibernateClass(t, &h, table)
+	for _, s := range []string{
+		"private String userName;",
+		"    @Column(name=\"usr_nm\", nullable=true)\n    public String getUserName() {",
+		"public void setUserName (String arg)",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%s is missing:\n%s", s, out)
+		}
+	}
+	if m := h.metamodel(table); m[1].Name != "userName" {
+		t.Errorf("expected userName, actual: %s", m[1].Name)
+	}
+
+	// other tables are not renamed
+	if out := renderHibernateClass(t, &h, Table{Name: "admins", Columns: table.Columns}); !strings.Contains(out, "private String usrNm;") {
+		t.Errorf("usrNm is missing:\n%s", out)
+	}
+
+	table.Columns = append(table.Columns, Column{Name: "username", DataType: "text"})
+	var buf bytes.Buffer
+	if err := h.buildTable(&buf, table); err == nil {
+		t.Error("expected an error of userName colliding with username")
+	}
+}
+
+func TestInferNonInsertable(t *testing.T) {
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "bigint", PrimaryKey: true, NotNull: true, Serial: true,
+				DefaultValue: sql.NullString{String: "nextval('users_id_seq'::regclass)", Valid: true}},
+			Column{Name: "full_name", DataType: "text", Generated: true,
+				DefaultValue: sql.NullString{String: "((first_name || ' '::text) || last_name)", Valid: true}},
+			Column{Name: "created_at", DataType: "timestamp with time zone", NotNull: true,
+				DefaultValue: sql.NullString{String: "now()", Valid: true}},
+			Column{Name: "code", DataType: "bigint", NotNull: true, IdentityKind: IdentityByDefault},
+			Column{Name: "name", DataType: "text", NotNull: true,
+				DefaultValue: sql.NullString{String: "''::text", Valid: true}},
+		},
+	}
+	ff := []struct {
+		col      Column
+		inferred string
+		manual   string
+	}{
+		{table.Columns[0], `@Column(name="id", nullable=false, insertable=false, updatable=false)`, `@Column(name="id", nullable=false)`},
+		{table.Columns[1], `@Column(name="full_name", nullable=true, insertable=false, updatable=false)`, `@Column(name="full_name", nullable=true, insertable=false, updatable=false)`},
+		{table.Columns[2], `@Column(name="created_at", nullable=false, insertable=false)`, `@Column(name="created_at", nullable=false)`},
+		{table.Columns[3], `@Column(name="code", nullable=false, insertable=false, updatable=false)`, `@Column(name="code", nullable=false)`},
+		{table.Columns[4], `@Column(name="name", nullable=false)`, `@Column(name="name", nullable=false)`},
+	}
+	for _, infer := range []bool{false, true} {
+		h := Hibernate{config: HibernateConfig{InferNonInsertable: infer}}
+		for _, f := range ff {
+			expected := f.manual
+			if infer {
+				expected = f.inferred
+			}
+			if actual := h.anotations(table, f.col); !contains(actual, expected) {
+				t.Errorf("infer %t: %s is missing: %v", infer, expected, actual)
+			}
+		}
+	}
+}
+
+func TestTimeColumns(t *testing.T) {
+	h := Hibernate{}
+	table := Table{
+		Name: "shops",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer", PrimaryKey: true},
+			Column{Name: "opens_at", DataType: "time"},
+			Column{Name: "closes_at", DataType: "timetz"},
+		},
+	}
+	out := renderHibernateClass(t, &h, table)
+	for _, s := range []string{
+		"import java.time.LocalTime;",
+		"import java.time.OffsetTime;",
+		"private LocalTime opensAt;",
+		"private OffsetTime closesAt;",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%s is missing:\n%s", s, out)
+		}
+	}
+}
+
+func TestInterval(t *testing.T) {
+	h := Hibernate{}
+	table := Table{
+		Name: "plans",
+		Columns: []Column{
+			Column{Name: "period", DataType: "interval", Comment: sql.NullString{String: "billing period", Valid: true}},
+		},
+	}
+	if actual := h.anotations(table, table.Columns[0]); !contains(actual, `@Type(type = "IntervalUserType")`) {
+		t.Errorf("@Type is missing: %v", actual)
+	}
+	m := h.members(table)
+	if m[0].Type != "Duration" {
+		t.Errorf("expected Duration, actual: %s", m[0].Type)
+	}
+	if expected := "billing period (" + intervalDurationNote + ")"; m[0].Comment != expected {
+		t.Errorf("expected %s, actual: %s", expected, m[0].Comment)
+	}
+}
+
+func TestSmallint(t *testing.T) {
+	h := Hibernate{}
+	table := Table{Name: "items"}
+	serial := h.anotations(table, Column{Name: "id", DataType: "smallserial", PrimaryKey: true})
+	if !contains(serial, "@GeneratedValue(strategy=GenerationType.IDENTITY)") {
+		t.Errorf("@GeneratedValue is missing: %v", serial)
+	}
+	inspected := h.anotations(table, Column{Name: "id", DataType: "smallint", PrimaryKey: true, Serial: true})
+	if !contains(inspected, "@GeneratedValue(strategy=GenerationType.IDENTITY)") {
+		t.Errorf("@GeneratedValue is missing: %v", inspected)
+	}
+	if plain := h.anotations(table, Column{Name: "rank", DataType: "smallint"}); contains(plain, "@GeneratedValue(strategy=GenerationType.IDENTITY)") {
+		t.Errorf("unexpected @GeneratedValue: %v", plain)
+	}
+
+	col := Column{Name: "rank", DataType: "smallint", DefaultValue: sql.NullString{String: "1", Valid: true}}
+	if init, ok := h.initializer(col, "Short"); !ok || init != "(short) 1" {
+		t.Errorf("expected (short) 1, actual: %s", init)
+	}
+}
+
+func TestIdentityColumns(t *testing.T) {
+	h := Hibernate{}
+	table := Table{Name: "items"}
+	ff := []struct {
+		col      Column
+		expected []string
+	}{
+		{Column{Name: "id", DataType: "bigint", PrimaryKey: true, NotNull: true, IdentityKind: IdentityAlways},
+			[]string{"@GeneratedValue(strategy=GenerationType.IDENTITY)", `@Column(name="id", nullable=false, insertable=false, updatable=false)`}},
+		{Column{Name: "id", DataType: "bigint", PrimaryKey: true, NotNull: true, IdentityKind: IdentityByDefault},
+			[]string{"@GeneratedValue(strategy=GenerationType.IDENTITY)", `@Column(name="id", nullable=false)`}},
+		{Column{Name: "id", DataType: "bigint", PrimaryKey: true, NotNull: true},
+			[]string{`@Column(name="id", nullable=false)`}},
+	}
+	for _, f := range ff {
+		actual := h.anotations(table, f.col)
+		for _, e := range f.expected {
+			if !contains(actual, e) {
+				t.Errorf("%q: %s is missing: %v", f.col.IdentityKind, e, actual)
+			}
+		}
+		if f.col.IdentityKind == "" && contains(actual, "@GeneratedValue(strategy=GenerationType.IDENTITY)") {
+			t.Errorf("unexpected @GeneratedValue: %v", actual)
+		}
+	}
+}
+
+func TestPiiAnotations(t *testing.T) {
+	h := Hibernate{
+		config: HibernateConfig{
+			PiiColumns: []string{"users.email"},
+		},
+	}
+	table := Table{Name: "users"}
+	ff := []struct {
+		col      Column
+		expected bool
+	}{
+		{Column{Name: "email", DataType: "text"}, true},
+		{Column{Name: "phone", DataType: "text", Comment: sql.NullString{String: "phone number @pii", Valid: true}}, true},
+		{Column{Name: "name", DataType: "text"}, false},
+	}
+	for _, f := range ff {
+		actual := contains(h.anotations(table, f.col), "@Convert(converter = PiiConverter.class)")
+		if actual != f.expected {
+			t.Errorf("%s: expected %t, actual: %t", f.col.Name, f.expected, actual)
+		}
+	}
+
+	h.config.PiiConverter = "com.foo.Encrypted"
+	if !contains(h.anotations(table, ff[0].col), "@Convert(converter = com.foo.Encrypted.class)") {
+		t.Errorf("custom converter is not used: %v", h.anotations(table, ff[0].col))
+	}
+}
+
+func TestTemplateData(t *testing.T) {
+	h := Hibernate{
+		config: HibernateConfig{TemplateData: map[string]interface{}{"company": "Example Inc.", "table": "clobbered"}},
+	}
+	h.template = template.Must(template.ParseGlob("templates/hibernate/*.tmpl"))
+	template.Must(h.template.New("class").Parse(`// (c) {{ .extra.company }}
+class {{ .table.Name }} {{ .extra.table }}
+{{ range .accessor }}{{ . }}{{ end }}`))
+	template.Must(h.template.New("getter").Parse(`{{ .extra.company }}: {{ .name }}`))
+
+	var buf bytes.Buffer
+	table := Table{Name: "users", Columns: []Column{Column{Name: "id", DataType: "integer", PrimaryKey: true}}}
+	if err := h.buildTable(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "// (c) Example Inc.\nclass users clobbered\nExample Inc.: id"; !strings.HasPrefix(buf.String(), expected) {
+		t.Errorf("expected %q, actual: %q", expected, buf.String())
+	}
+}
+
+func TestDynamicUpdateInsert(t *testing.T) {
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer", PrimaryKey: true},
+		},
+	}
+
+	h := Hibernate{}
+	out := renderHibernateClass(t, &h, table)
+	if strings.Contains(out, "@DynamicUpdate\n") || strings.Contains(out, "@DynamicInsert\n") {
+		t.Errorf("unexpected annotation:\n%s", out)
+	}
+
+	h = Hibernate{
+		config: HibernateConfig{
+			DynamicUpdate: true,
+			DynamicInsert: true,
+		},
+	}
+	out = renderHibernateClass(t, &h, table)
+	if !strings.Contains(out, "@Entity\n@DynamicUpdate\n@DynamicInsert\n@Table") {
+		t.Errorf("annotations are missing:\n%s", out)
+	}
+}
+
+func TestImplementSerializable(t *testing.T) {
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer", PrimaryKey: true},
+			Column{Name: "name", DataType: "text"},
+		},
+	}
+	h := Hibernate{
+		config: HibernateConfig{ImplementSerializable: true},
+	}
+	out := renderHibernateClass(t, &h, table)
+	if !strings.Contains(out, "public class Users implements java.io.Serializable {") {
+		t.Errorf("Serializable is missing:\n%s", out)
+	}
+	uid := h.serialVersionUID(table)
+	if !strings.Contains(out, "private static final long serialVersionUID = "+uid+";") {
+		t.Errorf("serialVersionUID is missing:\n%s", out)
+	}
+	if uid != h.serialVersionUID(table) {
+		t.Error("serialVersionUID should be deterministic")
+	}
+
+	table.Columns = append(table.Columns, Column{Name: "email", DataType: "text"})
+	if uid == h.serialVersionUID(table) {
+		t.Error("serialVersionUID should change when fields change")
+	}
+}
+
+func TestPreferBigInteger(t *testing.T) {
+	h := Hibernate{
+		config: HibernateConfig{PreferBigInteger: true},
+	}
+	ff := [][]string{
+		[]string{"numeric(38,0)", "BigInteger"},
+		[]string{"numeric(38)", "BigInteger"},
+		[]string{"numeric(10,2)", "BigDecimal"},
+		[]string{"numeric(18,0)", "BigDecimal"},
+		[]string{"numeric", "BigDecimal"},
+	}
+	for _, d := range ff {
+		if actual := h.convertType(Column{DataType: d[0]}); actual != d[1] {
+			t.Errorf("%s: expected %s, actual: %s", d[0], d[1], actual)
+		}
+	}
+
+	h.config.PreferBigInteger = false
+	if actual := h.convertType(Column{DataType: "numeric(38,0)"}); actual != "BigDecimal" {
+		t.Errorf("expected BigDecimal, actual: %s", actual)
+	}
+}
+
+func TestTableCheck(t *testing.T) {
+	table := Table{
+		Name: "campaigns",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer", PrimaryKey: true},
+			Column{Name: "start_date", DataType: "date"},
+			Column{Name: "end_date", DataType: "date"},
+		},
+		TableChecks: []Check{Check{Expression: "start_date < end_date"}},
+	}
+
+	h := Hibernate{}
+	if out := renderHibernateClass(t, &h, table); strings.Contains(out, "@Check(") {
+		t.Errorf("unexpected annotation:\n%s", out)
+	}
+
+	h = Hibernate{config: HibernateConfig{GenerateCheck: true}}
+	out := renderHibernateClass(t, &h, table)
+	if !strings.Contains(out, `@Check(constraints = "start_date < end_date")`) {
+		t.Errorf("@Check is missing:\n%s", out)
+	}
+
+	table.TableChecks = []Check{Check{Name: "campaigns_check", Expression: "start_date < end_date"}}
+	out = renderHibernateClass(t, &h, table)
+	if !strings.Contains(out, " * CHECK campaigns_check: start_date < end_date\n") {
+		t.Errorf("CHECK comment is missing:\n%s", out)
+	}
+	if strings.Count(out, "@Check(") != 1 {
+		t.Errorf("expected a single @Check:\n%s", out)
+	}
+}
+
+func TestColumnTransformer(t *testing.T) {
+	h := Hibernate{
+		config: HibernateConfig{
+			ColumnTransformers: map[string]ColumnTransformer{
+				"users.ssn": ColumnTransformer{
+					Read:  `pgp_sym_decrypt(ssn, current_setting('app.key'))`,
+					Write: `pgp_sym_encrypt(?, "key")`,
+				},
+			},
+		},
+	}
+	table := Table{Name: "users"}
+	expected := `@ColumnTransformer(read = "pgp_sym_decrypt(ssn, current_setting('app.key'))", write = "pgp_sym_encrypt(?, \"key\")")`
+	if actual := h.anotations(table, Column{Name: "ssn", DataType: "bytea"}); !contains(actual, expected) {
+		t.Errorf("@ColumnTransformer is missing: %v", actual)
+	}
+	for _, a := range h.anotations(Table{Name: "admins"}, Column{Name: "ssn", DataType: "bytea"}) {
+		if strings.HasPrefix(a, "@ColumnTransformer") {
+			t.Errorf("unexpected annotation: %s", a)
+		}
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	h := Hibernate{
+		config: HibernateConfig{SoftDeleteColumn: "deleted_at"},
+	}
+	users := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer", PrimaryKey: true},
+			Column{Name: "deleted_at", DataType: "timestamp with time zone"},
+		},
+	}
+	out := renderHibernateClass(t, &h, users)
+	if !strings.Contains(out, `@Where(clause = "deleted_at IS NULL")`) {
+		t.Errorf("@Where is missing:\n%s", out)
+	}
+	if !strings.Contains(out, `@SQLDelete(sql = "UPDATE \"public\".\"users\" SET \"deleted_at\" = now() WHERE \"id\" = ?")`) {
+		t.Errorf("@SQLDelete is missing:\n%s", out)
+	}
+
+	logs := Table{
+		Name: "logs",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer", PrimaryKey: true},
+		},
+	}
+	if out := renderHibernateClass(t, &h, logs); strings.Contains(out, "@Where(") || strings.Contains(out, "@SQLDelete(") {
+		t.Errorf("unexpected annotation:\n%s", out)
+	}
+
+	h.config.SoftDeleteColumn = "is_deleted"
+	h.config.VersionFieldColumn = "version"
+	items := Table{
+		Schema: "catalog",
+		Name:   "items",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer", PrimaryKey: true},
+			Column{Name: "is_deleted", DataType: "boolean"},
+			Column{Name: "version", DataType: "integer"},
+		},
+	}
+	expected := []string{
+		`@Where(clause = "is_deleted = false")`,
+		`@SQLDelete(sql = "UPDATE \"catalog\".\"items\" SET \"is_deleted\" = true WHERE \"id\" = ? AND \"version\" = ?")`,
+	}
+	if actual := h.softDeleteAnotations(items); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+
+	// reserved words
+	h.config.SoftDeleteColumn = "deleted_at"
+	h.config.VersionFieldColumn = ""
+	user := Table{
+		Name: "user",
+		Columns: []Column{
+			Column{Name: "order", DataType: "integer", PrimaryKey: true},
+			Column{Name: "deleted_at", DataType: "timestamp with time zone"},
+		},
+	}
+	expected = []string{
+		`@Where(clause = "deleted_at IS NULL")`,
+		`@SQLDelete(sql = "UPDATE \"public\".\"user\" SET \"deleted_at\" = now() WHERE \"order\" = ?")`,
+	}
+	if actual := h.softDeleteAnotations(user); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+}
+
+func TestVersionStampHeader(t *testing.T) {
+	ins := InspectResult{
+		Tables: []Table{Table{Name: "users"}},
+	}
+	stamp := versionStamp(ins)
+	table := Table{Name: "users"}
+
+	h := Hibernate{}
+	if out := renderHibernateClass(t, &h, table); strings.Contains(out, "schema ") {
+		t.Errorf("unexpected stamp:\n%s", out)
+	}
+
+	h = Hibernate{opts: BuildOptions{Stamp: stamp}}
+	out := renderHibernateClass(t, &h, table)
+	if !strings.Contains(out, "// Generated by pg2any. DO NOT EDIT THIS FILE\n// "+stamp+"\n") {
+		t.Errorf("stamp is missing:\n%s", out)
+	}
+}
+
+func TestFormula(t *testing.T) {
+	h := Hibernate{
+		config: HibernateConfig{
+			Formulas: map[string][]FormulaDef{
+				"users": []FormulaDef{
+					FormulaDef{Name: "full_name", SQL: "first_name || ' ' || last_name", Type: "String"},
+				},
+			},
+		},
+	}
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer", PrimaryKey: true},
+			Column{Name: "first_name", DataType: "text"},
+			Column{Name: "last_name", DataType: "text"},
+		},
+	}
+	out := renderHibernateClass(t, &h, table)
+	for _, s := range []string{
+		"private String fullName;",
+		"    @Formula(\"first_name || ' ' || last_name\")\n    public String getFullName() {",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%s is missing:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "setFullName") {
+		t.Errorf("formula should be read only:\n%s", out)
+	}
+}
+
+func TestEnumValueComments(t *testing.T) {
+	h := Hibernate{
+		config: HibernateConfig{
+			PackageName: "com.example",
+			EnumValueComments: map[string]map[string]string{
+				"order_status": {"open": "waiting for payment"},
+			},
+		},
+		template: template.Must(template.ParseGlob("templates/hibernate/*.tmpl")),
+	}
+	typ := Type{Name: "order_status", Values: []string{"open", "closed"}}
+	var buf, ut bytes.Buffer
+	if err := h.buildType(&buf, &ut, typ); err != nil {
+		t.Fatal(err)
+	}
+	expected := "   /** waiting for payment */\n   OPEN(\"open\"),\n   CLOSED(\"closed\");"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in:\n%s", expected, buf.String())
+	}
+
+	h.config.EnumValueComments = nil
+	buf.Reset()
+	if err := h.buildType(&buf, &ut, typ); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `OPEN("open"), CLOSED("closed");`) {
+		t.Errorf("values without comments should be on one line:\n%s", buf.String())
+	}
+}
+
+func TestCacheableTables(t *testing.T) {
+	ff := []struct {
+		strategy string
+		table    string
+		expected []string
+	}{
+		{"", "countries", []string{"@Cacheable", "@Cache(usage = CacheConcurrencyStrategy.READ_WRITE)"}},
+		{"READ_ONLY", "countries", []string{"@Cacheable", "@Cache(usage = CacheConcurrencyStrategy.READ_ONLY)"}},
+		{"READ_ONLY", "orders", nil},
+		{"", "countries_audit", nil},
+		{"", "country_codes", []string{"@Cacheable", "@Cache(usage = CacheConcurrencyStrategy.READ_WRITE)"}},
+	}
+	for _, f := range ff {
+		h := Hibernate{
+			config: HibernateConfig{
+				CacheableTables: []string{"countries", "country_*"},
+				CacheStrategy:   f.strategy,
+			},
+		}
+		if actual := h.classAnotations(Table{Name: f.table}); !reflect.DeepEqual(actual, f.expected) {
+			t.Errorf("%s %s: expected %v, actual: %v", f.strategy, f.table, f.expected, actual)
+		}
+	}
+
+	if _, err := loadHibernateConfig(".", []byte(`{"output": ".", "cache_strategy": "READ_SOMETIMES"}`)); err == nil {
+		t.Error("unknown cache strategy should be error")
+	}
+	if _, err := loadHibernateConfig(".", []byte(`{"output": ".", "cacheable_tables": ["country_["]}`)); err == nil {
+		t.Error("invalid glob should be error")
+	}
+}
+
+func TestParseForignTable(t *testing.T) {
+	ff := [][]string{
+		[]string{"FOREIGN KEY (security_code) REFERENCES master_security(security_code)", "master_security", "security_code"},
+		[]string{"FOREIGN KEY (a, b) REFERENCES pairs(x, y) ON DELETE CASCADE", "pairs", "x"},
+		[]string{"CHECK ((price > 0))", "", ""},
+	}
+	for _, f := range ff {
+		table, column := parseForignTable(f[0])
+		if table != f[1] || column != f[2] {
+			t.Errorf("%s: expected %s(%s), actual: %s(%s)", f[0], f[1], f[2], table, column)
+		}
+	}
+}
+
+func TestForeignKeyRelation(t *testing.T) {
+	fk := func(src string) sql.NullString { return sql.NullString{String: src, Valid: true} }
+	h := Hibernate{
+		config: HibernateConfig{PackageName: "com.example"},
+		ins: InspectResult{Tables: []Table{
+			Table{Name: "users", Columns: []Column{Column{Name: "id", DataType: "integer", PrimaryKey: true}}},
+			Table{Name: "pairs", Columns: []Column{Column{Name: "x", DataType: "integer"}}},
+		}},
+	}
+	table := Table{
+		Name: "orders",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer", PrimaryKey: true},
+			Column{Name: "user_id", DataType: "integer", NotNull: true,
+				ForeignKeySrc: fk("FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE")},
+			Column{Name: "a", DataType: "integer", ForeignKeySrc: fk("FOREIGN KEY (a, b) REFERENCES pairs(x, y)")},
+			Column{Name: "b", DataType: "integer", ForeignKeySrc: fk("FOREIGN KEY (a, b) REFERENCES pairs(x, y)")},
+			Column{Name: "broken", DataType: "integer", ForeignKeySrc: fk("FOREIGN KEY broken")},
+			Column{Name: "ignored_id", DataType: "integer", ForeignKeySrc: fk("FOREIGN KEY (ignored_id) REFERENCES ignored(id)")},
+		},
+	}
+	out := renderHibernateClass(t, &h, table)
+	for _, s := range []string{
+		"private Users userId;",
+		"    @ManyToOne(fetch = FetchType.LAZY)\n" +
+			"    @JoinColumn(name=\"user_id\", referencedColumnName=\"id\", nullable=false)\n" +
+			"    @OnDelete(action = OnDeleteAction.CASCADE)\n" +
+			"    public Users getUserId() {",
+		"public void setUserId (Users arg)",
+		"private Pairs a;",
+		"    @JoinColumns({\n" +
+			"        @JoinColumn(name=\"a\", referencedColumnName=\"x\", nullable=true),\n" +
+			"        @JoinColumn(name=\"b\", referencedColumnName=\"y\")\n" +
+			"    })\n" +
+			"    public Pairs getA() {",
+		"    @Column(name=\"b\", nullable=true, insertable=false, updatable=false)\n    public Integer getB() {",
+		"    @Column(name=\"broken\", nullable=true)\n    public Integer getBroken() {",
+		"    @Column(name=\"ignored_id\", nullable=true)\n    public Integer getIgnoredId() {",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%s is missing:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, `@Column(name="user_id"`) {
+		t.Errorf("relation should not have @Column:\n%s", out)
+	}
+}
+
+func TestOneToOneRelation(t *testing.T) {
+	fk := func(src string) sql.NullString { return sql.NullString{String: src, Valid: true} }
+	h := Hibernate{
+		ins: InspectResult{Tables: []Table{
+			Table{Name: "users", Columns: []Column{Column{Name: "id", DataType: "integer", PrimaryKey: true}}},
+			Table{Name: "pairs", Columns: []Column{Column{Name: "x", DataType: "integer"}}},
+		}},
+	}
+	table := Table{
+		Name: "profiles",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer", PrimaryKey: true},
+			Column{Name: "user_id", DataType: "integer", Unique: true, ForeignKeySrc: fk("FOREIGN KEY (user_id) REFERENCES users(id)")},
+			Column{Name: "owner_id", DataType: "integer", ForeignKeySrc: fk("FOREIGN KEY (owner_id) REFERENCES users(id)")},
+			Column{Name: "a", DataType: "integer", ForeignKeySrc: fk("FOREIGN KEY (a, b) REFERENCES pairs(x, y)")},
+			Column{Name: "b", DataType: "integer", ForeignKeySrc: fk("FOREIGN KEY (a, b) REFERENCES pairs(x, y)")},
+		},
+		Indexs: []Index{Index{Unique: true, Columns: []Column{Column{Name: "b"}, Column{Name: "a"}}}},
+	}
+	out := renderHibernateClass(t, &h, table)
+	for _, s := range []string{
+		"    @OneToOne(fetch = FetchType.LAZY)\n    @JoinColumn(name=\"user_id\"",
+		"    @ManyToOne(fetch = FetchType.LAZY)\n    @JoinColumn(name=\"owner_id\"",
+		"    @OneToOne(fetch = FetchType.LAZY)\n    @JoinColumns({",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%s is missing:\n%s", s, out)
+		}
+	}
+}
+
+func TestGeneratedColumns(t *testing.T) {
+	h := Hibernate{
+		config: HibernateConfig{GeneratedColumns: []string{"users.created_at"}},
+	}
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "full_name", DataType: "text", Generated: true,
+				DefaultValue: sql.NullString{String: "((first_name || ' '::text) || last_name)", Valid: true}},
+			Column{Name: "created_at", DataType: "timestamp with time zone", NotNull: true,
+				DefaultValue: sql.NullString{String: "now()", Valid: true}},
+			Column{Name: "name", DataType: "text"},
+		},
+	}
+	ff := []struct {
+		col      Column
+		expected []string
+	}{
+		{table.Columns[0], []string{"@Generated(GenerationTime.ALWAYS)", `@Column(name="full_name", nullable=true, insertable=false, updatable=false)`}},
+		{table.Columns[1], []string{"@Generated(GenerationTime.INSERT)", `@ColumnDefault("now()")`, `@Column(name="created_at", nullable=false, insertable=false)`}},
+		{table.Columns[2], []string{`@Column(name="name", nullable=true)`}},
+	}
+	for _, f := range ff {
+		if actual := h.anotations(table, f.col); !reflect.DeepEqual(actual, f.expected) {
+			t.Errorf("%s: expected %v, actual: %v", f.col.Name, f.expected, actual)
+		}
+	}
+
+	out := renderHibernateClass(t, &h, table)
+	if !strings.Contains(out, "private void setFullName (String arg)") {
+		t.Errorf("setter of generated column should be private:\n%s", out)
+	}
+}
+
+func TestDomainType(t *testing.T) {
+	h := Hibernate{
+		ins: InspectResult{Domains: []Type{
+			Type{DataType: "d", Name: "email", BaseType: "character varying(255)", Check: sql.NullString{String: "CHECK (VALUE ~~ '%@%'::text)", Valid: true}},
+			Type{DataType: "d", Name: "work_email", BaseType: "email"},
+			Type{DataType: "d", Name: "price", BaseType: "numeric(38,0)"},
+		}},
+	}
+	ff := [][]string{
+		[]string{"email", "String"},
+		[]string{"email[]", "String"},
+		[]string{"work_email", "String"},
+		[]string{"price", "BigDecimal"},
+	}
+	for _, d := range ff {
+		if actual := h.convertType(Column{DataType: d[0]}); actual != d[1] {
+			t.Errorf("%s: expected %s, actual: %s", d[0], d[1], actual)
+		}
+	}
+	h.config.PreferBigInteger = true
+	if actual := h.convertType(Column{DataType: "price"}); actual != "BigInteger" {
+		t.Errorf("precision of the domain is not used: %s", actual)
+	}
+
+	m := h.members(Table{Name: "users", Columns: []Column{
+		Column{Name: "email", DataType: "email", Comment: sql.NullString{String: "login", Valid: true}},
+	}})
+	if expected := "login (domain email: CHECK (VALUE ~~ '%@%'::text))"; m[0].Comment != expected {
+		t.Errorf("expected %s, actual: %s", expected, m[0].Comment)
+	}
+}
+
+func TestCompositeType(t *testing.T) {
+	address := Type{DataType: "c", Name: "address", Attributes: []Column{
+		Column{Name: "street", DataType: "text"},
+		Column{Name: "zip", DataType: "integer", NotNull: true},
+	}}
+	// timestamps aren't read from row literals
+	period := Type{DataType: "c", Name: "period", Attributes: []Column{
+		Column{Name: "starts_at", DataType: "timestamp with time zone"},
+	}}
+	h := Hibernate{
+		config: HibernateConfig{Templates: "templates/hibernate", PackageName: "com.example"},
+		ins:    InspectResult{Composites: []Type{address, period}},
+		root:   ".",
+	}
+	if actual := h.convertType(Column{DataType: "address"}); actual != "Address" {
+		t.Errorf("expected Address, actual: %s", actual)
+	}
+	if actual := h.convertType(Column{DataType: "period"}); actual != "String" {
+		t.Errorf("expected String, actual: %s", actual)
+	}
+	if actual := h.fieldType(Table{}, Column{DataType: "address[]", Array: true}); actual != "Address[]" {
+		t.Errorf("expected Address[], actual: %s", actual)
+	}
+
+	h.ins.Tables = []Table{Table{Name: "shops", Columns: []Column{
+		Column{Name: "id", DataType: "bigint", PrimaryKey: true},
+		Column{Name: "address", DataType: "address"},
+		Column{Name: "open_period", DataType: "period"},
+	}}}
+	files := &recordingFiles{}
+	if err := h.Build(h.ins, BuildOptions{Files: files}); err != nil {
+		t.Fatal(err)
+	}
+	if problems := hibernateMappingProblems(files.files); len(problems) > 0 {
+		t.Errorf("entities are not mappable: %v", problems)
+	}
+	for file, ss := range map[string][]string{
+		"Address.java": []string{
+			"public class Address implements java.io.Serializable {",
+			"    public Integer getZip() {",
+		},
+		"AddressUserType.java": []string{
+			"public class AddressUserType implements UserType {",
+			"    value.setZip(s == null ? null : Integer.valueOf(s));",
+			`    pgobject.setType("address");`,
+			"    return Address.class;",
+		},
+		"Shops.java": []string{
+			"    @Type(type = \"com.example.AddressUserType\")\n    @Column(name=\"address\", nullable=true)\n    public Address getAddress() {",
+			"    @ColumnTransformer(write = \"?::period\")\n    @Column(name=\"open_period\", nullable=true)\n    public String getOpenPeriod() {",
+		},
+	} {
+		for _, s := range ss {
+			if !strings.Contains(files.files[file], s) {
+				t.Errorf("%s: %s is missing:\n%s", file, s, files.files[file])
+			}
+		}
+	}
+	if _, ok := files.files["PeriodUserType.java"]; ok {
+		t.Error("composite with timestamps should have no user type")
+	}
+}
+
+// TestHibernateGoldenMappable checks the entities of the golden files can be mapped.
+func TestHibernateGoldenMappable(t *testing.T) {
+	h := Hibernate{
+		config: HibernateConfig{Templates: "templates/hibernate", PackageName: "com.example.entity"},
+		root:   ".",
+	}
+	files := &recordingFiles{}
+	if err := h.Build(goldenFixture(), BuildOptions{Files: files}); err != nil {
+		t.Fatal(err)
+	}
+	if problems := hibernateMappingProblems(files.files); len(problems) > 0 {
+		t.Errorf("entities are not mappable: %v", problems)
+	}
+}
+
+var (
+	regJavaClass  = regexp.MustCompile(`(?m)^public (?:class|enum) (\w+)`)
+	regJavaGetter = regexp.MustCompile(`((?:\n    @.*)*)\n    public ([\w.\[\]<>]+) get\w+\(\) \{`)
+	regJavaType   = regexp.MustCompile(`@Type\(type = "(?:[\w.]+\.)?(\w+)"\)`)
+)
+
+// hibernateMappingProblems checks fields of entities in files, keyed by file name, the way
+// Hibernate 5 binds them: fields of @Embeddable classes are embedded, so they can't have
+// @Column and their attributes would be columns of the entity table, and fields of other
+// generated classes need @Type of a generated user type returning the class.
+func hibernateMappingProblems(files map[string]string) []string {
+	classes := map[string]string{}
+	for _, src := range files {
+		if m := regJavaClass.FindStringSubmatch(src); m != nil {
+			classes[m[1]] = src
+		}
+	}
+	var ret []string
+	for file, src := range files {
+		if !strings.Contains(src, "\n@Entity\n") {
+			continue
+		}
+		for _, m := range regJavaGetter.FindAllStringSubmatch(src, -1) {
+			annotations, typ := m[1], m[2]
+			class, ok := classes[typ]
+			if !ok || strings.Contains(class, "\n@Entity\n") {
+				// basic types and relations
+				continue
+			}
+			if strings.Contains(class, "\n@Embeddable\n") {
+				ret = append(ret, fmt.Sprintf("%s: %s is embedded", file, typ))
+				continue
+			}
+			t := regJavaType.FindStringSubmatch(annotations)
+			if t == nil {
+				ret = append(ret, fmt.Sprintf("%s: %s has no user type", file, typ))
+				continue
+			}
+			if ut, ok := classes[t[1]]; !ok || !strings.Contains(ut, "implements UserType") || !strings.Contains(ut, typ+".class") {
+				ret = append(ret, fmt.Sprintf("%s: %s is not a user type of %s", file, t[1], typ))
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func TestViews(t *testing.T) {
+	view := Table{
+		Name:   "active_users",
+		IsView: true,
+		Columns: []Column{
+			Column{Name: "id", DataType: "bigint"},
+			Column{Name: "name", DataType: "text"},
+		},
+	}
+	h := Hibernate{ins: InspectResult{Views: []Table{view}}}
+	if len(h.tables()) != 0 {
+		t.Errorf("views should be opt-in: %v", h.tables())
+	}
+	h.config.GenerateViews = true
+	if len(h.tables()) != 1 {
+		t.Errorf("view is missing: %v", h.tables())
+	}
+
+	out := renderHibernateClass(t, &h, view)
+	for _, s := range []string{
+		"@Entity\n@Immutable\n",
+		"public Long getId() {",
+		"private void setId (Long arg)",
+		"private void setName (String arg)",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%s is missing:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "public void set") {
+		t.Errorf("view should not have public setters:\n%s", out)
+	}
+}
+
+func TestColumnDefault(t *testing.T) {
+	def := func(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+	h := Hibernate{
+		config: HibernateConfig{PackageName: "com.example"},
+		ins:    InspectResult{Types: []Type{Type{Name: "user_status", Values: []string{"active", "banned"}}}},
+	}
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "bigint", PrimaryKey: true, DefaultValue: def("nextval('users_id_seq'::regclass)")},
+			Column{Name: "status", DataType: "user_status", DefaultValue: def("'active'::user_status")},
+			Column{Name: "note", DataType: "text", DefaultValue: def("'it''s'::text")},
+			Column{Name: "score", DataType: "integer", DefaultValue: def("0")},
+			Column{Name: "total", DataType: "bigint", DefaultValue: def("'-1'::integer")},
+			Column{Name: "rate", DataType: "numeric(5,2)", DefaultValue: def("1.50")},
+			Column{Name: "enabled", DataType: "boolean", DefaultValue: def("true")},
+			Column{Name: "created_at", DataType: "timestamp with time zone", DefaultValue: def("now()")},
+			Column{Name: "name", DataType: "text"},
+		},
+	}
+	out := renderHibernateClass(t, &h, table)
+	for _, s := range []string{
+		"private Long id; //",
+		"private UserStatus status = UserStatus.ACTIVE; //",
+		`private String note = "it's"; //`,
+		"private Integer score = 0; //",
+		"private Long total = -1L; //",
+		`private BigDecimal rate = new BigDecimal("1.50"); //`,
+		"private Boolean enabled = true; //",
+		"private OffsetDateTime createdAt; //",
+		"private String name; //",
+		"    @ColumnDefault(\"'active'::user_status\")\n",
+		"    @ColumnDefault(\"now()\")\n",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%s is missing:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "nextval") {
+		t.Errorf("sequence should not be a column default:\n%s", out)
+	}
+}
+
+func TestTypeRegistry(t *testing.T) {
+	ins := InspectResult{
+		Types: []Type{
+			Type{DataType: "e", Name: "user_status", Values: []string{"active", "banned"}},
+			Type{DataType: "e", Name: "order_status", Values: []string{"open", "closed"}},
+		},
+	}
+	for _, registry := range []bool{false, true} {
+		dir := t.TempDir()
+		h := Hibernate{
+			config: HibernateConfig{Output: dir, Templates: "templates/hibernate", PackageName: "com.example", GenerateTypeRegistry: registry},
+			root:   ".",
+		}
+		if err := h.Build(ins, BuildOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, hibernateTypeRegistryFileName))
+		if !registry {
+			if err == nil {
+				t.Errorf("unexpected %s:\n%s", hibernateTypeRegistryFileName, b)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := string(b)
+		for _, s := range []string{
+			`@TypeDef(name = "user_status", typeClass = UserStatusUserType.class)`,
+			`@TypeDef(name = "order_status", typeClass = OrderStatusUserType.class)`,
+		} {
+			if n := strings.Count(out, s); n != 1 {
+				t.Errorf("%s appears %d times:\n%s", s, n, out)
+			}
+		}
+		if !strings.Contains(out, "})\npackage com.example;\n") {
+			t.Errorf("package is missing:\n%s", out)
+		}
+	}
+}
+
+func TestOverwrites(t *testing.T) {
+	dir := t.TempDir()
+	const edited = "// edited by hand\n"
+	for _, name := range []string{"Users.java", "Orders.java", "UserStatusUserType.java"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(edited), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ins := InspectResult{
+		Tables: []Table{
+			Table{Name: "users", Columns: []Column{Column{Name: "id", DataType: "bigint", PrimaryKey: true}}},
+			Table{Name: "orders", Columns: []Column{Column{Name: "id", DataType: "bigint", PrimaryKey: true}}},
+		},
+		Types: []Type{Type{DataType: "e", Name: "user_status", Values: []string{"active"}}},
+	}
+	h := Hibernate{
+		config: HibernateConfig{Output: dir, Templates: "templates/hibernate", PackageName: "com.example", Overwrites: []string{"Users.java", "UserStatus.java"}},
+		root:   ".",
+	}
+	if err := h.Build(ins, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for name, kept := range map[string]bool{
+		"Users.java":              false, // listed
+		"UserStatus.java":         false, // listed, created
+		"Orders.java":             true,
+		"UserStatusUserType.java": true, // written with UserStatus.java, but not listed
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := string(b) == edited; actual != kept {
+			t.Errorf("%s: expected kept %t, actual: %t", name, kept, actual)
+		}
+	}
+}
+
+func TestStableOutput(t *testing.T) {
+	dir := t.TempDir()
+	h := Hibernate{
+		config: HibernateConfig{Output: dir, Templates: "templates/hibernate", PackageName: "com.example", StableOutput: true},
+		root:   ".",
+	}
+	table := Table{Name: "users", Columns: []Column{Column{Name: "id", DataType: "bigint", PrimaryKey: true}}}
+	if err := h.Build(InspectResult{Tables: []Table{table}}, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "Users.java")
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(file, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Build(InspectResult{Tables: []Table{table}}, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(past) {
+		t.Errorf("unchanged file should not be written: %s", info.ModTime())
+	}
+
+	table.Columns = append(table.Columns, Column{Name: "name", DataType: "text"})
+	if err := h.Build(InspectResult{Tables: []Table{table}}, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "private String name;") {
+		t.Errorf("changed file should be written:\n%s", b)
+	}
+}
+
+func TestHibernateConcurrency(t *testing.T) {
+	var ins InspectResult
+	for i := 0; i < 50; i++ {
+		ins.Tables = append(ins.Tables, Table{Name: fmt.Sprintf("table_%d", i), Columns: []Column{Column{Name: "id", DataType: "bigint", PrimaryKey: true}}})
+	}
+	var expected *recordingFiles
+	for _, concurrency := range []int{1, 8} {
+		h := Hibernate{
+			config: HibernateConfig{Templates: "templates/hibernate", PackageName: "com.example", GenerateMetamodel: true, Concurrency: concurrency},
+			root:   ".",
+		}
+		files := &recordingFiles{}
+		if err := h.Build(ins, BuildOptions{Files: files}); err != nil {
+			t.Fatal(err)
+		}
+		if expected == nil {
+			expected = files
+			continue
+		}
+		if !reflect.DeepEqual(files.paths, expected.paths) || !reflect.DeepEqual(files.files, expected.files) {
+			t.Errorf("concurrency %d should write the same files in the same order", concurrency)
+		}
+	}
+}
+
+// BenchmarkHibernateBuild compares building entities one by one and with a worker per CPU.
+func BenchmarkHibernateBuild(b *testing.B) {
+	var ins InspectResult
+	for i := 0; i < 400; i++ {
+		table := Table{Name: fmt.Sprintf("table_%d", i)}
+		for j := 0; j < 20; j++ {
+			table.Columns = append(table.Columns, Column{Name: fmt.Sprintf("column_%d", j), DataType: "text", PrimaryKey: j == 0})
+		}
+		ins.Tables = append(ins.Tables, table)
+	}
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	for _, concurrency := range []int{1, 0} {
+		name := "concurrency=GOMAXPROCS"
+		if concurrency == 1 {
+			name = "concurrency=1"
+		}
+		b.Run(name, func(b *testing.B) {
+			h := Hibernate{
+				config: HibernateConfig{Templates: "templates/hibernate", PackageName: "com.example", Concurrency: concurrency},
+				root:   ".",
+			}
+			for i := 0; i < b.N; i++ {
+				if err := h.Build(ins, BuildOptions{Files: &recordingFiles{}}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCompositePrimaryKey(t *testing.T) {
+	ins := InspectResult{
+		Tables: []Table{
+			Table{Name: "users", Columns: []Column{Column{Name: "id", DataType: "bigint", PrimaryKey: true}}},
+			Table{Name: "tenant_orders", Columns: []Column{
+				Column{Name: "tenant_id", DataType: "bigint", PrimaryKey: true},
+				Column{Name: "order_id", DataType: "integer", PrimaryKey: true},
+				Column{Name: "total", DataType: "numeric"},
+			}},
+		},
+	}
+	h := Hibernate{
+		config: HibernateConfig{Templates: "templates/hibernate", PackageName: "com.example"},
+		root:   ".",
+	}
+	files := &recordingFiles{}
+	if err := h.Build(ins, BuildOptions{Files: files}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(files.files["Users.java"], "@IdClass") {
+		t.Errorf("single primary key should keep @Id only:\n%s", files.files["Users.java"])
+	}
+	if _, ok := files.files["UsersId.java"]; ok {
+		t.Error("single primary key should have no id class")
+	}
+	entity := files.files["TenantOrders.java"]
+	if !strings.Contains(entity, "@Entity\n@IdClass(TenantOrdersId.class)\n@Table") || strings.Count(entity, "@Id\n") != 2 {
+		t.Errorf("unexpected entity:\n%s", entity)
+	}
+	id := files.files["TenantOrdersId.java"]
+	for _, s := range []string{
+		"public class TenantOrdersId implements java.io.Serializable {\n\tprivate Long tenantId;\n\tprivate Integer orderId;\n",
+		"public Integer getOrderId() {",
+		"return Objects.equals(this.tenantId, that.tenantId)\n            && Objects.equals(this.orderId, that.orderId);",
+		"return Objects.hash(this.tenantId, this.orderId);",
+	} {
+		if !strings.Contains(id, s) {
+			t.Errorf("expected %q:\n%s", s, id)
+		}
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	h := Hibernate{config: HibernateConfig{PackageName: "com.example"}}
+	table := Table{
+		Name: "memberships",
+		Columns: []Column{
+			Column{Name: "id", DataType: "bigint", PrimaryKey: true},
+			Column{Name: "tenant_id", DataType: "bigint"},
+			Column{Name: "user_id", DataType: "bigint"},
+		},
+		Indexs: []Index{
+			Index{Name: "memberships_tenant_id_user_id_key", Unique: true, Columns: []Column{Column{Name: "tenant_id"}, Column{Name: "user_id"}}},
+			Index{Name: "memberships_user_id_idx", Columns: []Column{Column{Name: "user_id"}}},
+		},
+	}
+	actual := renderHibernateClass(t, &h, table)
+	for _, expected := range []string{
+		`@UniqueConstraint(name = "memberships_tenant_id_user_id_key", columnNames = {`,
+		`    ,indexes = {
+      @Index(name = "memberships_user_id_idx", columnList = "user_id"),
+    }
+)`,
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("expected %s:\n%s", expected, actual)
+		}
+	}
+	// unique indexes are only written as @UniqueConstraint
+	if strings.Contains(actual, `@Index(name = "memberships_tenant_id_user_id_key"`) {
+		t.Errorf("unique index is written twice:\n%s", actual)
+	}
+}
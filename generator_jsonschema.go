@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type JSONSchemaConfig struct {
+	Output       string   `json:"output"`
+	IgnoreTables []string `json:"ignore_tables"`
+	// IgnoreColumns are columns ("column" or "table.column") left out of properties
+	IgnoreColumns []string `json:"ignore_columns"`
+	// SkipEmptyTables skips tables without columns, which may exist while migrating
+	SkipEmptyTables bool `json:"skip_empty_tables"`
+	// StableOutput leaves files whose content is unchanged, ignoring generated timestamps
+	StableOutput bool `json:"stable_output"`
+	// IncludeTables restricts generation to tables matching the glob patterns like order_*, before IgnoreTables
+	IncludeTables []string `json:"include_tables"`
+}
+
+// JSONSchema writes documents with encoding/json instead of templates, so they are always valid JSON.
+type JSONSchema struct {
+	db     *sql.DB
+	config JSONSchemaConfig
+	ins    InspectResult
+	root   string
+	opts   BuildOptions
+}
+
+// JSONSchemaDocument is the schema of the objects of a table.
+type JSONSchemaDocument struct {
+	Schema      string               `json:"$schema"`
+	Comment     string               `json:"$comment,omitempty"`
+	Title       string               `json:"title"`
+	Description string               `json:"description,omitempty"`
+	Type        string               `json:"type"`
+	Properties  JSONSchemaProperties `json:"properties"`
+	Required    []string             `json:"required,omitempty"`
+}
+
+// JSONSchemaProperty is the schema of a column. Type is a type name, or a list of type names
+// with "null" for nullable columns. The empty schema accepts any value.
+type JSONSchemaProperty struct {
+	Type            interface{}         `json:"type,omitempty"`
+	Format          string              `json:"format,omitempty"`
+	ContentEncoding string              `json:"contentEncoding,omitempty"`
+	Description     string              `json:"description,omitempty"`
+	Enum            []interface{}       `json:"enum,omitempty"`
+	Items           *JSONSchemaProperty `json:"items,omitempty"`
+}
+
+type JSONSchemaNamedProperty struct {
+	Name     string
+	Property JSONSchemaProperty
+}
+
+// JSONSchemaProperties are written as an object in order of columns.
+type JSONSchemaProperties []JSONSchemaNamedProperty
+
+const JSONSchemaTypeName = "jsonschema"
+
+const jsonSchemaDraft07 = "http://json-schema.org/draft-07/schema#"
+
+func (props JSONSchemaProperties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, p := range props {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(p.Name)
+		if err != nil {
+			return nil, err
+		}
+		prop, err := json.Marshal(p.Property)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(prop)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func NewJSONSchema(db *sql.DB, root string, raw json.RawMessage) (Generator, error) {
+	config, err := loadJSONSchemaConfig(root, raw)
+	if err != nil {
+		return nil, err
+	}
+	ret := JSONSchema{
+		db:     db,
+		config: config,
+		root:   root,
+	}
+
+	return &ret, nil
+}
+
+func (gen *JSONSchema) GetType() string {
+	return JSONSchemaTypeName
+}
+
+func (gen *JSONSchema) Build(ins InspectResult, opts BuildOptions) error {
+	log.Printf("output: %s", opts.outputDir(gen.root, gen.config.Output))
+	gen.ins = ins
+	gen.opts = opts
+	opts = opts.stableOutput(gen.config.StableOutput)
+
+	// Build tables and row types of functions
+	for _, table := range gen.ins.TablesAndFunctions() {
+		if skipTable(gen.config.IncludeTables, gen.config.IgnoreTables, table.Name) {
+			continue
+		}
+		if len(table.Columns) == 0 && gen.config.SkipEmptyTables {
+			log.Printf("skip %s: no columns", table.Name)
+			continue
+		}
+		fileName := table.Name + ".json"
+		if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), fileName), func(wr io.Writer) error {
+			return gen.buildTable(wr, table)
+		}); err != nil {
+			return errors.Wrap(err, "build write table")
+		}
+	}
+
+	return nil
+}
+
+func (gen *JSONSchema) buildTable(wr io.Writer, table Table) error {
+	comment := "Generated by pg2any. DO NOT EDIT THIS FILE"
+	if gen.opts.Stamp != "" {
+		comment += ". " + gen.opts.Stamp
+	}
+	doc := JSONSchemaDocument{
+		Schema:      jsonSchemaDraft07,
+		Comment:     comment,
+		Title:       SnakeToUpperCamel(table.Name),
+		Description: strings.Replace(table.Comment.String, "\n", " ", -1),
+		Type:        "object",
+		Properties:  JSONSchemaProperties{},
+	}
+	for _, col := range table.Columns {
+		if containsColumn(gen.config.IgnoreColumns, table.Name, col.Name) {
+			continue
+		}
+		name := SnakeToLowerCamel(col.Name)
+		prop := gen.convertType(col)
+		if !col.NotNull {
+			prop = jsonSchemaNullable(prop)
+		}
+		prop.Description = strings.Replace(col.Comment.String, "\n", " ", -1)
+		doc.Properties = append(doc.Properties, JSONSchemaNamedProperty{Name: name, Property: prop})
+		if col.NotNull {
+			doc.Required = append(doc.Required, name)
+		}
+	}
+
+	buf, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshal "+table.Name)
+	}
+	_, err = wr.Write(append(buf, '\n'))
+	return err
+}
+
+// jsonSchemaNullable returns prop also accepting null. The empty schema already accepts null.
+func jsonSchemaNullable(prop JSONSchemaProperty) JSONSchemaProperty {
+	if typ, ok := prop.Type.(string); ok {
+		prop.Type = []string{typ, "null"}
+	}
+	if prop.Enum != nil {
+		prop.Enum = append(prop.Enum, nil)
+	}
+	return prop
+}
+
+func (gen *JSONSchema) convertType(col Column) JSONSchemaProperty {
+	if strings.HasSuffix(col.DataType, "[]") {
+		col.DataType = strings.Replace(col.DataType, "[]", "", 1)
+		items := gen.convertType(col)
+		return JSONSchemaProperty{Type: "array", Items: &items}
+	}
+
+	switch col.DataType {
+	case "text", "citext":
+		return JSONSchemaProperty{Type: "string"}
+	case "uuid":
+		return JSONSchemaProperty{Type: "string", Format: "uuid"}
+	case "smallint", "int", "integer", "bigint", "smallserial", "serial", "bigserial":
+		return JSONSchemaProperty{Type: "integer"}
+	case "numeric", "real", "float", "double", "double precision":
+		return JSONSchemaProperty{Type: "number"}
+	case "boolean":
+		return JSONSchemaProperty{Type: "boolean"}
+	case "date":
+		return JSONSchemaProperty{Type: "string", Format: "date"}
+	case "time", "time without time zone", "time with time zone":
+		return JSONSchemaProperty{Type: "string", Format: "time"}
+	case "interval":
+		// ISO 8601 durations like "P1DT2H", which PostgreSQL writes with IntervalStyle iso_8601.
+		// duration is a format of draft 2019-09, draft-07 validators ignore it as unknown.
+		return JSONSchemaProperty{Type: "string", Format: "duration"}
+	case "json", "jsonb":
+		// any JSON value
+		return JSONSchemaProperty{}
+	case "bytea":
+		return JSONSchemaProperty{Type: "string", ContentEncoding: "base64"}
+	case "int2vector", "oidvector":
+		// space separated numbers like "1 2"
+		return JSONSchemaProperty{Type: "string"}
+	default:
+		// "timestamp", "timestamp with time zone", "timestamp(n) without time zone"
+		if strings.HasPrefix(col.DataType, "timestamp") {
+			return JSONSchemaProperty{Type: "string", Format: "date-time"}
+		}
+		if strings.HasPrefix(col.DataType, "numeric") {
+			return JSONSchemaProperty{Type: "number"}
+		}
+		if strings.HasPrefix(col.DataType, "character") {
+			return JSONSchemaProperty{Type: "string"}
+		}
+
+		typ, err := gen.ins.FindType(col.DataType)
+		if err == nil {
+			enum := make([]interface{}, len(typ.Values))
+			for i, v := range typ.Values {
+				enum[i] = v
+			}
+			return JSONSchemaProperty{Type: "string", Enum: enum}
+		}
+		if dom, err := gen.ins.FindDomain(col.DataType); err == nil {
+			col.DataType = dom.BaseType
+			return gen.convertType(col)
+		}
+	}
+	// unknown types are not validated
+	return JSONSchemaProperty{}
+}
+
+func loadJSONSchemaConfig(root string, raw json.RawMessage) (JSONSchemaConfig, error) {
+	var jc JSONSchemaConfig
+	if err := json.Unmarshal(raw, &jc); err != nil {
+		return jc, fmt.Errorf("jsonschema config error: %s", err)
+	}
+	if err := applyEnvOverrides(JSONSchemaTypeName, &jc); err != nil {
+		return jc, fmt.Errorf("jsonschema config error: %s", err)
+	}
+	output := filePathJoinRoot(root, jc.Output)
+	if err := DirExists(output); err != nil {
+		return jc, fmt.Errorf("jsonschema output is not exists: %s", jc.Output)
+	}
+	if err := checkGlobs(jc.IncludeTables); err != nil {
+		return jc, fmt.Errorf("jsonschema include_tables: %s", err)
+	}
+	if err := checkIgnores(jc.IgnoreTables); err != nil {
+		return jc, fmt.Errorf("jsonschema ignore_tables: %s", err)
+	}
+	return jc, nil
+}
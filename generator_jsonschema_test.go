@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONSchemaConvertType(t *testing.T) {
+	gen := JSONSchema{
+		ins: InspectResult{
+			Types:   []Type{Type{Name: "order_status", Values: []string{"open", "closed"}}},
+			Domains: []Type{Type{Name: "email", BaseType: "character varying(255)"}},
+		},
+	}
+	str := &JSONSchemaProperty{Type: "string"}
+	ff := []struct {
+		dataType string
+		expected JSONSchemaProperty
+	}{
+		{"text", JSONSchemaProperty{Type: "string"}},
+		{"uuid", JSONSchemaProperty{Type: "string", Format: "uuid"}},
+		{"bigint", JSONSchemaProperty{Type: "integer"}},
+		{"numeric(10,2)", JSONSchemaProperty{Type: "number"}},
+		{"boolean", JSONSchemaProperty{Type: "boolean"}},
+		{"date", JSONSchemaProperty{Type: "string", Format: "date"}},
+		{"timestamp with time zone", JSONSchemaProperty{Type: "string", Format: "date-time"}},
+		{"interval", JSONSchemaProperty{Type: "string", Format: "duration"}},
+		{"bytea", JSONSchemaProperty{Type: "string", ContentEncoding: "base64"}},
+		{"int2vector", JSONSchemaProperty{Type: "string"}},
+		{"jsonb", JSONSchemaProperty{}},
+		{"text[]", JSONSchemaProperty{Type: "array", Items: str}},
+		{"order_status", JSONSchemaProperty{Type: "string", Enum: []interface{}{"open", "closed"}}},
+		{"order_status[]", JSONSchemaProperty{Type: "array", Items: &JSONSchemaProperty{Type: "string", Enum: []interface{}{"open", "closed"}}}},
+		{"email", JSONSchemaProperty{Type: "string"}},
+		{"fooBar", JSONSchemaProperty{}},
+	}
+	for _, f := range ff {
+		if actual := gen.convertType(Column{DataType: f.dataType}); !reflect.DeepEqual(actual, f.expected) {
+			t.Errorf("%s: expected %+v, actual: %+v", f.dataType, f.expected, actual)
+		}
+	}
+}
+
+func TestJSONSchemaTable(t *testing.T) {
+	gen := JSONSchema{
+		config: JSONSchemaConfig{IgnoreColumns: []string{"users.password_hash"}},
+		ins: InspectResult{
+			Types: []Type{Type{Name: "user_status", Values: []string{"active", "banned"}}},
+		},
+	}
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "user_id", DataType: "uuid", NotNull: true},
+			Column{Name: "display_name", DataType: "text"},
+			Column{Name: "status", DataType: "user_status"},
+			Column{Name: "password_hash", DataType: "text", NotNull: true},
+		},
+	}
+	var buf bytes.Buffer
+	if err := gen.buildTable(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Schema     string                     `json:"$schema"`
+		Type       string                     `json:"type"`
+		Properties map[string]json.RawMessage `json:"properties"`
+		Required   []string                   `json:"required"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("%s:\n%s", err, buf.String())
+	}
+	if doc.Schema != jsonSchemaDraft07 || doc.Type != "object" {
+		t.Errorf("unexpected document: %s", buf.String())
+	}
+	if !reflect.DeepEqual(doc.Required, []string{"userId"}) {
+		t.Errorf("required: %v", doc.Required)
+	}
+	ff := map[string]string{
+		"userId":      `{"type":"string","format":"uuid"}`,
+		"displayName": `{"type":["string","null"]}`,
+		"status":      `{"type":["string","null"],"enum":["active","banned",null]}`,
+	}
+	for name, expected := range ff {
+		var actual bytes.Buffer
+		if err := json.Compact(&actual, doc.Properties[name]); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if actual.String() != expected {
+			t.Errorf("%s: expected %s, actual: %s", name, expected, actual.String())
+		}
+	}
+	if _, ok := doc.Properties["passwordHash"]; ok {
+		t.Errorf("ignored column is generated:\n%s", buf.String())
+	}
+	if strings.Index(buf.String(), `"userId"`) > strings.Index(buf.String(), `"displayName"`) {
+		t.Errorf("properties should be in order of columns:\n%s", buf.String())
+	}
+}
+
+func TestJSONSchemaIntervalsAndEnumArrays(t *testing.T) {
+	gen := JSONSchema{
+		ins: InspectResult{
+			Types: []Type{Type{Name: "job_state", Values: []string{"idle", "running"}}},
+		},
+	}
+	table := Table{
+		Name: "jobs",
+		Columns: []Column{
+			Column{Name: "run_every", DataType: "interval", NotNull: true},
+			Column{Name: "timeout", DataType: "interval"},
+			Column{Name: "history", DataType: "job_state[]", NotNull: true},
+		},
+	}
+	var buf bytes.Buffer
+	if err := gen.buildTable(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("%s:\n%s", err, buf.String())
+	}
+	ff := map[string]string{
+		"runEvery": `{"type":"string","format":"duration"}`,
+		"timeout":  `{"type":["string","null"],"format":"duration"}`,
+		"history":  `{"type":"array","items":{"type":"string","enum":["idle","running"]}}`,
+	}
+	for name, expected := range ff {
+		var actual bytes.Buffer
+		if err := json.Compact(&actual, doc.Properties[name]); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if actual.String() != expected {
+			t.Errorf("%s: expected %s, actual: %s", name, expected, actual.String())
+		}
+	}
+}
+
+// checkDraft07Schema checks the keywords written by the generator against the rules of the
+// draft-07 meta-schema.
+func checkDraft07Schema(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	schema, ok := v.(map[string]interface{})
+	if !ok {
+		t.Errorf("%s: schema must be an object: %v", path, v)
+		return
+	}
+	simpleTypes := []string{"array", "boolean", "integer", "null", "number", "object", "string"}
+	for key, val := range schema {
+		switch key {
+		case "$schema", "$comment", "title", "description", "format", "contentEncoding":
+			if _, ok := val.(string); !ok {
+				t.Errorf("%s.%s must be a string: %v", path, key, val)
+			}
+		case "type":
+			types, ok := val.([]interface{})
+			if !ok {
+				types = []interface{}{val}
+			}
+			seen := map[interface{}]bool{}
+			for _, typ := range types {
+				if s, ok := typ.(string); !ok || !contains(simpleTypes, s) || seen[typ] {
+					t.Errorf("%s.type is not unique simple types: %v", path, val)
+				}
+				seen[typ] = true
+			}
+		case "enum":
+			if _, ok := val.([]interface{}); !ok {
+				t.Errorf("%s.enum must be an array: %v", path, val)
+			}
+		case "required":
+			names, ok := val.([]interface{})
+			if !ok {
+				t.Errorf("%s.required must be an array: %v", path, val)
+			}
+			seen := map[interface{}]bool{}
+			for _, name := range names {
+				if _, ok := name.(string); !ok || seen[name] {
+					t.Errorf("%s.required is not unique strings: %v", path, val)
+				}
+				seen[name] = true
+			}
+		case "properties":
+			props, ok := val.(map[string]interface{})
+			if !ok {
+				t.Errorf("%s.properties must be an object: %v", path, val)
+			}
+			for name, prop := range props {
+				checkDraft07Schema(t, path+".properties."+name, prop)
+			}
+		case "items":
+			checkDraft07Schema(t, path+".items", val)
+		default:
+			t.Errorf("%s: unexpected keyword %s", path, key)
+		}
+	}
+}
+
+func TestJSONSchemaMetaSchema(t *testing.T) {
+	ins := goldenFixture()
+	gen := JSONSchema{ins: ins}
+	for _, table := range ins.Tables {
+		var buf bytes.Buffer
+		if err := gen.buildTable(&buf, table); err != nil {
+			t.Fatal(err)
+		}
+		var doc interface{}
+		if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+			t.Fatalf("%s: %s", table.Name, err)
+		}
+		checkDraft07Schema(t, table.Name, doc)
+	}
+}
@@ -0,0 +1,545 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type KotlinConfig struct {
+	Output       string   `json:"output"`
+	Templates    string   `json:"templates"`
+	PackageName  string   `json:"package_name"`
+	IgnoreTables []string `json:"ignore_tables"`
+	// IgnoreColumns are columns ("column" or "table.column") left out of data classes
+	IgnoreColumns []string `json:"ignore_columns"`
+	// SkipEmptyTables skips tables without columns, which may exist while migrating
+	SkipEmptyTables bool `json:"skip_empty_tables"`
+	// StableOutput leaves files whose content is unchanged, ignoring generated timestamps
+	StableOutput bool `json:"stable_output"`
+	// IncludeTables restricts generation to tables matching the glob patterns like order_*, before IgnoreTables
+	IncludeTables []string `json:"include_tables"`
+	// JsonUnions maps json/jsonb columns ("table.column") to tagged unions, written as sealed classes
+	JsonUnions map[string]UnionDef `json:"json_unions"`
+	// Exposed also writes JetBrains Exposed DSL table objects like object UsersTable : Table("users")
+	Exposed bool `json:"exposed"`
+}
+
+type Kotlin struct {
+	db       *sql.DB
+	config   KotlinConfig
+	ins      InspectResult
+	template *template.Template
+	root     string
+	opts     BuildOptions
+}
+
+type KotlinMember struct {
+	Name    string
+	Type    string
+	Comment string
+}
+
+// KotlinExposedColumn is a column of an Exposed table object, declared by Builder like
+// varchar("name", 50).nullable().
+type KotlinExposedColumn struct {
+	Name    string
+	Builder string
+	Comment string
+}
+
+// KotlinUnion is a sealed class of a json/jsonb column, with a subclass wrapping each variant.
+type KotlinUnion struct {
+	Name          string
+	Discriminator string
+	Variants      []KotlinVariant
+}
+
+type KotlinVariant struct {
+	Name    string
+	Type    string
+	Comment string
+}
+
+type KotlinEnumValue struct {
+	Name  string
+	Value string
+}
+
+const KotlinTypeName = "kotlin"
+
+// kotlinImports are imports of Kotlin types not in the default imports.
+var kotlinImports = map[string]string{
+	"BigDecimal":     "java.math.BigDecimal",
+	"Duration":       "java.time.Duration",
+	"LocalDate":      "java.time.LocalDate",
+	"LocalDateTime":  "java.time.LocalDateTime",
+	"LocalTime":      "java.time.LocalTime",
+	"OffsetDateTime": "java.time.OffsetDateTime",
+	"OffsetTime":     "java.time.OffsetTime",
+	"UUID":           "java.util.UUID",
+}
+
+// kotlinExposedImports are imports of Exposed column builders of exposed-java-time.
+var kotlinExposedImports = map[string]string{
+	"date":                  "org.jetbrains.exposed.sql.javatime.date",
+	"datetime":              "org.jetbrains.exposed.sql.javatime.datetime",
+	"duration":              "org.jetbrains.exposed.sql.javatime.duration",
+	"time":                  "org.jetbrains.exposed.sql.javatime.time",
+	"timestampWithTimeZone": "org.jetbrains.exposed.sql.javatime.timestampWithTimeZone",
+}
+
+// kotlinKeywords are hard keywords, which can only be names in backticks.
+var kotlinKeywords = []string{
+	"as", "break", "class", "continue", "do", "else", "false", "for", "fun", "if", "in",
+	"interface", "is", "null", "object", "package", "return", "super", "this", "throw",
+	"true", "try", "typealias", "typeof", "val", "var", "when", "while",
+}
+
+func NewKotlin(db *sql.DB, root string, raw json.RawMessage) (Generator, error) {
+	config, err := loadKotlinConfig(root, raw)
+	if err != nil {
+		return nil, err
+	}
+	ret := Kotlin{
+		db:     db,
+		config: config,
+		root:   root,
+	}
+
+	return &ret, nil
+}
+
+func (gen *Kotlin) GetType() string {
+	return KotlinTypeName
+}
+
+func (gen *Kotlin) Build(ins InspectResult, opts BuildOptions) error {
+	log.Printf("output: %s", opts.outputDir(gen.root, gen.config.Output))
+	log.Printf("templates: %s", filePathJoinRoot(gen.root, gen.config.Templates))
+	gen.ins = ins
+	gen.opts = opts
+	opts = opts.stableOutput(gen.config.StableOutput)
+
+	// Load templates
+	tdir := filepath.Join(filePathJoinRoot(gen.root, gen.config.Templates), "*.tmpl")
+	t := template.Must(template.New("").Funcs(templateFuncs()).ParseGlob(tdir))
+	gen.template = t
+
+	// Build tables and row types of functions
+	for _, table := range gen.ins.TablesAndFunctions() {
+		if skipTable(gen.config.IncludeTables, gen.config.IgnoreTables, table.Name) {
+			continue
+		}
+		if len(table.Columns) == 0 && gen.config.SkipEmptyTables {
+			log.Printf("skip %s: no columns", table.Name)
+			continue
+		}
+		fileName := SnakeToUpperCamel(table.Name) + ".kt"
+		if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), fileName), func(wr io.Writer) error {
+			return gen.buildTable(wr, table)
+		}); err != nil {
+			return errors.Wrap(err, "build write table")
+		}
+	}
+
+	// Build Exposed table objects of tables
+	if gen.config.Exposed {
+		for _, table := range gen.ins.Tables {
+			if skipTable(gen.config.IncludeTables, gen.config.IgnoreTables, table.Name) {
+				continue
+			}
+			fileName := kotlinExposedName(table) + ".kt"
+			if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), fileName), func(wr io.Writer) error {
+				return gen.buildExposedTable(wr, table)
+			}); err != nil {
+				return errors.Wrap(err, "build write exposed table")
+			}
+		}
+	}
+
+	// Build sealed classes of json unions
+	for _, table := range gen.ins.Tables {
+		if skipTable(gen.config.IncludeTables, gen.config.IgnoreTables, table.Name) {
+			continue
+		}
+		for _, u := range gen.unions(table) {
+			fileName := u.Name + ".kt"
+			if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), fileName), func(wr io.Writer) error {
+				return gen.buildUnion(wr, u)
+			}); err != nil {
+				return errors.Wrap(err, "build write union")
+			}
+		}
+	}
+
+	// Build types
+	for _, typ := range gen.ins.Types {
+		fileName := SnakeToUpperCamel(typ.Name) + ".kt"
+		if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), fileName), func(wr io.Writer) error {
+			return gen.buildType(wr, typ)
+		}); err != nil {
+			return errors.Wrap(err, "build write type")
+		}
+	}
+
+	return nil
+}
+
+func (gen *Kotlin) buildTable(wr io.Writer, table Table) error {
+	members := gen.members(table)
+	return gen.template.ExecuteTemplate(wr, "data_class", map[string]interface{}{
+		"stamp":        gen.opts.Stamp,
+		"now":          time.Now().UTC().Format(time.RFC3339),
+		"package_name": gen.packageName(),
+		"imports":      gen.imports(members),
+		"comment":      strings.Replace(table.Comment.String, "\n", " ", -1),
+		"name":         SnakeToUpperCamel(table.Name),
+		"member":       members,
+	})
+}
+
+// buildExposedTable writes the Exposed table object of table. Columns without an Exposed
+// column builder are left out with a comment.
+func (gen *Kotlin) buildExposedTable(wr io.Writer, table Table) error {
+	var columns []KotlinExposedColumn
+	var skipped []string
+	var primaryKeys []string
+	imports := []string{"org.jetbrains.exposed.sql.Table"}
+	for _, col := range gen.columns(table) {
+		builder, ok := gen.exposedBuilder(col)
+		if !ok {
+			skipped = append(skipped, fmt.Sprintf("%s %s", col.Name, col.DataType))
+			continue
+		}
+		fn := builder[:strings.Index(builder, "(")]
+		if imp, ok := kotlinExposedImports[fn]; ok && !contains(imports, imp) {
+			imports = append(imports, imp)
+		}
+		if col.Serial || col.IdentityKind != "" {
+			builder += ".autoIncrement()"
+		}
+		if !col.NotNull && !col.PrimaryKey {
+			builder += ".nullable()"
+		}
+		name := kotlinName(SnakeToLowerCamel(col.Name))
+		columns = append(columns, KotlinExposedColumn{
+			Name:    name,
+			Builder: builder,
+			Comment: strings.Replace(col.Comment.String, "\n", " ", -1),
+		})
+		if col.PrimaryKey {
+			primaryKeys = append(primaryKeys, name)
+		}
+	}
+	sort.Strings(imports)
+	return gen.template.ExecuteTemplate(wr, "exposed_table", map[string]interface{}{
+		"stamp":        gen.opts.Stamp,
+		"now":          time.Now().UTC().Format(time.RFC3339),
+		"package_name": gen.packageName(),
+		"imports":      imports,
+		"comment":      strings.Replace(table.Comment.String, "\n", " ", -1),
+		"name":         kotlinExposedName(table),
+		"table":        strconv.Quote(table.Name),
+		"columns":      columns,
+		"skipped":      skipped,
+		"primary_key":  strings.Join(primaryKeys, ", "),
+	})
+}
+
+func kotlinExposedName(table Table) string {
+	return SnakeToUpperCamel(table.Name) + "Table"
+}
+
+// exposedBuilder returns the Exposed column builder of col, like varchar("name", 50), if any.
+// Arrays, json, unconstrained numerics and unknown types have none.
+func (gen *Kotlin) exposedBuilder(col Column) (string, bool) {
+	name := strconv.Quote(col.Name)
+	if col.Array || strings.HasSuffix(col.DataType, "[]") {
+		return "", false
+	}
+	switch col.DataType {
+	case "text", "citext", "character varying":
+		return "text(" + name + ")", true
+	case "smallint", "smallserial":
+		return "short(" + name + ")", true
+	case "int", "integer", "serial":
+		return "integer(" + name + ")", true
+	case "bigint", "bigserial":
+		return "long(" + name + ")", true
+	case "real":
+		return "float(" + name + ")", true
+	case "float", "double", "double precision":
+		return "double(" + name + ")", true
+	case "boolean":
+		return "bool(" + name + ")", true
+	case "uuid":
+		return "uuid(" + name + ")", true
+	case "date":
+		return "date(" + name + ")", true
+	case "timestamp", "timestamp without time zone":
+		return "datetime(" + name + ")", true
+	case "time", "time without time zone":
+		return "time(" + name + ")", true
+	case "interval":
+		return "duration(" + name + ")", true
+	case "bytea":
+		return "binary(" + name + ")", true
+	}
+	if strings.HasPrefix(col.DataType, "timestamp") {
+		if strings.HasSuffix(col.DataType, "with time zone") {
+			return "timestampWithTimeZone(" + name + ")", true
+		}
+		return "datetime(" + name + ")", true
+	}
+	if strings.HasPrefix(col.DataType, "numeric") && col.NumericPrecision > 0 {
+		return fmt.Sprintf("decimal(%s, %d, %d)", name, col.NumericPrecision, col.NumericScale), true
+	}
+	if n, ok := characterLength(col.DataType); ok {
+		if strings.HasPrefix(col.DataType, "character varying") || strings.HasPrefix(col.DataType, "varchar") {
+			return fmt.Sprintf("varchar(%s, %d)", name, n), true
+		}
+		return fmt.Sprintf("char(%s, %d)", name, n), true
+	}
+	if typ, err := gen.ins.FindType(col.DataType); err == nil {
+		// values are written as strings, which needs stringtype=unspecified of the JDBC driver
+		enum := SnakeToUpperCamel(typ.Name)
+		return fmt.Sprintf("customEnumeration(%s, %s, { value -> %s.values().first { it.value == value } }, { it.value })",
+			name, strconv.Quote(typ.Name), enum), true
+	}
+	if dom, err := gen.ins.FindDomain(col.DataType); err == nil {
+		col.DataType = dom.BaseType
+		return gen.exposedBuilder(col)
+	}
+	return "", false
+}
+
+func (gen *Kotlin) buildType(wr io.Writer, typ Type) error {
+	var values []KotlinEnumValue
+	for _, val := range typ.Values {
+		values = append(values, KotlinEnumValue{Name: hibernateEnumConstant(val), Value: strconv.Quote(val)})
+	}
+	return gen.template.ExecuteTemplate(wr, "enum", map[string]interface{}{
+		"stamp":        gen.opts.Stamp,
+		"now":          time.Now().UTC().Format(time.RFC3339),
+		"package_name": gen.packageName(),
+		"comment":      strings.Replace(typ.Comment.String, "\n", " ", -1),
+		"name":         SnakeToUpperCamel(typ.Name),
+		"values":       values,
+	})
+}
+
+// packageName returns package_name, qualified by the schema being built if any.
+func (gen *Kotlin) packageName() string {
+	if gen.opts.Schema == "" {
+		return gen.config.PackageName
+	}
+	return gen.config.PackageName + "." + gen.opts.Schema
+}
+
+func (gen *Kotlin) columns(table Table) []Column {
+	var ret []Column
+	for _, col := range table.Columns {
+		if containsColumn(gen.config.IgnoreColumns, table.Name, col.Name) {
+			continue
+		}
+		ret = append(ret, col)
+	}
+	return ret
+}
+
+func (gen *Kotlin) members(table Table) []KotlinMember {
+	var ret []KotlinMember
+	for _, col := range gen.columns(table) {
+		typ := gen.convertType(col)
+		if _, ok := gen.jsonUnion(table, col); ok {
+			typ = kotlinUnionName(table, col)
+		}
+		if !col.NotNull {
+			typ += "?"
+		}
+		ret = append(ret, KotlinMember{
+			Name:    kotlinName(SnakeToLowerCamel(col.Name)),
+			Type:    typ,
+			Comment: strings.Replace(col.Comment.String, "\n", " ", -1),
+		})
+	}
+	return ret
+}
+
+// imports returns the sorted imports of the types of members.
+func (gen *Kotlin) imports(members []KotlinMember) []string {
+	var ret []string
+	for _, m := range members {
+		typ := strings.TrimSuffix(m.Type, "?")
+		for strings.HasPrefix(typ, "List<") {
+			typ = strings.TrimSuffix(strings.TrimPrefix(typ, "List<"), ">")
+		}
+		if imp, ok := kotlinImports[typ]; ok && !contains(ret, imp) {
+			ret = append(ret, imp)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (gen *Kotlin) buildUnion(wr io.Writer, u KotlinUnion) error {
+	return gen.template.ExecuteTemplate(wr, "sealed_class", map[string]interface{}{
+		"stamp":        gen.opts.Stamp,
+		"now":          time.Now().UTC().Format(time.RFC3339),
+		"package_name": gen.packageName(),
+		"union":        u,
+	})
+}
+
+func (gen *Kotlin) jsonUnion(table Table, col Column) (UnionDef, bool) {
+	if col.Array || (col.DataType != "json" && col.DataType != "jsonb") {
+		return UnionDef{}, false
+	}
+	def, ok := gen.config.JsonUnions[table.Name+"."+col.Name]
+	return def, ok
+}
+
+// kotlinUnionName returns the name of the sealed class of col, like EventsPayloadUnion.
+func kotlinUnionName(table Table, col Column) string {
+	return SnakeToUpperCamel(table.Name) + SnakeToUpperCamel(col.Name) + "Union"
+}
+
+// unions returns sealed classes of json/jsonb columns of table configured in json_unions.
+// Subclasses are in order of their discriminator values, and named like ClickVariant so they
+// don't shadow the variant types.
+func (gen *Kotlin) unions(table Table) []KotlinUnion {
+	var ret []KotlinUnion
+	for _, col := range gen.columns(table) {
+		def, ok := gen.jsonUnion(table, col)
+		if !ok {
+			continue
+		}
+		u := KotlinUnion{Name: kotlinUnionName(table, col), Discriminator: strconv.Quote(def.Discriminator)}
+		for _, v := range def.VariantValues() {
+			u.Variants = append(u.Variants, KotlinVariant{
+				Name:    SnakeToUpperCamel(protoBufVariantName(v)) + "Variant",
+				Type:    def.Variants[v],
+				Comment: fmt.Sprintf("%s = %q", def.Discriminator, v),
+			})
+		}
+		ret = append(ret, u)
+	}
+	return ret
+}
+
+// kotlinName quotes name in backticks if it is a keyword.
+func kotlinName(name string) string {
+	if contains(kotlinKeywords, name) {
+		return "`" + name + "`"
+	}
+	return name
+}
+
+func (gen *Kotlin) convertType(col Column) string {
+	if strings.HasSuffix(col.DataType, "[]") {
+		col.DataType = strings.Replace(col.DataType, "[]", "", 1)
+		return "List<" + gen.convertType(col) + ">"
+	}
+
+	switch col.DataType {
+	case "text", "citext":
+		return "String"
+	case "smallint", "smallserial":
+		return "Short"
+	case "int", "integer", "serial":
+		return "Int"
+	case "bigint", "bigserial":
+		return "Long"
+	case "real":
+		return "Float"
+	case "float", "double", "double precision":
+		return "Double"
+	case "numeric":
+		return "BigDecimal"
+	case "boolean":
+		return "Boolean"
+	case "uuid":
+		return "UUID"
+	case "date":
+		return "LocalDate"
+	case "timestamp", "timestamp without time zone":
+		return "LocalDateTime"
+	case "time", "time without time zone":
+		return "LocalTime"
+	case "time with time zone":
+		return "OffsetTime"
+	case "interval":
+		return "Duration"
+	case "json", "jsonb":
+		// raw JSON
+		return "String"
+	case "bytea":
+		return "ByteArray"
+	case "int2vector", "oidvector":
+		// space separated numbers like "1 2"
+		return "String"
+	default:
+		// "timestamp with time zone", "timestamp(n) with time zone"
+		if strings.HasSuffix(col.DataType, "with time zone") && strings.HasPrefix(col.DataType, "timestamp") {
+			return "OffsetDateTime"
+		}
+		if strings.HasPrefix(col.DataType, "timestamp") {
+			return "LocalDateTime"
+		}
+		if strings.HasPrefix(col.DataType, "numeric") {
+			return "BigDecimal"
+		}
+		if strings.HasPrefix(col.DataType, "character") {
+			return "String"
+		}
+
+		typ, err := gen.ins.FindType(col.DataType)
+		if err == nil {
+			return SnakeToUpperCamel(typ.Name)
+		}
+		if dom, err := gen.ins.FindDomain(col.DataType); err == nil {
+			col.DataType = dom.BaseType
+			return gen.convertType(col)
+		}
+	}
+	// unknown types must be cast before use
+	return "Any"
+}
+
+func loadKotlinConfig(root string, raw json.RawMessage) (KotlinConfig, error) {
+	var kc KotlinConfig
+	if err := json.Unmarshal(raw, &kc); err != nil {
+		return kc, fmt.Errorf("kotlin config error: %s", err)
+	}
+	if err := applyEnvOverrides(KotlinTypeName, &kc); err != nil {
+		return kc, fmt.Errorf("kotlin config error: %s", err)
+	}
+	output := filePathJoinRoot(root, kc.Output)
+	if err := DirExists(output); err != nil {
+		return kc, fmt.Errorf("kotlin output is not exists: %s", kc.Output)
+	}
+	if kc.PackageName == "" {
+		return kc, fmt.Errorf("kotlin package_name is required")
+	}
+	if err := checkGlobs(kc.IncludeTables); err != nil {
+		return kc, fmt.Errorf("kotlin include_tables: %s", err)
+	}
+	if err := checkIgnores(kc.IgnoreTables); err != nil {
+		return kc, fmt.Errorf("kotlin ignore_tables: %s", err)
+	}
+	if err := checkJsonUnions(kc.JsonUnions); err != nil {
+		return kc, fmt.Errorf("kotlin config error: %s", err)
+	}
+	return kc, nil
+}
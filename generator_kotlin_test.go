@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func newTestKotlin(config KotlinConfig, ins InspectResult) *Kotlin {
+	config.PackageName = "com.example.model"
+	return &Kotlin{
+		config:   config,
+		ins:      ins,
+		template: template.Must(template.New("").Funcs(templateFuncs()).ParseGlob("templates/kotlin/*.tmpl")),
+	}
+}
+
+func TestKotlinConvertType(t *testing.T) {
+	gen := Kotlin{
+		ins: InspectResult{
+			Types:   []Type{Type{Name: "order_status", Values: []string{"open"}}},
+			Domains: []Type{Type{Name: "email", BaseType: "character varying(255)"}},
+		},
+	}
+	ff := [][]string{
+		[]string{"text", "String"},
+		[]string{"integer", "Int"},
+		[]string{"bigint", "Long"},
+		[]string{"numeric(10,2)", "BigDecimal"},
+		[]string{"boolean", "Boolean"},
+		[]string{"date", "LocalDate"},
+		[]string{"timestamp with time zone", "OffsetDateTime"},
+		[]string{"timestamp(3) with time zone", "OffsetDateTime"},
+		[]string{"timestamp without time zone", "LocalDateTime"},
+		[]string{"uuid", "UUID"},
+		[]string{"text[]", "List<String>"},
+		[]string{"integer[][]", "List<List<Int>>"},
+		[]string{"order_status", "OrderStatus"},
+		[]string{"email", "String"},
+		[]string{"fooBar", "Any"},
+	}
+	for _, d := range ff {
+		col := Column{
+			DataType: d[0],
+		}
+		if actual := gen.convertType(col); actual != d[1] {
+			t.Errorf("%s: expected %s, actual: %s", d[0], d[1], actual)
+		}
+	}
+}
+
+func TestKotlinDataClass(t *testing.T) {
+	gen := newTestKotlin(KotlinConfig{IgnoreColumns: []string{"users.password_hash"}}, InspectResult{
+		Types: []Type{Type{Name: "user_status", Values: []string{"active", "on_hold"}}},
+	})
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "uuid", NotNull: true},
+			Column{Name: "display_name", DataType: "text"},
+			Column{Name: "status", DataType: "user_status", NotNull: true},
+			Column{Name: "scores", DataType: "numeric[]"},
+			Column{Name: "created_at", DataType: "timestamp with time zone", NotNull: true},
+			Column{Name: "object", DataType: "text", NotNull: true},
+			Column{Name: "password_hash", DataType: "text", NotNull: true},
+		},
+	}
+	var buf bytes.Buffer
+	if err := gen.buildTable(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{
+		"package com.example.model\n\nimport java.math.BigDecimal\nimport java.time.OffsetDateTime\nimport java.util.UUID\n\n",
+		"data class Users(\n",
+		"    val id: UUID,\n",
+		"    val displayName: String?,\n",
+		"    val status: UserStatus,\n",
+		"    val scores: List<BigDecimal>?,\n",
+		"    val createdAt: OffsetDateTime,\n",
+		"    val `object`: String,\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "passwordHash") {
+		t.Errorf("ignored column is generated:\n%s", out)
+	}
+
+	buf.Reset()
+	if err := gen.buildTable(&buf, Table{Name: "migrations"}); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, "\nclass Migrations\n") || strings.Contains(out, "data class") {
+		t.Errorf("a data class needs a property:\n%s", out)
+	}
+
+	buf.Reset()
+	if err := gen.buildType(&buf, gen.ins.Types[0]); err != nil {
+		t.Fatal(err)
+	}
+	expected := "enum class UserStatus(val value: String) {\n    ACTIVE(\"active\"),\n    ON_HOLD(\"on_hold\"),\n}"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in:\n%s", expected, buf.String())
+	}
+}
+
+func TestKotlinExposedTable(t *testing.T) {
+	gen := newTestKotlin(KotlinConfig{Exposed: true}, InspectResult{
+		Types:   []Type{Type{Name: "user_status", Values: []string{"active"}}},
+		Domains: []Type{Type{Name: "email", BaseType: "character varying(255)"}},
+	})
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer", NotNull: true, PrimaryKey: true, Serial: true},
+			Column{Name: "name", DataType: "character varying(50)", NotNull: true},
+			Column{Name: "nickname", DataType: "text"},
+			Column{Name: "email", DataType: "email", NotNull: true},
+			Column{Name: "balance", DataType: "numeric(10,2)", NumericPrecision: 10, NumericScale: 2},
+			Column{Name: "status", DataType: "user_status", NotNull: true},
+			Column{Name: "created_at", DataType: "timestamp with time zone", NotNull: true},
+			Column{Name: "memo", DataType: "jsonb"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := gen.buildExposedTable(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{
+		"import org.jetbrains.exposed.sql.Table\nimport org.jetbrains.exposed.sql.javatime.timestampWithTimeZone\n",
+		"object UsersTable : Table(\"users\") {\n",
+		"    val id = integer(\"id\").autoIncrement()\n",
+		"    val name = varchar(\"name\", 50)\n",
+		"    val nickname = text(\"nickname\").nullable()\n",
+		"    val email = varchar(\"email\", 255)\n",
+		"    val balance = decimal(\"balance\", 10, 2).nullable()\n",
+		"    val status = customEnumeration(\"status\", \"user_status\", { value -> UserStatus.values().first { it.value == value } }, { it.value })\n",
+		"    val createdAt = timestampWithTimeZone(\"created_at\")\n",
+		"    // memo jsonb: no Exposed column type\n",
+		"    override val primaryKey = PrimaryKey(id)\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+
+	buf.Reset()
+	table = Table{
+		Name: "tenant_orders",
+		Columns: []Column{
+			Column{Name: "tenant_id", DataType: "bigint", NotNull: true, PrimaryKey: true},
+			Column{Name: "order_id", DataType: "bigint", NotNull: true, PrimaryKey: true},
+		},
+	}
+	if err := gen.buildExposedTable(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "    override val primaryKey = PrimaryKey(tenantId, orderId)\n"; !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in:\n%s", expected, buf.String())
+	}
+}
+
+func TestKotlinJsonUnions(t *testing.T) {
+	gen := newTestKotlin(KotlinConfig{JsonUnions: map[string]UnionDef{
+		"events.payload": UnionDef{Discriminator: "kind", Variants: map[string]string{"page-view": "PageView", "click": "Click"}},
+		"events.id":      UnionDef{Discriminator: "kind", Variants: map[string]string{"click": "Click"}},
+	}}, InspectResult{})
+	table := Table{
+		Name: "events",
+		Columns: []Column{
+			Column{Name: "id", DataType: "bigint", NotNull: true},
+			Column{Name: "payload", DataType: "jsonb"},
+		},
+	}
+	unions := gen.unions(table)
+	if len(unions) != 1 {
+		t.Fatalf("only the jsonb column should be a union: %v", unions)
+	}
+	var buf bytes.Buffer
+	if err := gen.buildUnion(&buf, unions[0]); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{
+		"package com.example.model\n",
+		"sealed class EventsPayloadUnion {\n",
+		"    data class ClickVariant(val value: Click) : EventsPayloadUnion()\n",
+		"    data class PageViewVariant(val value: PageView) : EventsPayloadUnion()\n",
+		`        const val DISCRIMINATOR = "kind"`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+	if strings.Index(out, "class ClickVariant") > strings.Index(out, "class PageViewVariant") {
+		t.Errorf("variants should be in order of discriminator values:\n%s", out)
+	}
+
+	buf.Reset()
+	if err := gen.buildTable(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "    val payload: EventsPayloadUnion?,\n)\n"; !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in:\n%s", expected, buf.String())
+	}
+}
@@ -5,9 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -25,6 +29,64 @@ type ProtoBufConfig struct {
 	GoPackage          string   `json:"go_package"`
 	IgnoreTables       []string `json:"ignore_tables"`
 	UseStringToNumeric bool     `json:"use_string_to_numeric"`
+	FieldNumberBase    int      `json:"field_number_base"`
+	PiiColumns         []string `json:"pii_columns"`
+	PiiImport          string   `json:"pii_import"`
+	// GenerateProtovalidate adds buf.validate field rules
+	GenerateProtovalidate bool `json:"generate_protovalidate"`
+	GenerateListWrappers  bool `json:"generate_list_wrappers"`
+	// CommentsFile is a JSON file of descriptions keyed by "table" or "table.column"
+	CommentsFile     string `json:"comments_file"`
+	CommentsFileWins bool   `json:"comments_file_wins"`
+	// PkFieldsFirst puts primary key fields first so they get the lowest field numbers
+	PkFieldsFirst bool `json:"pk_fields_first"`
+	// GenerateApiResource adds google.api.resource options, https://google.aip.dev/123
+	GenerateApiResource bool   `json:"generate_api_resource"`
+	ApiServiceName      string `json:"api_service_name"`
+	// EnumFilePerType writes each enum to its own file instead of enum.proto
+	EnumFilePerType bool `json:"enum_file_per_type"`
+	// GenerateServices writes service.proto with gRPC CRUD services of tables
+	GenerateServices bool `json:"generate_services"`
+	// StreamLists makes List RPCs of services stream messages instead of returning pages
+	StreamLists bool `json:"stream_lists"`
+	// JsonMaps maps json/jsonb columns ("table.column") to map<string, value type>
+	JsonMaps map[string]string `json:"json_maps"`
+	// FieldNumbersFile persists assigned field numbers so they are stable across runs
+	FieldNumbersFile string `json:"field_numbers_file"`
+	// Syntax is "proto3" (default) or "proto2"
+	Syntax string `json:"syntax"`
+	// TimestampMode is one of "wkt" (default), "string", "epoch_millis" and "epoch_seconds"
+	TimestampMode string `json:"timestamp_mode"`
+	// SkipEmptyTables skips tables without columns, which may exist while migrating
+	SkipEmptyTables bool `json:"skip_empty_tables"`
+	// ConnectServices writes service.proto with CRUD services of tables, following Connect conventions
+	ConnectServices bool `json:"connect_services"`
+	// EnumValueComments documents enum values, keyed by enum type and value
+	EnumValueComments map[string]map[string]string `json:"enum_value_comments"`
+	// NumericAsDouble maps numeric with a scale to double instead of string, losing exactness
+	NumericAsDouble bool `json:"numeric_as_double"`
+	// UseWKT maps date to google.type.Date and timestamps to google.protobuf.Timestamp
+	UseWKT bool `json:"use_wkt"`
+	// GenerateViews writes messages of views and materialized views
+	GenerateViews bool `json:"generate_views"`
+	// TypeOverrides maps data types like citext to protobuf types, taking precedence over built-in mappings
+	TypeOverrides map[string]string `json:"type_overrides"`
+	// TemplateData is passed to every template as .extra
+	TemplateData map[string]interface{} `json:"template_data"`
+	// ColumnRenames are field names keyed by "table.column"
+	ColumnRenames map[string]string `json:"column_renames"`
+	// EnumAliases are extra labels sharing the number of an enum value, keyed by "type.value"
+	EnumAliases map[string][]string `json:"enum_aliases"`
+	// IntervalMode is "string" (default, or "duration" with use_wkt) or "duration" for google.protobuf.Duration
+	IntervalMode string `json:"interval_mode"`
+	// StableOutput leaves files whose content is unchanged, ignoring generated timestamps
+	StableOutput bool `json:"stable_output"`
+	// JsonUnions maps json/jsonb columns ("table.column") to tagged unions, written as oneof
+	JsonUnions map[string]UnionDef `json:"json_unions"`
+	// IncludeTables restricts generation to tables matching the glob patterns like order_*, before IgnoreTables
+	IncludeTables []string `json:"include_tables"`
+	// Concurrency is the number of messages built at once, GOMAXPROCS if zero
+	Concurrency int `json:"concurrency"`
 }
 
 type ProtoBuf struct {
@@ -33,6 +95,9 @@ type ProtoBuf struct {
 	ins      InspectResult
 	template *template.Template
 	root     string
+	opts     BuildOptions
+	comments map[string]string
+	numbers  ProtoBufFieldNumbers
 }
 
 type ProtoBufMember struct {
@@ -41,16 +106,251 @@ type ProtoBufMember struct {
 	Type       string
 	Comment    string
 	Index      int
+	Options    []string
+	// LeadingComments are written above the field
+	LeadingComments []string
 }
 
-type ProtoBufTypeMember struct {
+// FieldOptions returns options written after the field number, like " [(pii) = true]".
+func (m ProtoBufMember) FieldOptions() string {
+	if len(m.Options) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(m.Options, ", ") + "]"
+}
+
+// ProtoBufFieldNumbers are field numbers assigned per message, persisted in field_numbers_file.
+type ProtoBufFieldNumbers map[string]*ProtoBufMessageNumbers
+
+type ProtoBufMessageNumbers struct {
+	// Fields are numbers keyed by column name, so renaming a field keeps its number
+	Fields map[string]int `json:"fields"`
+	// Max is the highest number ever assigned. Numbers are never reused even if the field is dropped.
+	Max int `json:"max"`
+	// Reserved are fields dropped from the message, written as reserved to keep wire compatibility
+	Reserved []ProtoBufReservedField `json:"reserved,omitempty"`
+	// Names are field names of renamed columns, reserved if the column is dropped
+	Names map[string]string `json:"names,omitempty"`
+}
+
+type ProtoBufReservedField struct {
+	// Name is empty once a new field of the same name is added
+	Name   string `json:"name,omitempty"`
+	Number int    `json:"number"`
+}
+
+func loadProtoBufFieldNumbers(file string) (ProtoBufFieldNumbers, error) {
+	ret := make(ProtoBufFieldNumbers)
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ret, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, &ret); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	for _, msg := range ret {
+		if msg.Fields == nil {
+			msg.Fields = make(map[string]int)
+		}
+		for _, n := range msg.Fields {
+			if n > msg.Max {
+				msg.Max = n
+			}
+		}
+	}
+	return ret, nil
+}
+
+func (numbers ProtoBufFieldNumbers) save(wr io.Writer) error {
+	buf, err := json.MarshalIndent(numbers, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = wr.Write(append(buf, '\n'))
+	return err
+}
+
+func (numbers ProtoBufFieldNumbers) message(name string) *ProtoBufMessageNumbers {
+	msg, ok := numbers[name]
+	if !ok {
+		msg = &ProtoBufMessageNumbers{Fields: make(map[string]int)}
+		numbers[name] = msg
+	}
+	return msg
+}
+
+// number returns the persisted number of column, or assigns the next number to it. field is
+// the name of the field of column.
+func (msg *ProtoBufMessageNumbers) number(column, field string, base int) int {
+	msg.name(column, field)
+	if n, ok := msg.Fields[column]; ok {
+		return n
+	}
+	if n, ok := msg.Fields[field]; ok && field != column {
+		// numbers were keyed by field name before
+		delete(msg.Fields, field)
+		msg.Fields[column] = n
+		return n
+	}
+	n := msg.Max + 1
+	if n < base {
+		n = base
+	}
+	n = skipReservedFieldNumber(n)
+	msg.Fields[column] = n
+	msg.Max = n
+	// the name of a dropped field is reusable, but its number is not
+	for i := range msg.Reserved {
+		if msg.Reserved[i].Name == field {
+			msg.Reserved[i].Name = ""
+		}
+	}
+	return n
+}
+
+// name records field as the name of column if it is renamed.
+func (msg *ProtoBufMessageNumbers) name(column, field string) {
+	if field == column {
+		delete(msg.Names, column)
+		return
+	}
+	if msg.Names == nil {
+		msg.Names = make(map[string]string)
+	}
+	msg.Names[column] = field
+}
+
+// reserve moves columns not in current to the reserved fields.
+func (msg *ProtoBufMessageNumbers) reserve(current map[string]bool) {
+	var dropped []string
+	for column := range msg.Fields {
+		if !current[column] {
+			dropped = append(dropped, column)
+		}
+	}
+	sort.Strings(dropped)
+	for _, column := range dropped {
+		name := column
+		if field, ok := msg.Names[column]; ok {
+			name = field
+		}
+		msg.Reserved = append(msg.Reserved, ProtoBufReservedField{Name: name, Number: msg.Fields[column]})
+		delete(msg.Fields, column)
+		delete(msg.Names, column)
+	}
+}
+
+// reservedNumbers returns the statement body like "2, 5" of reserved field numbers.
+func (msg *ProtoBufMessageNumbers) reservedNumbers() string {
+	var ret []string
+	for _, r := range msg.Reserved {
+		ret = append(ret, strconv.Itoa(r.Number))
+	}
+	return strings.Join(ret, ", ")
+}
+
+// reservedNames returns the statement body like `"name", "email"` of reserved field names.
+func (msg *ProtoBufMessageNumbers) reservedNames() string {
+	var ret []string
+	for _, r := range msg.Reserved {
+		if r.Name != "" {
+			ret = append(ret, strconv.Quote(r.Name))
+		}
+	}
+	return strings.Join(ret, ", ")
+}
+
+// ProtoBufArrayWrapper is a nested message holding one dimension of a multi-dimensional array,
+// because repeated fields can't be nested.
+type ProtoBufArrayWrapper struct {
+	Name string
+	Type string // type of the repeated values field
+}
+
+// ProtoBufComposite is a nested message of a composite type used by columns of a message.
+type ProtoBufComposite struct {
 	Name    string
-	Comment string
-	Values  string
+	Members []ProtoBufMember
+}
+
+// ProtoBufUnion is a nested message of a json/jsonb column holding a tagged union, whose
+// variants are fields of a oneof named by the discriminator.
+type ProtoBufUnion struct {
+	Name          string
+	Discriminator string
+	Variants      []ProtoBufMember
+	// ReservedNumbers and ReservedNames are of variants dropped from field_numbers_file
+	ReservedNumbers string
+	ReservedNames   string
+}
+
+// ProtoBufService is a CRUD service of a table with a single primary key.
+type ProtoBufService struct {
+	Name     string // service name, e.g. UsersService
+	Resource string // used in RPC and request names, e.g. GetUsers
+	Message  string // message of the table
+	Field    string // field name of the message in requests and responses
+	Key      ProtoBufMember
+}
+
+type ProtoBufApiResource struct {
+	Type    string
+	Pattern string
+}
+
+type ProtoBufTypeMember struct {
+	Name       string
+	Comment    string
+	Values     string
+	AllowAlias bool
 }
 
 const ProtoBufTypeName = "protobuf"
 
+const (
+	protoBufSyntax2 = "proto2"
+	protoBufSyntax3 = "proto3"
+)
+
+const (
+	protovalidateImport = "buf/validate/validate.proto"
+	apiResourceImport   = "google/api/resource.proto"
+	timestampImport     = "google/protobuf/timestamp.proto"
+	dateImport          = "google/type/date.proto"
+	fieldMaskImport     = "google/protobuf/field_mask.proto"
+	durationImport      = "google/protobuf/duration.proto"
+)
+
+// wktImports are files defining well-known and common types.
+var wktImports = map[string]string{
+	"google.protobuf.Timestamp": timestampImport,
+	"google.type.Date":          dateImport,
+	"google.protobuf.Duration":  durationImport,
+}
+
+const (
+	TimestampModeWKT          = "wkt"
+	TimestampModeString       = "string"
+	TimestampModeEpochMillis  = "epoch_millis"
+	TimestampModeEpochSeconds = "epoch_seconds"
+)
+
+const (
+	IntervalModeString   = "string"
+	IntervalModeDuration = "duration"
+)
+
+// Field numbers 19000 through 19999 are reserved for the Protocol Buffers implementation.
+// https://developers.google.com/protocol-buffers/docs/proto3#assigning_field_numbers
+const (
+	protoBufReservedFieldNumberFrom = 19000
+	protoBufReservedFieldNumberTo   = 19999
+	protoBufMaxFieldNumber          = 536870911
+)
+
 func NewProtoBuf(db *sql.DB, root string, raw json.RawMessage) (Generator, error) {
 	config, err := loadProtoBufConfig(root, raw)
 	if err != nil {
@@ -61,6 +361,13 @@ func NewProtoBuf(db *sql.DB, root string, raw json.RawMessage) (Generator, error
 		config: config,
 		root:   root,
 	}
+	if config.CommentsFile != "" {
+		comments, err := loadCommentsFile(filePathJoinRoot(root, config.CommentsFile))
+		if err != nil {
+			return nil, errors.Wrap(err, "protobuf comments file")
+		}
+		ret.comments = comments
+	}
 
 	return &ret, nil
 }
@@ -69,41 +376,89 @@ func (gen *ProtoBuf) GetType() string {
 	return ProtoBufTypeName
 }
 
-func (gen *ProtoBuf) Build(ins InspectResult) error {
-	log.Printf("output: %s", filePathJoinRoot(gen.root, gen.config.Output))
+func (gen *ProtoBuf) Build(ins InspectResult, opts BuildOptions) error {
+	log.Printf("output: %s", opts.outputDir(gen.root, gen.config.Output))
 	log.Printf("templates: %s", filePathJoinRoot(gen.root, gen.config.Templates))
 	gen.ins = ins
+	gen.opts = opts
+	opts = opts.stableOutput(gen.config.StableOutput)
+	// the field numbers file is not generated, so it is saved regardless of overwrites
+	numbersOpts := opts
+	opts = opts.keepExisting(gen.config.Overwrites)
 
 	// Load templates
 	tdir := filepath.Join(filePathJoinRoot(gen.root, gen.config.Templates), "*.tmpl")
-	t := template.Must(template.ParseGlob(tdir))
+	t := template.Must(template.New("").Funcs(templateFuncs()).ParseGlob(tdir))
 	gen.template = t
 
-	// Build tables
-	for _, table := range gen.ins.Tables {
-		if partContainsRegex(gen.config.IgnoreTables, table.Name) {
+	if gen.config.FieldNumbersFile != "" {
+		numbers, err := loadProtoBufFieldNumbers(filePathJoinRoot(gen.root, gen.config.FieldNumbersFile))
+		if err != nil {
+			return errors.Wrap(err, "load field numbers")
+		}
+		gen.numbers = numbers
+	}
+
+	// Build tables and row types of functions
+	tables := gen.ins.TablesAndFunctions()
+	if gen.config.GenerateViews {
+		tables = append(tables, gen.ins.Views...)
+	}
+	var jobs []fileJob
+	for _, table := range tables {
+		if skipTable(gen.config.IncludeTables, gen.config.IgnoreTables, table.Name) {
 			continue
 		}
+		if len(table.Columns) == 0 && gen.config.SkipEmptyTables {
+			log.Printf("skip %s: no columns", table.Name)
+			continue
+		}
+		table := table
 		fileName := SnakeToUpperCamel(table.Name) + "Message.proto"
-		file, err := os.Create(filepath.Join(filePathJoinRoot(gen.root, gen.config.Output), fileName))
-		if err != nil {
-			return errors.Wrap(err, "build create file")
+		jobs = append(jobs, fileJob{
+			path:  filepath.Join(opts.outputDir(gen.root, gen.config.Output), fileName),
+			write: func(wr io.Writer) error { return gen.buildTable(wr, table) },
+			wrap:  "build write table",
+		})
+	}
+	concurrency := gen.config.Concurrency
+	if gen.numbers != nil {
+		// field numbers are assigned in the shared file
+		concurrency = 1
+	}
+	if err := opts.writeFiles(jobs, concurrency); err != nil {
+		return err
+	}
+
+	if gen.config.GenerateServices || gen.config.ConnectServices {
+		if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), protoBufServiceFileName), func(wr io.Writer) error {
+			return gen.buildService(wr)
+		}); err != nil {
+			return errors.Wrap(err, "build write service")
 		}
-		if err := gen.buildTable(file, table); err != nil {
-			file.Close()
-			return errors.Wrap(err, "build write table")
+	}
+
+	if gen.numbers != nil {
+		if err := numbersOpts.writeFile(filePathJoinRoot(gen.root, gen.config.FieldNumbersFile), gen.numbers.save); err != nil {
+			return errors.Wrap(err, "save field numbers")
 		}
-		file.Close()
 	}
 
 	// Build types
-	enumFileName := "enum.proto"
-	file, err := os.Create(filepath.Join(filePathJoinRoot(gen.root, gen.config.Output), enumFileName))
-	defer file.Close()
-	if err != nil {
-		return errors.Wrap(err, "build create file")
+	if gen.config.EnumFilePerType {
+		for _, typ := range gen.ins.Types {
+			if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), gen.enumFileName(typ)), func(wr io.Writer) error {
+				return gen.buildType(wr, []Type{typ})
+			}); err != nil {
+				return errors.Wrap(err, "build write type")
+			}
+		}
+		return nil
 	}
-	if err := gen.buildType(file, gen.ins.Types); err != nil {
+	enumFileName := "enum.proto"
+	if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), enumFileName), func(wr io.Writer) error {
+		return gen.buildType(wr, gen.ins.Types)
+	}); err != nil {
 		return errors.Wrap(err, "build write type")
 	}
 
@@ -111,64 +466,729 @@ func (gen *ProtoBuf) Build(ins InspectResult) error {
 }
 
 func (gen *ProtoBuf) buildTable(wr io.Writer, table Table) error {
+	if err := checkColumnRenames(gen.config.ColumnRenames, table); err != nil {
+		return err
+	}
+	// members reserves dropped fields
+	members := gen.members(table)
+	var reservedNumbers, reservedNames string
+	if gen.numbers != nil {
+		msg := gen.messageNumbers(table)
+		reservedNumbers, reservedNames = msg.reservedNumbers(), msg.reservedNames()
+	}
 	return gen.template.ExecuteTemplate(wr, "message", map[string]interface{}{
-		"package_name": gen.config.PackageName,
+		"extra":          gen.config.TemplateData,
+		"stamp":          gen.opts.Stamp,
+		"syntax":         gen.syntax(),
+		"package_name":   gen.packageName(),
+		"java_package":   gen.config.JavaPackage,
+		"go_package":     gen.config.GoPackage,
+		"now":            time.Now().UTC().Format(time.RFC3339),
+		"comment":        gen.comment(table.Name, table.Comment.String),
+		"table":          table,
+		"name":           SnakeToUpperCamel(table.Name) + "Message",
+		"list_name":      gen.listName(table),
+		"resource":       gen.apiResource(table),
+		"member":         members,
+		"array_wrappers": gen.arrayWrappers(table),
+		"composites":     gen.compositeMessages(table),
+		"unions":         gen.unions(table),
+		"checks":         table.TableChecks,
+		"reserved":       reservedNumbers,
+		"reserved_names": reservedNames,
+		"enum_path":      gen.enumPath(),
+		"imports":        gen.imports(table),
+	})
+}
+
+func (gen *ProtoBuf) buildService(wr io.Writer) error {
+	services := gen.services()
+	var imports []string
+	for _, srv := range services {
+		if path, ok := protoBufWKTImport(srv.Key.Type); ok && !contains(imports, path) {
+			imports = append(imports, path)
+		}
+	}
+	if len(services) > 0 {
+		// update masks of Update requests
+		imports = append(imports, fieldMaskImport)
+	}
+	for _, srv := range services {
+		imports = append(imports, gen.importPath("", srv.Message+".proto"))
+	}
+	label := ""
+	if gen.syntax() == protoBufSyntax2 {
+		label = "optional "
+	}
+	return gen.template.ExecuteTemplate(wr, "service", map[string]interface{}{
+		"extra":        gen.config.TemplateData,
+		"stamp":        gen.opts.Stamp,
+		"syntax":       gen.syntax(),
+		"label":        label,
+		"package_name": gen.packageName(),
 		"java_package": gen.config.JavaPackage,
 		"go_package":   gen.config.GoPackage,
 		"now":          time.Now().UTC().Format(time.RFC3339),
-		"comment":      table.Comment.String,
-		"table":        table,
-		"name":         SnakeToUpperCamel(table.Name) + "Message",
-		"member":       gen.members(table),
-		"enum_path":    filepath.Join(gen.config.EnumDir, "enum.proto"),
+		"imports":      imports,
+		"services":     services,
+		"stream_lists": gen.config.StreamLists,
+		"connect":      gen.config.ConnectServices,
 	})
 }
 
+const protoBufServiceFileName = "service.proto"
+
+// services returns CRUD services of tables. Tables without a single primary key can't be
+// addressed by Get, Update and Delete, so they have no service.
+func (gen *ProtoBuf) services() []ProtoBufService {
+	var ret []ProtoBufService
+	for _, table := range gen.ins.Tables {
+		if skipTable(gen.config.IncludeTables, gen.config.IgnoreTables, table.Name) {
+			continue
+		}
+		var pks []Column
+		for _, col := range table.Columns {
+			if col.PrimaryKey {
+				pks = append(pks, col)
+			}
+		}
+		if len(pks) != 1 {
+			continue
+		}
+		resource := SnakeToUpperCamel(table.Name)
+		ret = append(ret, ProtoBufService{
+			Name:     resource + "Service",
+			Resource: resource,
+			Message:  resource + "Message",
+			Field:    table.Name,
+			Key: ProtoBufMember{
+				Name: renamedColumn(gen.config.ColumnRenames, table.Name, pks[0]),
+				Type: gen.fieldType(table, pks[0]),
+			},
+		})
+	}
+	return ret
+}
+
+// comment merges the DB comment with the comments file entry of key.
+// The DB comment takes precedence unless comments_file_wins is set.
+func (gen *ProtoBuf) comment(key, dbComment string) string {
+	c, ok := gen.comments[key]
+	if !ok {
+		return dbComment
+	}
+	if dbComment == "" || gen.config.CommentsFileWins {
+		return c
+	}
+	return dbComment
+}
+
+// apiResource returns the resource annotation of table. Only tables with a single primary key are resources.
+func (gen *ProtoBuf) apiResource(table Table) *ProtoBufApiResource {
+	if !gen.config.GenerateApiResource {
+		return nil
+	}
+	var pks []Column
+	for _, col := range table.Columns {
+		if col.PrimaryKey {
+			pks = append(pks, col)
+		}
+	}
+	if len(pks) != 1 {
+		return nil
+	}
+	service := gen.config.ApiServiceName
+	if service == "" {
+		service = gen.config.PackageName
+	}
+	return &ProtoBufApiResource{
+		Type:    service + "/" + SnakeToUpperCamel(table.Name),
+		Pattern: SnakeToLowerCamel(table.Name) + "/{" + renamedColumn(gen.config.ColumnRenames, table.Name, pks[0]) + "}",
+	}
+}
+
+// listName returns the name of the list wrapper message of table, or empty if not generated.
+func (gen *ProtoBuf) listName(table Table) string {
+	if !gen.config.GenerateListWrappers {
+		return ""
+	}
+	return SnakeToUpperCamel(table.Name) + "MessageList"
+}
+
+// packageName returns package_name, qualified by the schema being built if any.
+func (gen *ProtoBuf) packageName() string {
+	if gen.opts.Schema == "" {
+		return gen.config.PackageName
+	}
+	return gen.config.PackageName + "." + gen.opts.Schema
+}
+
+// messageNumbers returns the field numbers of table, keyed by "schema.table" if a schema is
+// being built, so same-named tables of schemas have their own numbers. Numbers recorded under
+// the table name before schemas were configured are moved to the qualified key, so fields keep
+// their numbers.
+func (gen *ProtoBuf) messageNumbers(table Table) *ProtoBufMessageNumbers {
+	if gen.opts.Schema == "" {
+		return gen.numbers.message(table.Name)
+	}
+	key := gen.opts.Schema + "." + table.Name
+	if _, ok := gen.numbers[key]; !ok {
+		if msg, ok := gen.numbers[table.Name]; ok {
+			log.Printf("field numbers of %s are moved to %s", table.Name, key)
+			gen.numbers[key] = msg
+			delete(gen.numbers, table.Name)
+		}
+	}
+	return gen.numbers.message(key)
+}
+
+// enumPath returns the path of enum.proto imported by every message, or empty if enums are written per type.
+func (gen *ProtoBuf) enumPath() string {
+	if gen.config.EnumFilePerType {
+		return ""
+	}
+	return gen.importPath(gen.config.EnumDir, "enum.proto")
+}
+
+// importPath returns the import path of a generated file in dir, which is in the subdirectory
+// of the schema being built if any, so files of schemas can be compiled together.
+func (gen *ProtoBuf) importPath(dir, file string) string {
+	return filepath.Join(dir, gen.opts.Schema, file)
+}
+
+func (gen *ProtoBuf) enumFileName(typ Type) string {
+	return SnakeToUpperCamel(typ.Name) + ".proto"
+}
+
+// imports returns additional files imported by the message of table.
+func (gen *ProtoBuf) imports(table Table) []string {
+	var ret []string
+	// a file can't be imported twice
+	add := func(path string) {
+		if !contains(ret, path) {
+			ret = append(ret, path)
+		}
+	}
+	// attributes of nested composite types are written in the same file
+	cols := append([]Column{}, table.Columns...)
+	for _, typ := range gen.composites(table) {
+		cols = append(cols, typ.Attributes...)
+	}
+	for _, col := range cols {
+		// element types of multi-dimensional arrays are in wrappers
+		for _, typ := range []string{gen.fieldType(table, col), gen.convertType(col)} {
+			if path, ok := protoBufWKTImport(typ); ok {
+				add(path)
+			}
+		}
+	}
+	if gen.config.EnumFilePerType {
+		for _, col := range cols {
+			typ, err := gen.ins.FindType(strings.TrimSuffix(col.DataType, "[]"))
+			if err != nil {
+				continue
+			}
+			add(gen.importPath(gen.config.EnumDir, gen.enumFileName(typ)))
+		}
+	}
+	if gen.config.GenerateProtovalidate {
+		add(protovalidateImport)
+	}
+	if gen.apiResource(table) != nil {
+		add(apiResourceImport)
+	}
+	if gen.config.PiiImport != "" {
+		for _, col := range table.Columns {
+			if isPii(gen.config.PiiColumns, table.Name, col) {
+				add(gen.config.PiiImport)
+				break
+			}
+		}
+	}
+	for _, col := range table.Columns {
+		if path, ok := gen.jsonMapImport(table, col); ok {
+			add(path)
+		}
+		if def, ok := gen.jsonUnion(table, col); ok {
+			for _, v := range def.VariantValues() {
+				if path, ok := gen.messageImport(table, def.Variants[v]); ok {
+					add(path)
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// protoBufWKTImport returns the file to import for the well-known type used by fieldType,
+// including map values.
+func protoBufWKTImport(fieldType string) (string, bool) {
+	for typ, path := range wktImports {
+		if strings.Contains(fieldType, typ) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// fieldType returns the field type of col, applying per column settings before convertType.
+func (gen *ProtoBuf) fieldType(table Table, col Column) string {
+	if v, ok := gen.jsonMapValue(table, col); ok {
+		return "map<string, " + v + ">"
+	}
+	if _, ok := gen.jsonUnion(table, col); ok {
+		return protoBufUnionName(col)
+	}
+	typ := gen.convertType(col)
+	if col.Array && !strings.HasPrefix(typ, "repeated ") {
+		// data types of snapshots may lack []
+		typ = "repeated " + typ
+	}
+	if dims := arrayDims(col); dims > 1 {
+		return "repeated " + protoBufArrayWrapperName(col, dims-1)
+	}
+	return typ
+}
+
+// protoBufArrayWrapperName returns the name of the wrapper of depth dimensions of col.
+func protoBufArrayWrapperName(col Column, depth int) string {
+	return fmt.Sprintf("%sValues%d", SnakeToUpperCamel(col.Name), depth)
+}
+
+// arrayWrappers returns wrappers of multi-dimensional array columns of table.
+// numeric[][][] is repeated Values2, which holds repeated Values1, which holds repeated string.
+func (gen *ProtoBuf) arrayWrappers(table Table) []ProtoBufArrayWrapper {
+	var ret []ProtoBufArrayWrapper
+	for _, col := range gen.orderedColumns(table) {
+		dims := arrayDims(col)
+		if dims < 2 {
+			continue
+		}
+		typ := strings.TrimPrefix(gen.convertType(col), "repeated ")
+		for depth := 1; depth < dims; depth++ {
+			name := protoBufArrayWrapperName(col, depth)
+			ret = append(ret, ProtoBufArrayWrapper{Name: name, Type: typ})
+			typ = name
+		}
+	}
+	return ret
+}
+
+// composites returns composite types used by columns of table, followed by
+// composite types used by their attributes.
+func (gen *ProtoBuf) composites(table Table) []Type {
+	var ret []Type
+	var names []string
+	var visit func(cols []Column)
+	visit = func(cols []Column) {
+		for _, col := range cols {
+			typ, err := gen.ins.FindComposite(strings.TrimSuffix(col.DataType, "[]"))
+			if err != nil || contains(names, typ.Name) {
+				continue
+			}
+			names = append(names, typ.Name)
+			ret = append(ret, typ)
+			visit(typ.Attributes)
+		}
+	}
+	visit(table.Columns)
+	return ret
+}
+
+// compositeMessages returns nested messages of composite types used by table.
+// Attributes are numbered in order, as composite types can't drop attributes in place.
+func (gen *ProtoBuf) compositeMessages(table Table) []ProtoBufComposite {
+	var ret []ProtoBufComposite
+	for _, typ := range gen.composites(table) {
+		attrs := Table{Name: typ.Name, Columns: typ.Attributes}
+		msg := ProtoBufComposite{Name: SnakeToUpperCamel(typ.Name)}
+		for i, col := range typ.Attributes {
+			m := ProtoBufMember{
+				Name:    col.Name,
+				Type:    gen.fieldType(attrs, col),
+				Comment: strings.Replace(col.Comment.String, "\n", "", -1),
+				Index:   i + 1,
+			}
+			if gen.syntax() == protoBufSyntax2 && !strings.HasPrefix(m.Type, "repeated ") && !strings.HasPrefix(m.Type, "map<") {
+				m.Constraint = "optional"
+			}
+			msg.Members = append(msg.Members, m)
+		}
+		ret = append(ret, msg)
+	}
+	return ret
+}
+
+func (gen *ProtoBuf) jsonMapValue(table Table, col Column) (string, bool) {
+	if col.Array || (col.DataType != "json" && col.DataType != "jsonb") {
+		return "", false
+	}
+	v, ok := gen.config.JsonMaps[table.Name+"."+col.Name]
+	return v, ok
+}
+
+// jsonMapImport returns the file to import if the map value is a message generated from a table.
+func (gen *ProtoBuf) jsonMapImport(table Table, col Column) (string, bool) {
+	v, ok := gen.jsonMapValue(table, col)
+	if !ok {
+		return "", false
+	}
+	return gen.messageImport(table, v)
+}
+
+// messageImport returns the file to import if typ is a message generated from another table.
+func (gen *ProtoBuf) messageImport(table Table, typ string) (string, bool) {
+	for _, t := range gen.ins.TablesAndFunctions() {
+		name := SnakeToUpperCamel(t.Name) + "Message"
+		if typ == name && t.Name != table.Name {
+			return gen.importPath("", name+".proto"), true
+		}
+	}
+	return "", false
+}
+
+func (gen *ProtoBuf) jsonUnion(table Table, col Column) (UnionDef, bool) {
+	if col.Array || (col.DataType != "json" && col.DataType != "jsonb") {
+		return UnionDef{}, false
+	}
+	def, ok := gen.config.JsonUnions[table.Name+"."+col.Name]
+	return def, ok
+}
+
+func protoBufUnionName(col Column) string {
+	return SnakeToUpperCamel(col.Name) + "Union"
+}
+
+// unions returns nested messages of json/jsonb columns of table configured in json_unions.
+// Variants are numbered by numbers of the union, or recorded in field_numbers_file like fields.
+func (gen *ProtoBuf) unions(table Table) []ProtoBufUnion {
+	var ret []ProtoBufUnion
+	for _, col := range gen.orderedColumns(table) {
+		def, ok := gen.jsonUnion(table, col)
+		if !ok {
+			continue
+		}
+		u := ProtoBufUnion{Name: protoBufUnionName(col), Discriminator: protoBufVariantName(def.Discriminator)}
+		var numbers *ProtoBufMessageNumbers
+		if len(def.Numbers) == 0 && gen.numbers != nil {
+			numbers = gen.unionNumbers(table, col)
+		}
+		current := make(map[string]bool)
+		for _, v := range def.VariantValues() {
+			name := protoBufVariantName(v)
+			number := def.Numbers[v]
+			if numbers != nil {
+				number = numbers.number(v, name, 1)
+				current[v] = true
+			}
+			u.Variants = append(u.Variants, ProtoBufMember{
+				Name:    name,
+				Type:    def.Variants[v],
+				Comment: fmt.Sprintf("%s = %q", def.Discriminator, v),
+				Index:   number,
+			})
+		}
+		if numbers != nil {
+			numbers.reserve(current)
+			u.ReservedNumbers, u.ReservedNames = numbers.reservedNumbers(), numbers.reservedNames()
+		}
+		sort.SliceStable(u.Variants, func(a, b int) bool {
+			return u.Variants[a].Index < u.Variants[b].Index
+		})
+		ret = append(ret, u)
+	}
+	return ret
+}
+
+// unionNumbers returns the field numbers of the variants of the union of col, keyed by
+// discriminator value under the key of table followed by the union name.
+func (gen *ProtoBuf) unionNumbers(table Table, col Column) *ProtoBufMessageNumbers {
+	key := table.Name + "." + protoBufUnionName(col)
+	if gen.opts.Schema != "" {
+		key = gen.opts.Schema + "." + key
+	}
+	return gen.numbers.message(key)
+}
+
+// protoBufVariantName returns a field name of the discriminator value v, like page_view of "page-view".
+func protoBufVariantName(v string) string {
+	name := []rune(camelToSnake(v))
+	for i, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_') {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || name[0] >= '0' && name[0] <= '9' {
+		return "v" + string(name)
+	}
+	return string(name)
+}
+
 func (gen *ProtoBuf) members(table Table) []ProtoBufMember {
 	var ret []ProtoBufMember
 
-	for i, col := range table.Columns {
+	index := gen.config.FieldNumberBase
+	if index < 1 {
+		index = 1
+	}
+	base := index
+	var numbers *ProtoBufMessageNumbers
+	if gen.numbers != nil {
+		numbers = gen.messageNumbers(table)
+	}
+	names := make(map[string]bool)
+	columns := make(map[string]bool)
+	for _, col := range gen.orderedColumns(table) {
+		index = skipReservedFieldNumber(index)
+		renamed := renamedColumn(gen.config.ColumnRenames, table.Name, col)
+		name := uniqueFieldName(names, renamed)
+		number := index
+		if numbers != nil {
+			number = numbers.number(col.Name, name, base)
+			columns[col.Name] = true
+		}
 		m := ProtoBufMember{
-			Name:    col.Name,
-			Type:    gen.convertType(col),
-			Comment: strings.Replace(col.Comment.String, "\n", "", -1),
-			Index:   i + 1,
+			Name:    name,
+			Type:    gen.fieldType(table, col),
+			Comment: strings.Replace(gen.comment(table.Name+"."+col.Name, col.Comment.String), "\n", "", -1),
+			Index:   number,
+		}
+		defaultOption := false
+		if gen.syntax() == protoBufSyntax2 && !strings.HasPrefix(m.Type, "repeated ") && !strings.HasPrefix(m.Type, "map<") {
+			m.Constraint = "optional"
+			if def, ok := gen.defaultValue(col, m.Type); ok {
+				m.Options = append(m.Options, "default = "+def)
+				defaultOption = true
+			}
+		}
+		if columnDefault(col) && !defaultOption {
+			// proto3 has no default values
+			m.LeadingComments = append(m.LeadingComments, "default: "+col.DefaultValue.String)
+		}
+		if c, ok := domainComment(gen.ins, col); ok {
+			m.LeadingComments = append(m.LeadingComments, c)
+		}
+		if renamed != col.Name {
+			m.LeadingComments = append(m.LeadingComments, "column: "+col.Name)
+		}
+		if table.IsView && col.Source != "" {
+			m.LeadingComments = append(m.LeadingComments, "from: "+col.Source)
+		}
+		if isPii(gen.config.PiiColumns, table.Name, col) {
+			m.Options = append(m.Options, "(pii) = true")
+		}
+		if gen.config.GenerateProtovalidate {
+			m.Options = append(m.Options, gen.validateRules(col)...)
+		}
+		if isTimestamp(col.DataType) {
+			switch gen.config.TimestampMode {
+			case TimestampModeEpochMillis:
+				m.LeadingComments = append(m.LeadingComments, "epoch milliseconds")
+			case TimestampModeEpochSeconds:
+				m.LeadingComments = append(m.LeadingComments, "epoch seconds")
+			}
+		}
+		if isInterval(col) && gen.intervalType() == "google.protobuf.Duration" {
+			m.LeadingComments = append(m.LeadingComments, intervalDurationNote)
+		}
+		if customStorage(col) {
+			m.LeadingComments = append(m.LeadingComments, storageNote(col))
+		}
+		if col.ForeignKeySrc.Valid {
+			if fk, err := parseForeignKey(col.ForeignKeySrc.String); err == nil {
+				if ref, ok := fk.Reference(col.Name); ok {
+					m.LeadingComments = append(m.LeadingComments,
+						fmt.Sprintf("FK: %s -> %s.%s", col.Name, fk.RefTable, ref))
+				}
+			}
 		}
 		ret = append(ret, m)
+		index++
+	}
+	if numbers != nil {
+		numbers.reserve(columns)
+	}
+	return ret
+}
+
+func (gen *ProtoBuf) syntax() string {
+	if gen.config.Syntax == "" {
+		return protoBufSyntax3
+	}
+	return gen.config.Syntax
+}
+
+// defaultValue returns the proto2 literal of the default value of col, if it is a literal.
+func (gen *ProtoBuf) defaultValue(col Column, fieldType string) (string, bool) {
+	src, ok := literalDefault(col)
+	if !ok {
+		return "", false
 	}
+
+	switch fieldType {
+	case "string":
+		return strconv.Quote(src), true
+	case "bool":
+		if src == "true" || src == "false" {
+			return src, true
+		}
+	case "int32", "int64", "float", "double":
+		if m := regNumberDefault.FindStringSubmatch(src); m != nil {
+			return m[1], true
+		}
+	default:
+		if typ, err := gen.ins.FindType(col.DataType); err == nil && contains(typ.Values, src) {
+			return protoBufEnumValueName(typ.Name, src), true
+		}
+	}
+	return "", false
+}
+
+var protoBufNumericScalars = map[string]bool{
+	"int32": true, "int64": true, "uint32": true, "uint64": true, "sint32": true, "sint64": true,
+	"fixed32": true, "fixed64": true, "sfixed32": true, "sfixed64": true, "float": true, "double": true,
+}
+
+var regSimpleCheck = regexp.MustCompile(`^CHECK \(\((\w+) (>=|<=|>|<|<>|=) \(?(-?[0-9.]+)\)?(::[\w ]+)?\)\)$`)
+
+// validateRules returns protovalidate rules of col.
+// https://github.com/bufbuild/protovalidate
+func (gen *ProtoBuf) validateRules(col Column) []string {
+	var ret []string
+	typ := gen.convertType(col)
+	// proto3 scalars don't have presence, so required rejects zero values like 0, false and "".
+	// Put required only on message fields, whose zero value is unset.
+	if col.NotNull && !col.PrimaryKey && !col.DefaultValue.Valid && !col.Array && strings.Contains(typ, ".") {
+		ret = append(ret, "(buf.validate.field).required = true")
+	}
+	if n, ok := characterLength(col.DataType); ok && !col.Array {
+		ret = append(ret, fmt.Sprintf("(buf.validate.field).string.max_len = %d", n))
+	}
+	// Only simple comparisons with a number can be translated to CEL, on fields of numbers.
+	if col.Constraint.String == "c" && protoBufNumericScalars[typ] {
+		if m := regSimpleCheck.FindStringSubmatch(col.ConstraintSrc.String); m != nil && m[1] == col.Name {
+			op := m[2]
+			if op == "=" {
+				op = "=="
+			} else if op == "<>" {
+				op = "!="
+			}
+			ret = append(ret, fmt.Sprintf(`(buf.validate.field).cel = { id: "%s_check", expression: "this %s %s" }`,
+				col.Name, op, m[3]))
+		}
+	}
+	return ret
+}
+
+// orderedColumns returns columns of table in the field number order.
+func (gen *ProtoBuf) orderedColumns(table Table) []Column {
+	if !gen.config.PkFieldsFirst {
+		return table.Columns
+	}
+	var pks, others []Column
+	for _, col := range table.Columns {
+		if col.PrimaryKey {
+			pks = append(pks, col)
+		} else {
+			others = append(others, col)
+		}
+	}
+	return append(pks, others...)
+}
+
+// uniqueFieldName returns name, or name with a numbered suffix if it is already used.
+// Columns of a view joining several tables may have the same name.
+func uniqueFieldName(used map[string]bool, name string) string {
+	ret := name
+	for i := 2; used[ret]; i++ {
+		ret = fmt.Sprintf("%s_%d", name, i)
+	}
+	used[ret] = true
 	return ret
 }
 
+// skipReservedFieldNumber returns the next usable field number from n.
+func skipReservedFieldNumber(n int) int {
+	if n >= protoBufReservedFieldNumberFrom && n <= protoBufReservedFieldNumberTo {
+		return protoBufReservedFieldNumberTo + 1
+	}
+	return n
+}
+
 func (gen *ProtoBuf) buildType(wr io.Writer, types []Type) error {
 	var members []ProtoBufTypeMember
 	for _, typ := range types {
-		name := SnakeToUpper(typ.Name)
+		if err := gen.checkEnumAliases(typ); err != nil {
+			return err
+		}
 		var vs []string
+		allowAlias := false
 		for i, val := range typ.Values {
-			if isNumber(val) {
-				vs = append(vs, fmt.Sprintf("%s_VALUE_%s = %d;", name, SnakeToUpper(val), i))
-			} else {
-				vs = append(vs, fmt.Sprintf("%s_%s = %d;", name, SnakeToUpper(val), i))
+			v := fmt.Sprintf("%s = %d;", protoBufEnumValueName(typ.Name, val), i)
+			if c, ok := gen.config.EnumValueComments[typ.Name][val]; ok {
+				v += " // " + strings.Replace(c, "\n", " ", -1)
+			}
+			vs = append(vs, v)
+			for _, alias := range gen.config.EnumAliases[typ.Name+"."+val] {
+				vs = append(vs, fmt.Sprintf("%s = %d; // alias of %s", protoBufEnumValueName(typ.Name, alias), i, val))
+				allowAlias = true
 			}
 		}
 		m := ProtoBufTypeMember{
-			Name:    SnakeToUpperCamel(typ.Name),
-			Comment: typ.Comment.String,
-			Values:  "  " + strings.Join(vs, "\n  "),
+			Name:       SnakeToUpperCamel(typ.Name),
+			Comment:    typ.Comment.String,
+			Values:     "  " + strings.Join(vs, "\n  "),
+			AllowAlias: allowAlias,
 		}
 		members = append(members, m)
 	}
 
 	return gen.template.ExecuteTemplate(wr, "enum", map[string]interface{}{
-		"package_name": gen.config.PackageName,
+		"extra":        gen.config.TemplateData,
+		"stamp":        gen.opts.Stamp,
+		"syntax":       gen.syntax(),
+		"package_name": gen.packageName(),
 		"java_package": gen.config.JavaPackage,
 		"go_package":   gen.config.GoPackage,
 		"now":          time.Now().UTC().Format(time.RFC3339),
 		"members":      members,
 	})
+}
+
+// checkEnumAliases returns an error if enum_aliases of typ refers to an unknown value,
+// or an alias has the name of another value.
+func (gen *ProtoBuf) checkEnumAliases(typ Type) error {
+	names := make(map[string]bool)
+	for _, val := range typ.Values {
+		names[protoBufEnumValueName(typ.Name, val)] = true
+	}
+	for key, aliases := range gen.config.EnumAliases {
+		i := strings.Index(key, ".")
+		if i < 0 || key[:i] != typ.Name {
+			continue
+		}
+		if !contains(typ.Values, key[i+1:]) {
+			return fmt.Errorf("enum_aliases: %s is not a value of %s", key[i+1:], typ.Name)
+		}
+		for _, alias := range aliases {
+			name := protoBufEnumValueName(typ.Name, alias)
+			if names[name] {
+				return fmt.Errorf("enum_aliases: %s of %s is already defined", alias, key)
+			}
+			names[name] = true
+		}
+	}
 	return nil
 }
 
+// protoBufEnumValueName returns the name of the enum value val of the type typName.
+func protoBufEnumValueName(typName, val string) string {
+	if isNumber(val) {
+		return SnakeToUpper(typName) + "_VALUE_" + SnakeToUpper(val)
+	}
+	return SnakeToUpper(typName) + "_" + SnakeToUpper(val)
+}
+
 func (gen *ProtoBuf) enumExists(typeName string) bool {
 	for _, typ := range gen.ins.Types {
 		if typ.Name == typeName {
@@ -186,11 +1206,15 @@ func (gen *ProtoBuf) convertType(col Column) string {
 		array = "repeated "
 		col.DataType = strings.Replace(col.DataType, "[]", "", 1)
 	}
+	if t, ok := overrideType(gen.config.TypeOverrides, col); ok {
+		return array + t
+	}
+	col = resolveDomain(gen.ins, col)
 
 	switch col.DataType {
 	case "text":
 		return array + "string"
-	case "int", "integer":
+	case "smallint", "int2", "int", "integer":
 		return array + "int32"
 	case "float":
 		return array + "float"
@@ -198,7 +1222,7 @@ func (gen *ProtoBuf) convertType(col Column) string {
 		return array + "double"
 	case "bigint":
 		return array + "int64"
-	case "serial":
+	case "smallserial", "serial":
 		return array + "int32"
 	case "bigserial":
 		return array + "int64"
@@ -207,26 +1231,36 @@ func (gen *ProtoBuf) convertType(col Column) string {
 	case "bytea":
 		return array + "bytes"
 	case "numeric":
-		if gen.config.UseStringToNumeric {
-			return array + "string"
-		}
-		return array + "int64"
+		return array + gen.numericType(col)
 	case "date":
+		if gen.config.UseWKT {
+			return array + "google.type.Date"
+		}
 		return array + "string"
 	case "boolean":
 		return array + "bool"
 	case "json", "jsonb":
-		return array + "map<string, string>"
+		if array != "" {
+			// map fields can't be repeated
+			return array + "string"
+		}
+		return "map<string, string>"
+	case "int2vector", "oidvector":
+		// space separated numbers like "1 2"
+		return array + "string"
 	default:
-		// "timestamp with time zone", "timestamp without time zone", "timestamp(n) with time zone"
-		if strings.HasSuffix(col.DataType, "time zone") {
-			return array + "google.protobuf.Timestamp"
+		if isTimestamp(col.DataType) {
+			return array + gen.timestampType()
+		}
+		// "time", "timetz", "time(n) with time zone" are times of day like "10:30:00+09"
+		if strings.HasPrefix(col.DataType, "time") {
+			return array + "string"
+		}
+		if isInterval(col) {
+			return array + gen.intervalType()
 		}
 		if strings.HasPrefix(col.DataType, "numeric") {
-			if gen.config.UseStringToNumeric {
-				return array + "string"
-			}
-			return array + "int64"
+			return array + gen.numericType(col)
 		}
 		if strings.HasPrefix(col.DataType, "character") {
 			return array + "string"
@@ -234,20 +1268,110 @@ func (gen *ProtoBuf) convertType(col Column) string {
 
 		typ, err := gen.ins.FindType(col.DataType)
 		if err == nil {
-			return array + gen.config.PackageName + "." + SnakeToUpperCamel(typ.Name)
+			return array + gen.packageName() + "." + SnakeToUpperCamel(typ.Name)
+		}
+		// nested message of the composite type
+		if typ, err := gen.ins.FindComposite(col.DataType); err == nil {
+			return array + SnakeToUpperCamel(typ.Name)
 		}
 	}
 	return array + col.DataType
 }
 
+func (gen *ProtoBuf) intervalType() string {
+	if gen.config.IntervalMode == IntervalModeDuration || (gen.config.IntervalMode == "" && gen.config.UseWKT) {
+		return "google.protobuf.Duration"
+	}
+	// text output of PostgreSQL like "1 day 02:00:00"
+	return "string"
+}
+
+// isTimestamp reports whether dataType is "timestamp", "timestamp with time zone",
+// "timestamp(n) without time zone" or so, but not "time with time zone".
+func isTimestamp(dataType string) bool {
+	return strings.HasPrefix(dataType, "timestamp")
+}
+
+func (gen *ProtoBuf) timestampType() string {
+	switch gen.config.TimestampMode {
+	case TimestampModeString:
+		return "string"
+	case TimestampModeEpochMillis, TimestampModeEpochSeconds:
+		return "int64"
+	}
+	return "google.protobuf.Timestamp"
+}
+
+// numericType returns the type of numeric col. Only numeric(p,0) fitting in int64 is integral,
+// others keep the exact decimal as string unless NumericAsDouble.
+func (gen *ProtoBuf) numericType(col Column) string {
+	if gen.config.UseStringToNumeric {
+		return "string"
+	}
+	if p, s, ok := numericTypmod(col); ok && s == 0 {
+		if p <= maxInt64Digits {
+			return "int64"
+		}
+		return "string"
+	}
+	if gen.config.NumericAsDouble {
+		return "double"
+	}
+	return "string"
+}
+
 func loadProtoBufConfig(root string, raw json.RawMessage) (ProtoBufConfig, error) {
 	var pbc ProtoBufConfig
 	if err := json.Unmarshal(raw, &pbc); err != nil {
 		return pbc, fmt.Errorf("protobuf config error: %s", err)
 	}
+	if err := applyEnvOverrides(ProtoBufTypeName, &pbc); err != nil {
+		return pbc, fmt.Errorf("protobuf config error: %s", err)
+	}
 	output := filePathJoinRoot(root, pbc.Output)
 	if err := DirExists(output); err != nil {
 		return pbc, fmt.Errorf("protobuf output is not exists: %s", pbc.Output)
 	}
+	if err := checkGlobs(pbc.IncludeTables); err != nil {
+		return pbc, fmt.Errorf("protobuf include_tables: %s", err)
+	}
+	if err := checkIgnores(pbc.IgnoreTables); err != nil {
+		return pbc, fmt.Errorf("protobuf ignore_tables: %s", err)
+	}
+	switch pbc.Syntax {
+	case "", protoBufSyntax2, protoBufSyntax3:
+	default:
+		return pbc, fmt.Errorf("protobuf syntax is unknown: %s", pbc.Syntax)
+	}
+	switch pbc.TimestampMode {
+	case "", TimestampModeWKT, TimestampModeString, TimestampModeEpochMillis, TimestampModeEpochSeconds:
+	default:
+		return pbc, fmt.Errorf("protobuf timestamp_mode is unknown: %s", pbc.TimestampMode)
+	}
+	if pbc.UseWKT && pbc.TimestampMode != "" && pbc.TimestampMode != TimestampModeWKT {
+		return pbc, fmt.Errorf("protobuf use_wkt conflicts with timestamp_mode: %s", pbc.TimestampMode)
+	}
+	switch pbc.IntervalMode {
+	case "", IntervalModeString, IntervalModeDuration:
+	default:
+		return pbc, fmt.Errorf("protobuf interval_mode is unknown: %s", pbc.IntervalMode)
+	}
+	if pbc.Concurrency < 0 {
+		return pbc, fmt.Errorf("protobuf concurrency must not be negative: %d", pbc.Concurrency)
+	}
+	if err := checkJsonUnions(pbc.JsonUnions); err != nil {
+		return pbc, fmt.Errorf("protobuf config error: %s", err)
+	}
+	for key, def := range pbc.JsonUnions {
+		if _, ok := pbc.JsonMaps[key]; ok {
+			return pbc, fmt.Errorf("protobuf %s is in both json_maps and json_unions", key)
+		}
+		if len(def.Numbers) == 0 && pbc.FieldNumbersFile == "" {
+			return pbc, fmt.Errorf("protobuf json_unions %s: numbers are required without field_numbers_file", key)
+		}
+	}
+	if pbc.FieldNumberBase < 0 || pbc.FieldNumberBase > protoBufMaxFieldNumber {
+		return pbc, fmt.Errorf("protobuf field_number_base is out of range: %d", pbc.FieldNumberBase)
+	}
 	return pbc, nil
 }
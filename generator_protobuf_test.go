@@ -0,0 +1,1631 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func renderProtoBufMessage(t *testing.T, gen *ProtoBuf, table Table) string {
+	gen.template = template.Must(template.ParseGlob("templates/protobuf/*.tmpl"))
+	var buf bytes.Buffer
+	if err := gen.buildTable(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestProtoBufTemplateData(t *testing.T) {
+	gen := ProtoBuf{
+		config: ProtoBufConfig{TemplateData: map[string]interface{}{"header": "// Copyright Example Inc.", "name": "Clobbered"}},
+	}
+	gen.template = template.Must(template.ParseGlob("templates/protobuf/*.tmpl"))
+	template.Must(gen.template.New("message").Parse(`{{ .extra.header }}
+message {{ .name }} {}`))
+
+	var buf bytes.Buffer
+	if err := gen.buildTable(&buf, Table{Name: "users", Columns: []Column{Column{Name: "id", DataType: "integer"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "// Copyright Example Inc.\nmessage UsersMessage {}"; buf.String() != expected {
+		t.Errorf("expected %q, actual: %q", expected, buf.String())
+	}
+}
+
+func TestProtoBufColumnRenames(t *testing.T) {
+	gen := ProtoBuf{
+		config: ProtoBufConfig{ColumnRenames: map[string]string{"users.usr_nm": "userName"}},
+	}
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer"},
+			Column{Name: "usr_nm", DataType: "text"},
+		},
+	}
+	out := renderProtoBufMessage(t, &gen, table)
+	if !strings.Contains(out, " // column: usr_nm\n  string user_name = 2;") {
+		t.Errorf("renamed field is missing:\n%s", out)
+	}
+
+	table.Columns = append(table.Columns, Column{Name: "UserName", DataType: "text"})
+	gen.template = template.Must(template.ParseGlob("templates/protobuf/*.tmpl"))
+	var buf bytes.Buffer
+	if err := gen.buildTable(&buf, table); err == nil {
+		t.Error("expected an error of user_name colliding with UserName")
+	}
+}
+
+func TestProtoBufFieldNumberBase(t *testing.T) {
+	table := Table{
+		Name: "foo",
+		Columns: []Column{
+			Column{Name: "a", DataType: "text"},
+			Column{Name: "b", DataType: "text"},
+			Column{Name: "c", DataType: "text"},
+		},
+	}
+
+	gen := ProtoBuf{}
+	for i, m := range gen.members(table) {
+		if m.Index != i+1 {
+			t.Errorf("expected: %d, actual: %d", i+1, m.Index)
+		}
+	}
+
+	gen = ProtoBuf{config: ProtoBufConfig{FieldNumberBase: 16}}
+	for i, m := range gen.members(table) {
+		if m.Index != i+16 {
+			t.Errorf("expected: %d, actual: %d", i+16, m.Index)
+		}
+	}
+}
+
+func TestProtoBufFieldNumberSkipReserved(t *testing.T) {
+	table := Table{
+		Name: "foo",
+		Columns: []Column{
+			Column{Name: "a", DataType: "text"},
+			Column{Name: "b", DataType: "text"},
+			Column{Name: "c", DataType: "text"},
+		},
+	}
+
+	gen := ProtoBuf{config: ProtoBufConfig{FieldNumberBase: 18999}}
+	expected := []int{18999, 20000, 20001}
+	for i, m := range gen.members(table) {
+		if m.Index != expected[i] {
+			t.Errorf("expected: %d, actual: %d", expected[i], m.Index)
+		}
+	}
+
+	gen = ProtoBuf{config: ProtoBufConfig{FieldNumberBase: 19500}}
+	if m := gen.members(table)[0]; m.Index != 20000 {
+		t.Errorf("expected: %d, actual: %d", 20000, m.Index)
+	}
+}
+
+func TestProtoBufPiiOption(t *testing.T) {
+	gen := ProtoBuf{
+		config: ProtoBufConfig{
+			PiiColumns: []string{"users.email"},
+			PiiImport:  "pii.proto",
+		},
+	}
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer"},
+			Column{Name: "email", DataType: "text"},
+		},
+	}
+	members := gen.members(table)
+	if actual := members[0].FieldOptions(); actual != "" {
+		t.Errorf("unexpected option: %s", actual)
+	}
+	if actual := members[1].FieldOptions(); actual != " [(pii) = true]" {
+		t.Errorf("unexpected option: %s", actual)
+	}
+	if imports := gen.imports(table); !contains(imports, "pii.proto") {
+		t.Errorf("pii import is missing: %v", imports)
+	}
+}
+
+func TestProtoBufForeignKeyComment(t *testing.T) {
+	gen := ProtoBuf{}
+	table := Table{
+		Name: "orders",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer"},
+			Column{
+				Name:          "customer_id",
+				DataType:      "integer",
+				ForeignKeySrc: sql.NullString{String: "FOREIGN KEY (customer_id) REFERENCES customers(id)", Valid: true},
+			},
+		},
+	}
+	members := gen.members(table)
+	if len(members[0].LeadingComments) != 0 {
+		t.Errorf("unexpected comments: %v", members[0].LeadingComments)
+	}
+	if !contains(members[1].LeadingComments, "FK: customer_id -> customers.id") {
+		t.Errorf("FK comment is missing: %v", members[1].LeadingComments)
+	}
+
+	out := renderProtoBufMessage(t, &gen, table)
+	if !strings.Contains(out, " // FK: customer_id -> customers.id\n  int32 customer_id = 2;") {
+		t.Errorf("FK comment is not rendered:\n%s", out)
+	}
+}
+
+func TestProtoBufUniqueFieldName(t *testing.T) {
+	gen := ProtoBuf{}
+	table := Table{
+		Name: "order_summary",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer"},
+			Column{Name: "name", DataType: "text"},
+			Column{Name: "id", DataType: "integer"},
+			Column{Name: "id_2", DataType: "integer"},
+			Column{Name: "id", DataType: "integer"},
+		},
+	}
+	expected := []string{"id", "name", "id_2", "id_2_2", "id_3"}
+	for n := 0; n < 2; n++ {
+		for i, m := range gen.members(table) {
+			if m.Name != expected[i] {
+				t.Errorf("expected: %s, actual: %s", expected[i], m.Name)
+			}
+			if m.Index != i+1 {
+				t.Errorf("expected: %d, actual: %d", i+1, m.Index)
+			}
+		}
+	}
+}
+
+func TestProtoBufProtovalidate(t *testing.T) {
+	gen := ProtoBuf{
+		config: ProtoBufConfig{GenerateProtovalidate: true},
+	}
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer", NotNull: true, PrimaryKey: true},
+			Column{Name: "name", DataType: "character varying(255)", NotNull: true},
+			Column{Name: "nickname", DataType: "character varying(32)"},
+			Column{Name: "active", DataType: "boolean", NotNull: true},
+			Column{
+				Name:          "age",
+				DataType:      "integer",
+				Constraint:    sql.NullString{String: "c", Valid: true},
+				ConstraintSrc: sql.NullString{String: "CHECK ((age >= 0))", Valid: true},
+			},
+			Column{Name: "created_at", DataType: "timestamp with time zone", NotNull: true},
+			Column{Name: "avatar", DataType: "bytea", NotNull: true},
+			Column{
+				Name:          "price",
+				DataType:      "numeric",
+				Constraint:    sql.NullString{String: "c", Valid: true},
+				ConstraintSrc: sql.NullString{String: "CHECK ((price > (0)::numeric))", Valid: true},
+			},
+		},
+	}
+	expected := []string{
+		"",
+		" [(buf.validate.field).string.max_len = 255]",
+		" [(buf.validate.field).string.max_len = 32]",
+		"",
+		` [(buf.validate.field).cel = { id: "age_check", expression: "this >= 0" }]`,
+		" [(buf.validate.field).required = true]",
+		"",
+		"", // numeric is a string
+	}
+	for i, m := range gen.members(table) {
+		if actual := m.FieldOptions(); actual != expected[i] {
+			t.Errorf("%s: expected %s, actual: %s", m.Name, expected[i], actual)
+		}
+	}
+	if !contains(gen.imports(table), "buf/validate/validate.proto") {
+		t.Errorf("validate import is missing: %v", gen.imports(table))
+	}
+
+	gen.config.GenerateProtovalidate = false
+	if actual := gen.members(table)[5].FieldOptions(); actual != "" {
+		t.Errorf("unexpected option: %s", actual)
+	}
+}
+
+func TestProtoBufConvertType(t *testing.T) {
+	gen := ProtoBuf{}
+	ff := [][]string{
+		[]string{"uuid", "string"},
+		[]string{"uuid[]", "repeated string"},
+		[]string{"int2vector", "string"},
+		[]string{"oidvector", "string"},
+		[]string{"smallint", "int32"},
+		[]string{"int2", "int32"},
+		[]string{"smallserial", "int32"},
+		[]string{"smallint[]", "repeated int32"},
+	}
+	for _, d := range ff {
+		col := Column{
+			DataType: d[0],
+			Array:    strings.HasSuffix(d[0], "[]"),
+		}
+		if actual := gen.convertType(col); actual != d[1] {
+			t.Errorf("%s: expected %s, actual: %s", d[0], d[1], actual)
+		}
+	}
+}
+
+func TestProtoBufBytea(t *testing.T) {
+	gen := ProtoBuf{}
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "avatar", DataType: "bytea"},
+			Column{Name: "thumbnails", DataType: "bytea[]", Array: true},
+		},
+	}
+	out := renderProtoBufMessage(t, &gen, table)
+	for _, s := range []string{" bytes avatar = 1;", " repeated bytes thumbnails = 2;"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%s is missing:\n%s", s, out)
+		}
+	}
+}
+
+func TestProtoBufNumeric(t *testing.T) {
+	ff := []struct {
+		col      Column
+		double   bool
+		expected string
+	}{
+		{Column{DataType: "numeric(10,2)", NumericPrecision: 10, NumericScale: 2}, false, "string"},
+		{Column{DataType: "numeric(10,2)", NumericPrecision: 10, NumericScale: 2}, true, "double"},
+		{Column{DataType: "numeric"}, false, "string"},
+		{Column{DataType: "numeric"}, true, "double"},
+		{Column{DataType: "numeric(8,0)", NumericPrecision: 8}, false, "int64"},
+		{Column{DataType: "numeric(8,0)"}, false, "int64"},
+		{Column{DataType: "numeric(30,0)", NumericPrecision: 30}, true, "string"},
+		{Column{DataType: "numeric(8,0)[]", NumericPrecision: 8, Array: true}, false, "repeated int64"},
+	}
+	for _, f := range ff {
+		gen := ProtoBuf{config: ProtoBufConfig{NumericAsDouble: f.double}}
+		if actual := gen.convertType(f.col); actual != f.expected {
+			t.Errorf("%s double %t: expected %s, actual: %s", f.col.DataType, f.double, f.expected, actual)
+		}
+	}
+
+	gen := ProtoBuf{config: ProtoBufConfig{UseStringToNumeric: true}}
+	if actual := gen.convertType(Column{DataType: "numeric(8,0)", NumericPrecision: 8}); actual != "string" {
+		t.Errorf("use_string_to_numeric: expected string, actual: %s", actual)
+	}
+}
+
+func TestProtoBufTypeOverrides(t *testing.T) {
+	gen := ProtoBuf{
+		config: ProtoBufConfig{TypeOverrides: map[string]string{"citext": "string", "geometry": "bytes", "bigint": "string"}},
+	}
+	ff := [][]string{
+		[]string{"citext", "string"},
+		[]string{"geometry", "bytes"},
+		[]string{"geometry[]", "repeated bytes"},
+		[]string{"bigint", "string"},
+		[]string{"integer", "int32"},
+	}
+	for _, d := range ff {
+		if actual := gen.convertType(Column{DataType: d[0]}); actual != d[1] {
+			t.Errorf("%s: expected %s, actual: %s", d[0], d[1], actual)
+		}
+	}
+}
+
+func TestProtoBufRepeated(t *testing.T) {
+	gen := ProtoBuf{
+		config: ProtoBufConfig{PackageName: "example"},
+		ins: InspectResult{
+			Types: []Type{Type{Name: "my_enum", Values: []string{"a", "b"}}},
+		},
+	}
+	table := Table{
+		Name: "posts",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer"},
+			Column{Name: "tags", DataType: "text[]", Array: true},
+			Column{Name: "statuses", DataType: "my_enum[]", Array: true},
+			Column{Name: "scores", DataType: "integer", Array: true},
+			Column{Name: "docs", DataType: "jsonb[]", Array: true},
+			Column{Name: "title", DataType: "text"},
+		},
+	}
+	expected := [][]string{
+		[]string{"int32", "1"},
+		[]string{"repeated string", "2"},
+		[]string{"repeated example.MyEnum", "3"},
+		[]string{"repeated int32", "4"},
+		[]string{"repeated string", "5"},
+		[]string{"string", "6"},
+	}
+	for i, m := range gen.members(table) {
+		if m.Type != expected[i][0] || strconv.Itoa(m.Index) != expected[i][1] {
+			t.Errorf("%s: expected %s = %s, actual: %s = %d", m.Name, expected[i][0], expected[i][1], m.Type, m.Index)
+		}
+	}
+}
+
+func TestProtoBufUseWKT(t *testing.T) {
+	table := Table{
+		Name: "events",
+		Columns: []Column{
+			Column{Name: "day", DataType: "date"},
+			Column{Name: "days", DataType: "date[]", Array: true},
+			Column{Name: "created_at", DataType: "timestamp with time zone"},
+			Column{Name: "updated_at", DataType: "timestamp without time zone"},
+			Column{Name: "seen_at", DataType: "timestamp"},
+		},
+	}
+	gen := ProtoBuf{config: ProtoBufConfig{TimestampMode: TimestampModeString}}
+	for _, m := range gen.members(table) {
+		if m.Type != "string" && m.Type != "repeated string" {
+			t.Errorf("%s: expected string, actual: %s", m.Name, m.Type)
+		}
+	}
+
+	gen = ProtoBuf{config: ProtoBufConfig{UseWKT: true}}
+	expected := []string{"google.type.Date", "repeated google.type.Date",
+		"google.protobuf.Timestamp", "google.protobuf.Timestamp", "google.protobuf.Timestamp"}
+	for i, m := range gen.members(table) {
+		if m.Type != expected[i] {
+			t.Errorf("%s: expected %s, actual: %s", m.Name, expected[i], m.Type)
+		}
+	}
+	out := renderProtoBufMessage(t, &gen, table)
+	for _, path := range []string{timestampImport, dateImport} {
+		if n := strings.Count(out, `import "`+path+`";`); n != 1 {
+			t.Errorf("%s is imported %d times:\n%s", path, n, out)
+		}
+	}
+
+	if _, err := loadProtoBufConfig(".", []byte(`{"output": ".", "use_wkt": true, "timestamp_mode": "string"}`)); err == nil {
+		t.Error("use_wkt with timestamp_mode string should be error")
+	}
+}
+
+func TestProtoBufInterval(t *testing.T) {
+	table := Table{
+		Name: "plans",
+		Columns: []Column{
+			Column{Name: "period", DataType: "interval"},
+			Column{Name: "grace", DataType: "interval day to second(3)"},
+		},
+	}
+	ff := []struct {
+		config   ProtoBufConfig
+		expected string
+	}{
+		{ProtoBufConfig{}, "string"},
+		{ProtoBufConfig{IntervalMode: IntervalModeString, UseWKT: true}, "string"},
+		{ProtoBufConfig{IntervalMode: IntervalModeDuration}, "google.protobuf.Duration"},
+		{ProtoBufConfig{UseWKT: true}, "google.protobuf.Duration"},
+	}
+	for _, f := range ff {
+		gen := ProtoBuf{config: f.config}
+		for _, m := range gen.members(table) {
+			if m.Type != f.expected {
+				t.Errorf("%+v: %s: expected %s, actual: %s", f.config, m.Name, f.expected, m.Type)
+			}
+			if noted := contains(m.LeadingComments, intervalDurationNote); noted != (f.expected != "string") {
+				t.Errorf("%+v: %s: precision note %t: %v", f.config, m.Name, noted, m.LeadingComments)
+			}
+		}
+	}
+
+	gen := ProtoBuf{config: ProtoBufConfig{IntervalMode: IntervalModeDuration}}
+	if out := renderProtoBufMessage(t, &gen, table); strings.Count(out, `import "`+durationImport+`";`) != 1 {
+		t.Errorf("%s should be imported once:\n%s", durationImport, out)
+	}
+	if _, err := loadProtoBufConfig(".", []byte(`{"output": ".", "interval_mode": "iso8601"}`)); err == nil {
+		t.Error("unknown interval_mode should be error")
+	}
+}
+
+func TestProtoBufMultiDimensionalArray(t *testing.T) {
+	gen := ProtoBuf{}
+	table := Table{
+		Name: "grids",
+		Columns: []Column{
+			Column{Name: "matrix", DataType: "numeric[]", Array: true, ArrayDims: 2},
+			Column{Name: "cube", DataType: "integer[]", Array: true, ArrayDims: 3},
+			Column{Name: "tags", DataType: "text[]", Array: true, ArrayDims: 1},
+		},
+	}
+	out := renderProtoBufMessage(t, &gen, table)
+	for _, s := range []string{
+		"  message MatrixValues1 {\n    repeated string values = 1;\n  }\n",
+		"  message CubeValues1 {\n    repeated int32 values = 1;\n  }\n",
+		"  message CubeValues2 {\n    repeated CubeValues1 values = 1;\n  }\n",
+		"  repeated MatrixValues1 matrix = 1;",
+		"  repeated CubeValues2 cube = 2;",
+		"  repeated string tags = 3;",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%s is missing:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "TagsValues") {
+		t.Errorf("one dimensional array should not be wrapped:\n%s", out)
+	}
+}
+
+func TestProtoBufDomainType(t *testing.T) {
+	gen := ProtoBuf{
+		ins: InspectResult{Domains: []Type{
+			Type{DataType: "d", Name: "email", BaseType: "character varying(255)", Check: sql.NullString{String: "CHECK (VALUE ~~ '%@%'::text)", Valid: true}},
+			Type{DataType: "d", Name: "quantity", BaseType: "integer"},
+		}},
+	}
+	ff := [][]string{
+		[]string{"email", "string"},
+		[]string{"email[]", "repeated string"},
+		[]string{"quantity", "int32"},
+	}
+	for _, d := range ff {
+		if actual := gen.convertType(Column{DataType: d[0]}); actual != d[1] {
+			t.Errorf("%s: expected %s, actual: %s", d[0], d[1], actual)
+		}
+	}
+
+	out := renderProtoBufMessage(t, &gen, Table{Name: "users", Columns: []Column{Column{Name: "email", DataType: "email"}}})
+	if !strings.Contains(out, " // domain email: CHECK (VALUE ~~ '%@%'::text)\n  string email = 1;") {
+		t.Errorf("domain comment is missing:\n%s", out)
+	}
+}
+
+func TestProtoBufCompositeType(t *testing.T) {
+	gen := ProtoBuf{
+		config: ProtoBufConfig{PackageName: "example"},
+		ins: InspectResult{Composites: []Type{
+			Type{DataType: "c", Name: "address", Attributes: []Column{
+				Column{Name: "street", DataType: "text"},
+				Column{Name: "location", DataType: "geo_point"},
+			}},
+			Type{DataType: "c", Name: "geo_point", Attributes: []Column{
+				Column{Name: "lat", DataType: "double precision"},
+				Column{Name: "lng", DataType: "double precision"},
+			}},
+		}},
+	}
+	table := Table{
+		Name: "shops",
+		Columns: []Column{
+			Column{Name: "id", DataType: "bigint"},
+			Column{Name: "address", DataType: "address"},
+			Column{Name: "branches", DataType: "address[]", Array: true},
+		},
+	}
+	out := renderProtoBufMessage(t, &gen, table)
+	for _, s := range []string{
+		"  message Address {\n    string street = 1; // \n    GeoPoint location = 2; // \n  }",
+		"  message GeoPoint {\n    double lat = 1; // \n    double lng = 2; // \n  }",
+		"  Address address = 2;",
+		"  repeated Address branches = 3;",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%s is missing:\n%s", s, out)
+		}
+	}
+	if strings.Count(out, "message Address {") != 1 {
+		t.Errorf("Address should be nested once:\n%s", out)
+	}
+}
+
+func TestProtoBufViews(t *testing.T) {
+	dir := t.TempDir()
+	ins := InspectResult{
+		Tables: []Table{Table{Name: "users", Columns: []Column{Column{Name: "id", DataType: "bigint"}}}},
+		Views:  []Table{Table{Name: "active_users", IsView: true, Columns: []Column{Column{Name: "id", DataType: "bigint"}}}},
+	}
+	for _, views := range []bool{false, true} {
+		gen := ProtoBuf{
+			config: ProtoBufConfig{Output: dir, Templates: "templates/protobuf", GenerateViews: views},
+			root:   ".",
+		}
+		os.Remove(filepath.Join(dir, "ActiveUsersMessage.proto"))
+		if err := gen.Build(ins, BuildOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := os.Stat(filepath.Join(dir, "ActiveUsersMessage.proto"))
+		if actual := err == nil; actual != views {
+			t.Errorf("generate_views %t: message of view exists: %t", views, actual)
+		}
+	}
+}
+
+func TestProtoBufViewColumnSource(t *testing.T) {
+	gen := ProtoBuf{config: ProtoBufConfig{Templates: "templates/protobuf"}, root: "."}
+	view := Table{
+		Name:   "order_summaries",
+		IsView: true,
+		Columns: []Column{
+			Column{Name: "id", DataType: "bigint"},
+			Column{Name: "total", DataType: "integer", Source: "orders.total"},
+		},
+	}
+	actual := renderProtoBufMessage(t, &gen, view)
+	if !strings.Contains(actual, "// from: orders.total\n  int32 total = 2;") {
+		t.Errorf("expected source comment:\n%s", actual)
+	}
+	if strings.Count(actual, "// from:") != 1 {
+		t.Errorf("unattributed column should have no source comment:\n%s", actual)
+	}
+}
+
+func TestProtoBufCheckComment(t *testing.T) {
+	gen := ProtoBuf{config: ProtoBufConfig{Templates: "templates/protobuf"}, root: "."}
+	table := Table{
+		Name: "campaigns",
+		Columns: []Column{
+			Column{Name: "start_date", DataType: "date"},
+			Column{Name: "end_date", DataType: "date"},
+		},
+		TableChecks: []Check{Check{Name: "campaigns_check", Expression: "start_date < end_date"}},
+	}
+	actual := renderProtoBufMessage(t, &gen, table)
+	if !strings.Contains(actual, "//  CHECK campaigns_check: start_date < end_date\n//\nmessage") {
+		t.Errorf("expected check comment:\n%s", actual)
+	}
+}
+
+func TestProtoBufOverwrites(t *testing.T) {
+	dir := t.TempDir()
+	const edited = "// edited by hand\n"
+	for _, name := range []string{"UsersMessage.proto", "enum.proto"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(edited), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gen := ProtoBuf{
+		config: ProtoBufConfig{Output: dir, Templates: "templates/protobuf", Overwrites: []string{"enum.proto"}},
+		root:   ".",
+	}
+	ins := InspectResult{Tables: []Table{Table{Name: "users", Columns: []Column{Column{Name: "id", DataType: "bigint"}}}}}
+	if err := gen.Build(ins, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for name, kept := range map[string]bool{"UsersMessage.proto": true, "enum.proto": false} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := string(b) == edited; actual != kept {
+			t.Errorf("%s: expected kept %t, actual: %t", name, kept, actual)
+		}
+	}
+}
+
+func TestProtoBufPostProcess(t *testing.T) {
+	dir := t.TempDir()
+	gen := ProtoBuf{
+		config: ProtoBufConfig{Output: dir, Templates: "templates/protobuf", PackageName: "example"},
+		root:   ".",
+	}
+	var processed []string
+	opts := BuildOptions{
+		PostProcess: func(file string, content []byte) ([]byte, error) {
+			processed = append(processed, filepath.Base(file))
+			return bytes.Replace(content, []byte("package example;"), []byte("package EXAMPLE;"), 1), nil
+		},
+	}
+	ins := InspectResult{Tables: []Table{Table{Name: "users", Columns: []Column{Column{Name: "id", DataType: "bigint"}}}}}
+	if err := gen.Build(ins, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(processed, []string{"UsersMessage.proto", "enum.proto"}) {
+		t.Errorf("unexpected processed files: %v", processed)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "UsersMessage.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "package EXAMPLE;") || strings.Contains(string(b), "package example;") {
+		t.Errorf("post processed content should be written:\n%s", b)
+	}
+
+	opts.PostProcess = func(file string, content []byte) ([]byte, error) {
+		return nil, fmt.Errorf("rejected")
+	}
+	if err := gen.Build(ins, opts); err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("should return the error of post process: %v", err)
+	}
+}
+
+func TestProtoBufListWrapper(t *testing.T) {
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer"},
+		},
+	}
+	gen := ProtoBuf{}
+	if out := renderProtoBufMessage(t, &gen, table); strings.Contains(out, "UsersMessageList") {
+		t.Errorf("unexpected list wrapper:\n%s", out)
+	}
+
+	gen = ProtoBuf{config: ProtoBufConfig{GenerateListWrappers: true}}
+	out := renderProtoBufMessage(t, &gen, table)
+	if !strings.Contains(out, "message UsersMessageList {\n  repeated UsersMessage items = 1;\n}") {
+		t.Errorf("list wrapper is missing:\n%s", out)
+	}
+}
+
+func TestProtoBufCommentsFile(t *testing.T) {
+	comments, err := loadCommentsFile("testdata/comments.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer"},
+			Column{Name: "name", DataType: "text", Comment: sql.NullString{String: "name in DB", Valid: true}},
+			Column{Name: "email", DataType: "text"},
+		},
+	}
+
+	gen := ProtoBuf{comments: comments}
+	expected := []string{"user id", "name in DB", ""}
+	for i, m := range gen.members(table) {
+		if m.Comment != expected[i] {
+			t.Errorf("expected: %s, actual: %s", expected[i], m.Comment)
+		}
+	}
+	if out := renderProtoBufMessage(t, &gen, table); !strings.Contains(out, "//  registered users\n") {
+		t.Errorf("message comment is missing:\n%s", out)
+	}
+
+	gen.config.CommentsFileWins = true
+	expected = []string{"user id", "display name", ""}
+	for i, m := range gen.members(table) {
+		if m.Comment != expected[i] {
+			t.Errorf("expected: %s, actual: %s", expected[i], m.Comment)
+		}
+	}
+}
+
+func TestProtoBufPkFieldsFirst(t *testing.T) {
+	table := Table{
+		Name: "order_items",
+		Columns: []Column{
+			Column{Name: "note", DataType: "text"},
+			Column{Name: "order_id", DataType: "integer", PrimaryKey: true},
+			Column{Name: "price", DataType: "integer"},
+			Column{Name: "item_id", DataType: "integer", PrimaryKey: true},
+		},
+	}
+
+	gen := ProtoBuf{}
+	expected := []string{"note", "order_id", "price", "item_id"}
+	for i, m := range gen.members(table) {
+		if m.Name != expected[i] || m.Index != i+1 {
+			t.Errorf("expected: %s = %d, actual: %s = %d", expected[i], i+1, m.Name, m.Index)
+		}
+	}
+
+	gen = ProtoBuf{config: ProtoBufConfig{PkFieldsFirst: true}}
+	expected = []string{"order_id", "item_id", "note", "price"}
+	for i, m := range gen.members(table) {
+		if m.Name != expected[i] || m.Index != i+1 {
+			t.Errorf("expected: %s = %d, actual: %s = %d", expected[i], i+1, m.Name, m.Index)
+		}
+	}
+}
+
+func TestProtoBufApiResource(t *testing.T) {
+	table := Table{
+		Name: "book_shelves",
+		Columns: []Column{
+			Column{Name: "shelf_id", DataType: "integer", PrimaryKey: true},
+			Column{Name: "name", DataType: "text"},
+		},
+	}
+	gen := ProtoBuf{
+		config: ProtoBufConfig{
+			PackageName:         "library",
+			ApiServiceName:      "library.example.com",
+			GenerateApiResource: true,
+		},
+	}
+	out := renderProtoBufMessage(t, &gen, table)
+	expected := `message BookShelvesMessage {
+  option (google.api.resource) = {
+    type: "library.example.com/BookShelves"
+    pattern: "bookShelves/{shelf_id}"
+  };
+
+  int32 shelf_id = 1;`
+	if !strings.Contains(out, expected) {
+		t.Errorf("resource option is missing:\n%s", out)
+	}
+	if !strings.Contains(out, `import "google/api/resource.proto";`) {
+		t.Errorf("resource import is missing:\n%s", out)
+	}
+
+	table.Columns = append(table.Columns, Column{Name: "book_id", DataType: "integer", PrimaryKey: true})
+	if r := gen.apiResource(table); r != nil {
+		t.Errorf("composite key should not be a resource: %v", r)
+	}
+}
+
+func TestProtoBufBuildFunction(t *testing.T) {
+	output := t.TempDir()
+	gen := ProtoBuf{
+		config: ProtoBufConfig{
+			Output:      output,
+			Templates:   "templates/protobuf",
+			PackageName: "example",
+		},
+		root: ".",
+	}
+	ins := InspectResult{
+		Functions: []Table{
+			Table{
+				Name: "search_users",
+				Columns: []Column{
+					Column{Name: "id", DataType: "integer"},
+					Column{Name: "rank", DataType: "double precision"},
+				},
+			},
+		},
+	}
+	if err := gen.Build(ins, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(output, "SearchUsersMessage.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "message SearchUsersMessage {\n  int32 id = 1;") {
+		t.Errorf("unexpected message:\n%s", b)
+	}
+}
+
+func TestProtoBufStorageComment(t *testing.T) {
+	gen := ProtoBuf{}
+	table := Table{
+		Name: "articles",
+		Columns: []Column{
+			Column{Name: "body", DataType: "text", Storage: "x", TypeStorage: "x"},
+			Column{Name: "raw", DataType: "bytea", Storage: "e", TypeStorage: "x"},
+		},
+	}
+	members := gen.members(table)
+	if len(members[0].LeadingComments) != 0 {
+		t.Errorf("default storage should not be commented: %v", members[0].LeadingComments)
+	}
+	if !contains(members[1].LeadingComments, "storage: EXTERNAL") {
+		t.Errorf("storage comment is missing: %v", members[1].LeadingComments)
+	}
+}
+
+func TestProtoBufEnumFilePerType(t *testing.T) {
+	output := t.TempDir()
+	gen := ProtoBuf{
+		config: ProtoBufConfig{
+			Output:          output,
+			Templates:       "templates/protobuf",
+			PackageName:     "example",
+			EnumDir:         "enums",
+			EnumFilePerType: true,
+		},
+		root: ".",
+	}
+	ins := InspectResult{
+		Tables: []Table{
+			Table{
+				Name: "orders",
+				Columns: []Column{
+					Column{Name: "id", DataType: "integer"},
+					Column{Name: "status", DataType: "order_status"},
+					Column{Name: "flags", DataType: "order_flag[]", Array: true},
+				},
+			},
+		},
+		Types: []Type{
+			Type{Name: "order_status", Values: []string{"open", "closed"}},
+			Type{Name: "order_flag", Values: []string{"gift"}},
+			Type{Name: "unused", Values: []string{"a"}},
+		},
+	}
+	if err := gen.Build(ins, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"OrderStatus.proto", "OrderFlag.proto", "Unused.proto"} {
+		b, err := ioutil.ReadFile(filepath.Join(output, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(b), "enum "); n != 1 {
+			t.Errorf("%s: expected one enum, actual: %d\n%s", name, n, b)
+		}
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(output, "enum.proto")); err == nil {
+		t.Error("enum.proto should not be generated")
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(output, "OrdersMessage.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+	for _, s := range []string{`import "enums/OrderStatus.proto";`, `import "enums/OrderFlag.proto";`} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%s is missing:\n%s", s, out)
+		}
+	}
+	for _, s := range []string{`import "enums/Unused.proto";`, `enum.proto`} {
+		if strings.Contains(out, s) {
+			t.Errorf("unexpected %s:\n%s", s, out)
+		}
+	}
+}
+
+func TestProtoBufServices(t *testing.T) {
+	output := t.TempDir()
+	gen := ProtoBuf{
+		config: ProtoBufConfig{
+			Output:           output,
+			Templates:        "templates/protobuf",
+			PackageName:      "example.v1",
+			GenerateServices: true,
+		},
+		root: ".",
+	}
+	ins := InspectResult{
+		Tables: []Table{
+			Table{
+				Name: "user_accounts",
+				Columns: []Column{
+					Column{Name: "id", DataType: "bigint", PrimaryKey: true},
+					Column{Name: "name", DataType: "text"},
+				},
+			},
+			Table{
+				Name:    "audit_logs",
+				Columns: []Column{Column{Name: "message", DataType: "text"}},
+			},
+		},
+	}
+	if err := gen.Build(ins, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(output, "service.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+	for _, expected := range []string{
+		`import "UserAccountsMessage.proto";`,
+		"service UserAccountsService {",
+		"  rpc GetUserAccounts(GetUserAccountsRequest) returns (GetUserAccountsResponse);\n",
+		"  rpc ListUserAccounts(ListUserAccountsRequest) returns (ListUserAccountsResponse);\n",
+		"  rpc DeleteUserAccounts(DeleteUserAccountsRequest) returns (DeleteUserAccountsResponse);\n",
+		"message GetUserAccountsRequest {\n  int64 id = 1;",
+		"repeated UserAccountsMessage user_accounts = 1;",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "AuditLogs") {
+		t.Errorf("table without primary key should not have a service:\n%s", out)
+	}
+}
+
+func TestProtoBufStreamLists(t *testing.T) {
+	gen := ProtoBuf{
+		config: ProtoBufConfig{PackageName: "example.v1", GenerateServices: true, StreamLists: true},
+		ins: InspectResult{
+			Tables: []Table{
+				Table{
+					Name:    "users",
+					Columns: []Column{Column{Name: "id", DataType: "bigint", PrimaryKey: true}},
+				},
+			},
+		},
+		template: template.Must(template.ParseGlob("templates/protobuf/*.tmpl")),
+	}
+	var buf bytes.Buffer
+	if err := gen.buildService(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{
+		"  rpc ListUsers(ListUsersRequest) returns (stream UsersMessage);\n",
+		"message GetUsersResponse {\n  UsersMessage users = 1;\n}\n\n// messages are streamed, so there are no pages\nmessage ListUsersRequest {}\n\nmessage CreateUsersRequest {",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "ListUsersResponse") {
+		t.Errorf("streamed lists have no response message:\n%s", out)
+	}
+}
+
+func TestProtoBufJsonMaps(t *testing.T) {
+	users := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer"},
+			Column{Name: "addresses", DataType: "jsonb"},
+			Column{Name: "labels", DataType: "jsonb"},
+			Column{Name: "settings", DataType: "jsonb"},
+		},
+	}
+	gen := ProtoBuf{
+		config: ProtoBufConfig{
+			JsonMaps: map[string]string{
+				"users.addresses": "AddressMessage",
+				"users.labels":    "string",
+			},
+		},
+		ins: InspectResult{
+			Tables: []Table{users, Table{Name: "address"}},
+		},
+	}
+	expected := []string{"int32", "map<string, AddressMessage>", "map<string, string>", "map<string, string>"}
+	for i, m := range gen.members(users) {
+		if m.Type != expected[i] {
+			t.Errorf("%s: expected %s, actual: %s", m.Name, expected[i], m.Type)
+		}
+	}
+	if imports := gen.imports(users); !reflect.DeepEqual(imports, []string{"AddressMessage.proto"}) {
+		t.Errorf("unexpected imports: %v", imports)
+	}
+}
+
+func TestProtoBufJsonUnions(t *testing.T) {
+	events := Table{
+		Name: "events",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer"},
+			Column{Name: "payload", DataType: "jsonb"},
+		},
+	}
+	gen := ProtoBuf{
+		config: ProtoBufConfig{
+			Templates: "templates/protobuf",
+			JsonUnions: map[string]UnionDef{
+				"events.payload": UnionDef{
+					Discriminator: "kind",
+					Variants:      map[string]string{"page-view": "PageViewsMessage", "click": "ClickEvent"},
+					Numbers:       map[string]int{"page-view": 2, "click": 1},
+				},
+			},
+		},
+		ins:  InspectResult{Tables: []Table{events, Table{Name: "page_views"}}},
+		root: ".",
+	}
+	actual := renderProtoBufMessage(t, &gen, events)
+	for _, s := range []string{
+		"import \"PageViewsMessage.proto\";",
+		"  message PayloadUnion {\n    oneof kind {\n" +
+			"      ClickEvent click = 1; // kind = \"click\"\n" +
+			"      PageViewsMessage page_view = 2; // kind = \"page-view\"\n    }\n  }",
+		"  PayloadUnion payload = 2;",
+	} {
+		if !strings.Contains(actual, s) {
+			t.Errorf("expected %q:\n%s", s, actual)
+		}
+	}
+}
+
+func TestProtoBufJsonUnionNumbers(t *testing.T) {
+	events := Table{
+		Name:    "events",
+		Columns: []Column{Column{Name: "payload", DataType: "jsonb"}},
+	}
+	union := func(variants ...string) UnionDef {
+		def := UnionDef{Discriminator: "kind", Variants: map[string]string{}}
+		for _, v := range variants {
+			def.Variants[v] = SnakeToUpperCamel(v)
+		}
+		return def
+	}
+	gen := ProtoBuf{numbers: ProtoBufFieldNumbers{}}
+	gen.config.JsonUnions = map[string]UnionDef{"events.payload": union("click", "view")}
+	gen.unions(events)
+
+	// a variant sorting first doesn't renumber the others, and dropped variants are reserved
+	gen.config.JsonUnions = map[string]UnionDef{"events.payload": union("add", "view")}
+	actual := gen.unions(events)[0]
+	var numbers []string
+	for _, v := range actual.Variants {
+		numbers = append(numbers, fmt.Sprintf("%s=%d", v.Name, v.Index))
+	}
+	if strings.Join(numbers, ",") != "view=2,add=3" {
+		t.Errorf("unexpected numbers: %v", numbers)
+	}
+	if actual.ReservedNumbers != "1" || actual.ReservedNames != `"click"` {
+		t.Errorf("unexpected reserved: %+v", actual)
+	}
+	if _, ok := gen.numbers["events.PayloadUnion"]; !ok {
+		t.Errorf("numbers should be recorded: %v", gen.numbers)
+	}
+
+	// explicit numbers win
+	def := union("add", "view")
+	def.Numbers = map[string]int{"add": 7, "view": 5}
+	gen.config.JsonUnions = map[string]UnionDef{"events.payload": def}
+	if v := gen.unions(events)[0].Variants; v[0].Name != "view" || v[0].Index != 5 || v[1].Index != 7 {
+		t.Errorf("unexpected variants: %+v", v)
+	}
+}
+
+func TestProtoBufJsonUnionsInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for _, unions := range []string{
+		`{"payload": {"discriminator": "kind", "variants": {"click": "ClickEvent"}}}`,
+		`{"events.payload": {"variants": {"click": "ClickEvent"}}}`,
+		`{"events.payload": {"discriminator": "kind"}}`,
+		`{"events.payload": {"discriminator": "kind", "variants": {"click": ""}}}`,
+		// numbers are required without field_numbers_file
+		`{"events.payload": {"discriminator": "kind", "variants": {"click": "ClickEvent"}}}`,
+		`{"events.payload": {"discriminator": "kind", "variants": {"click": "ClickEvent", "view": "View"}, "numbers": {"click": 1}}}`,
+		`{"events.payload": {"discriminator": "kind", "variants": {"click": "ClickEvent", "view": "View"}, "numbers": {"click": 1, "view": 1}}}`,
+		`{"events.payload": {"discriminator": "kind", "variants": {"click": "ClickEvent"}, "numbers": {"click": 19000}}}`,
+		`{"events.payload": {"discriminator": "kind", "variants": {"click": "ClickEvent"}, "numbers": {"click": 1, "tap": 2}}}`,
+		`{"events.payload": {"discriminator": "kind", "variants": {"page-view": "A", "page_view": "B"}, "numbers": {"page-view": 1, "page_view": 2}}}`,
+	} {
+		raw := json.RawMessage(`{"output": "` + dir + `", "json_unions": ` + unions + `}`)
+		if _, err := loadProtoBufConfig("/", raw); err == nil {
+			t.Errorf("should be error: %s", unions)
+		}
+	}
+}
+
+func TestProtoBufTemplateFuncs(t *testing.T) {
+	dir := t.TempDir()
+	gen := ProtoBuf{
+		config: ProtoBufConfig{Output: dir, Templates: "testdata/templates/funcs"},
+		root:   ".",
+	}
+	ins := InspectResult{Tables: []Table{Table{Name: "user_category", Comment: sql.NullString{String: "categories of users", Valid: true}}}}
+	if err := gen.Build(ins, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "UserCategoryMessage.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `snakeToUpperCamel: UserCategory
+snakeToLowerCamel: userCategory
+snakeToUpper: USER_CATEGORY
+pluralize: user_categories
+lower: usercategorymessage
+upper: USERCATEGORYMESSAGE
+title: Categories Of Users
+`
+	if string(b) != expected {
+		t.Errorf("expected:\n%s\nactual:\n%s", expected, b)
+	}
+}
+
+func TestProtoBufIncludeTables(t *testing.T) {
+	dir := t.TempDir()
+	gen := ProtoBuf{
+		config: ProtoBufConfig{
+			Output:        dir,
+			Templates:     "templates/protobuf",
+			IncludeTables: []string{"order_*"},
+			IgnoreTables:  []string{"_archive$"},
+		},
+		root: ".",
+	}
+	var ins InspectResult
+	for _, name := range []string{"users", "order_items", "order_items_archive"} {
+		ins.Tables = append(ins.Tables, Table{Name: name, Columns: []Column{Column{Name: "id", DataType: "bigint"}}})
+	}
+	if err := gen.Build(ins, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for file, exists := range map[string]bool{"OrderItemsMessage.proto": true, "UsersMessage.proto": false, "OrderItemsArchiveMessage.proto": false} {
+		_, err := os.Stat(filepath.Join(dir, file))
+		if actual := err == nil; actual != exists {
+			t.Errorf("%s: expected exists %t, actual: %t", file, exists, actual)
+		}
+	}
+
+	raw := json.RawMessage(`{"output": "` + dir + `", "include_tables": ["order_["]}`)
+	if _, err := loadProtoBufConfig("/", raw); err == nil {
+		t.Error("invalid glob should be error")
+	}
+	raw = json.RawMessage(`{"output": "` + dir + `", "ignore_tables": ["order_(["]}`)
+	if _, err := loadProtoBufConfig("/", raw); err == nil {
+		t.Error("invalid ignore pattern should be error")
+	}
+}
+
+func TestProtoBufVersionStamp(t *testing.T) {
+	gen := ProtoBuf{opts: BuildOptions{Stamp: "pg2any v1.0.0, schema 0123456789ab"}}
+	out := renderProtoBufMessage(t, &gen, Table{Name: "users"})
+	if !strings.Contains(out, "// Generated by pg2any. DO NOT EDIT THIS FILE\n// pg2any v1.0.0, schema 0123456789ab\n") {
+		t.Errorf("stamp is missing:\n%s", out)
+	}
+}
+
+func TestProtoBufStableFieldNumbers(t *testing.T) {
+	dir := t.TempDir()
+	gen := ProtoBuf{
+		config: ProtoBufConfig{
+			Output:           dir,
+			Templates:        "templates/protobuf",
+			FieldNumbersFile: filepath.Join(dir, "numbers.json"),
+		},
+		root: ".",
+	}
+	build := func(cols ...string) map[string]int {
+		table := Table{Name: "users"}
+		for _, c := range cols {
+			table.Columns = append(table.Columns, Column{Name: c, DataType: "text"})
+		}
+		if err := gen.Build(InspectResult{Tables: []Table{table}}, BuildOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		ret := make(map[string]int)
+		for _, m := range gen.members(table) {
+			ret[m.Name] = m.Index
+		}
+		return ret
+	}
+
+	expected := map[string]int{"id": 1, "name": 2, "email": 3}
+	if actual := build("id", "name", "email"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+
+	// drop the middle column
+	expected = map[string]int{"id": 1, "email": 3}
+	if actual := build("id", "email"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+
+	// a new column must not reuse the gap
+	expected = map[string]int{"id": 1, "email": 3, "phone": 4}
+	if actual := build("id", "email", "phone"); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+
+	// numbers are persisted across runs
+	numbers, err := loadProtoBufFieldNumbers(gen.config.FieldNumbersFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := numbers["users"]; msg.Max != 4 || msg.Fields["phone"] != 4 {
+		t.Errorf("unexpected persisted numbers: %v", msg)
+	}
+}
+
+func TestProtoBufReservedDroppedFields(t *testing.T) {
+	dir := t.TempDir()
+	gen := ProtoBuf{
+		config: ProtoBufConfig{
+			Output:           dir,
+			Templates:        "templates/protobuf",
+			FieldNumbersFile: filepath.Join(dir, "numbers.json"),
+		},
+		root: ".",
+	}
+	build := func(cols ...string) string {
+		table := Table{Name: "users"}
+		for _, c := range cols {
+			table.Columns = append(table.Columns, Column{Name: c, DataType: "text"})
+		}
+		if err := gen.Build(InspectResult{Tables: []Table{table}}, BuildOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, "UsersMessage.proto"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	if out := build("id", "name", "email"); strings.Contains(out, "reserved") {
+		t.Errorf("unexpected reserved:\n%s", out)
+	}
+	out := build("id", "email")
+	if !strings.Contains(out, "  reserved 2;\n  reserved \"name\";\n") {
+		t.Errorf("dropped field is not reserved:\n%s", out)
+	}
+
+	// re-adding the name releases it, but the number stays reserved
+	out = build("id", "email", "name")
+	if !strings.Contains(out, "  reserved 2;\n") || strings.Contains(out, `reserved "name"`) {
+		t.Errorf("unexpected reserved:\n%s", out)
+	}
+	if !strings.Contains(out, "string name = 4;") {
+		t.Errorf("re-added field must get a new number:\n%s", out)
+	}
+
+	numbers, err := loadProtoBufFieldNumbers(gen.config.FieldNumbersFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ProtoBufReservedField{ProtoBufReservedField{Number: 2}}
+	if actual := numbers["users"].Reserved; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, actual: %v", expected, actual)
+	}
+}
+
+func TestProtoBufTimestampMode(t *testing.T) {
+	table := Table{
+		Name: "events",
+		Columns: []Column{
+			Column{Name: "created_at", DataType: "timestamp with time zone"},
+			Column{Name: "opens_at", DataType: "time without time zone"},
+			Column{Name: "closes_at", DataType: "time with time zone"},
+		},
+	}
+	ff := []struct {
+		mode    string
+		typ     string
+		import_ bool
+	}{
+		{"", "google.protobuf.Timestamp", true},
+		{TimestampModeWKT, "google.protobuf.Timestamp", true},
+		{TimestampModeString, "string", false},
+		{TimestampModeEpochMillis, "int64", false},
+		{TimestampModeEpochSeconds, "int64", false},
+	}
+	for _, f := range ff {
+		gen := ProtoBuf{config: ProtoBufConfig{TimestampMode: f.mode}}
+		members := gen.members(table)
+		if actual := members[0].Type; actual != f.typ {
+			t.Errorf("%s: expected %s, actual: %s", f.mode, f.typ, actual)
+		}
+		// times of day aren't timestamps
+		for _, m := range members[1:] {
+			if m.Type != "string" || len(m.LeadingComments) > 0 {
+				t.Errorf("%s %s: expected string, actual: %s %v", f.mode, m.Name, m.Type, m.LeadingComments)
+			}
+		}
+		if actual := contains(gen.imports(table), "google/protobuf/timestamp.proto"); actual != f.import_ {
+			t.Errorf("%s: import expected %t, actual: %t", f.mode, f.import_, actual)
+		}
+	}
+
+	gen := ProtoBuf{config: ProtoBufConfig{TimestampMode: TimestampModeEpochMillis}}
+	if !contains(gen.members(table)[0].LeadingComments, "epoch milliseconds") {
+		t.Errorf("unit comment is missing: %v", gen.members(table)[0].LeadingComments)
+	}
+	if _, err := loadProtoBufConfig(".", []byte(`{"output": ".", "timestamp_mode": "unix"}`)); err == nil {
+		t.Error("unknown mode should be error")
+	}
+}
+
+func TestProtoBufProto2Default(t *testing.T) {
+	def := func(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+	gen := ProtoBuf{
+		config: ProtoBufConfig{Syntax: "proto2", PackageName: "example"},
+		ins: InspectResult{
+			Types: []Type{Type{Name: "user_status", Values: []string{"active", "banned"}}},
+		},
+	}
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "name", DataType: "text", DefaultValue: def("'it''s me'::text")},
+			Column{Name: "age", DataType: "integer", DefaultValue: def("20")},
+			Column{Name: "score", DataType: "integer", DefaultValue: def("'-1'::integer")},
+			Column{Name: "status", DataType: "user_status", DefaultValue: def("'active'::user_status")},
+			Column{Name: "created_at", DataType: "timestamp with time zone", DefaultValue: def("now()")},
+			Column{Name: "tags", DataType: "text[]", Array: true, DefaultValue: def("'{}'::text[]")},
+		},
+	}
+	ff := []struct {
+		constraint string
+		options    []string
+	}{
+		{"optional", []string{`default = "it's me"`}},
+		{"optional", []string{"default = 20"}},
+		{"optional", []string{"default = -1"}},
+		{"optional", []string{"default = USER_STATUS_ACTIVE"}},
+		{"optional", nil},
+		{"", nil},
+	}
+	members := gen.members(table)
+	for i, f := range ff {
+		if members[i].Constraint != f.constraint || !reflect.DeepEqual(members[i].Options, f.options) {
+			t.Errorf("%s: expected %q %v, actual: %q %v", members[i].Name, f.constraint, f.options, members[i].Constraint, members[i].Options)
+		}
+	}
+
+	proto := renderProtoBufMessage(t, &gen, table)
+	for _, expected := range []string{
+		`syntax = "proto2";`,
+		`optional int32 age = 2 [default = 20];`,
+	} {
+		if !strings.Contains(proto, expected) {
+			t.Errorf("expected %q in:\n%s", expected, proto)
+		}
+	}
+
+	gen.config.Syntax = ""
+	if members := gen.members(table); members[0].Constraint != "" || len(members[0].Options) != 0 {
+		t.Errorf("proto3 fields should not have defaults: %v", members[0])
+	}
+}
+
+func TestProtoBufSkipEmptyTables(t *testing.T) {
+	ins := InspectResult{
+		Tables: []Table{
+			Table{Name: "migrating"},
+			Table{Name: "users", Columns: []Column{Column{Name: "id", DataType: "integer"}}},
+		},
+	}
+	for _, skip := range []bool{false, true} {
+		output := t.TempDir()
+		gen := ProtoBuf{
+			config: ProtoBufConfig{
+				Output:          output,
+				Templates:       "templates/protobuf",
+				PackageName:     "example",
+				SkipEmptyTables: skip,
+			},
+			root: ".",
+		}
+		if err := gen.Build(ins, BuildOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ioutil.ReadFile(filepath.Join(output, "UsersMessage.proto")); err != nil {
+			t.Errorf("skip %t: %s", skip, err)
+		}
+		_, err := ioutil.ReadFile(filepath.Join(output, "MigratingMessage.proto"))
+		if exists := err == nil; exists == skip {
+			t.Errorf("skip %t: empty table generated %t", skip, exists)
+		}
+	}
+}
+
+func TestProtoBufConnectServices(t *testing.T) {
+	output := t.TempDir()
+	gen := ProtoBuf{
+		config: ProtoBufConfig{
+			Output:          output,
+			Templates:       "templates/protobuf",
+			PackageName:     "example.v1",
+			GoPackage:       "example/v1;examplev1",
+			ConnectServices: true,
+		},
+		root: ".",
+	}
+	ins := InspectResult{
+		Tables: []Table{
+			Table{
+				Name: "user_accounts",
+				Columns: []Column{
+					Column{Name: "id", DataType: "bigint", PrimaryKey: true},
+					Column{Name: "name", DataType: "text"},
+				},
+			},
+			Table{
+				Name:    "audit_logs",
+				Columns: []Column{Column{Name: "message", DataType: "text"}},
+			},
+		},
+	}
+	if err := gen.Build(ins, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(output, "service.proto"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+	for _, expected := range []string{
+		`import "UserAccountsMessage.proto";`,
+		`option go_package = "example/v1;examplev1";`,
+		"service UserAccountsService {",
+		"rpc GetUserAccounts(GetUserAccountsRequest) returns (GetUserAccountsResponse) {\n    option idempotency_level = NO_SIDE_EFFECTS;",
+		"rpc DeleteUserAccounts(DeleteUserAccountsRequest) returns (DeleteUserAccountsResponse);",
+		"message GetUserAccountsRequest {\n  int64 id = 1;",
+		"repeated UserAccountsMessage user_accounts = 1;",
+		`import "google/protobuf/field_mask.proto";`,
+		"message UpdateUserAccountsRequest {\n  UserAccountsMessage user_accounts = 1;\n  // fields of user_accounts to update\n  google.protobuf.FieldMask update_mask = 2;\n}",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "AuditLogs") {
+		t.Errorf("table without primary key should not have a service:\n%s", out)
+	}
+
+	gen.config.StreamLists = true
+	var buf bytes.Buffer
+	if err := gen.buildService(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); strings.Count(out, "NO_SIDE_EFFECTS") != 1 || !strings.Contains(out, "returns (stream UserAccountsMessage);\n") {
+		t.Errorf("only Get should have no side effects with streamed lists:\n%s", out)
+	}
+}
+
+func TestProtoBufEnumValueComments(t *testing.T) {
+	gen := ProtoBuf{
+		config: ProtoBufConfig{
+			PackageName: "example",
+			EnumValueComments: map[string]map[string]string{
+				"order_status": {"open": "waiting for payment"},
+			},
+		},
+		template: template.Must(template.ParseGlob("templates/protobuf/*.tmpl")),
+	}
+	var buf bytes.Buffer
+	if err := gen.buildType(&buf, []Type{Type{Name: "order_status", Values: []string{"open", "closed"}}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"  ORDER_STATUS_OPEN = 0; // waiting for payment\n",
+		"  ORDER_STATUS_CLOSED = 1;\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestProtoBufEnumAliases(t *testing.T) {
+	gen := ProtoBuf{
+		config: ProtoBufConfig{
+			PackageName: "example",
+			EnumAliases: map[string][]string{"order_status.closed": []string{"done"}},
+		},
+		template: template.Must(template.ParseGlob("templates/protobuf/*.tmpl")),
+	}
+	types := []Type{
+		Type{Name: "order_status", Values: []string{"open", "closed"}},
+		Type{Name: "color", Values: []string{"red"}},
+	}
+	var buf bytes.Buffer
+	if err := gen.buildType(&buf, types); err != nil {
+		t.Fatal(err)
+	}
+	expected := "enum OrderStatus {\n" +
+		"  option allow_alias = true;\n" +
+		"  ORDER_STATUS_OPEN = 0;\n" +
+		"  ORDER_STATUS_CLOSED = 1;\n" +
+		"  ORDER_STATUS_DONE = 1; // alias of closed\n" +
+		"}"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in:\n%s", expected, buf.String())
+	}
+	if !strings.Contains(buf.String(), "enum Color {\n  COLOR_RED = 0;\n}") {
+		t.Errorf("enums without aliases should not allow alias:\n%s", buf.String())
+	}
+
+	for _, aliases := range []map[string][]string{
+		{"order_status.pending": []string{"waiting"}},
+		{"order_status.closed": []string{"open"}},
+	} {
+		gen.config.EnumAliases = aliases
+		if err := gen.buildType(&bytes.Buffer{}, types); err == nil {
+			t.Errorf("%v: expected an error", aliases)
+		}
+	}
+}
+
+func TestProtoBufSchemaImports(t *testing.T) {
+	gen := ProtoBuf{
+		config: ProtoBufConfig{
+			PackageName:     "example",
+			EnumDir:         "proto",
+			EnumFilePerType: true,
+			JsonMaps:        map[string]string{"accounts.owners": "UsersMessage"},
+		},
+		ins: InspectResult{
+			Tables: []Table{Table{Schema: "billing", Name: "users"}},
+			Types:  []Type{Type{Schema: "billing", Name: "account_status", Values: []string{"open"}}},
+		},
+		opts: BuildOptions{Schema: "billing"},
+	}
+	table := Table{
+		Schema: "billing",
+		Name:   "accounts",
+		Columns: []Column{
+			Column{Name: "status", DataType: "account_status"},
+			Column{Name: "owners", DataType: "jsonb"},
+		},
+	}
+	actual := renderProtoBufMessage(t, &gen, table)
+	var imports []string
+	for _, line := range strings.Split(actual, "\n") {
+		if strings.HasPrefix(line, "import ") {
+			imports = append(imports, line)
+		}
+	}
+	expected := []string{`import "proto/billing/AccountStatus.proto";`, `import "billing/UsersMessage.proto";`}
+	if !reflect.DeepEqual(imports, expected) {
+		t.Errorf("expected imports %v, actual: %v\n%s", expected, imports, actual)
+	}
+}
+
+func TestProtoBufSchemaFieldNumbers(t *testing.T) {
+	gen := ProtoBuf{
+		numbers: ProtoBufFieldNumbers{
+			"accounts": &ProtoBufMessageNumbers{Fields: map[string]int{"balance": 2, "id": 1}, Max: 2},
+		},
+		opts: BuildOptions{Schema: "public"},
+	}
+	table := Table{
+		Schema: "public",
+		Name:   "accounts",
+		Columns: []Column{
+			Column{Name: "balance", DataType: "bigint"},
+			Column{Name: "id", DataType: "bigint"},
+		},
+	}
+	members := gen.members(table)
+	if members[0].Index != 2 || members[1].Index != 1 {
+		t.Errorf("numbers recorded before schemas should be kept: %+v", members)
+	}
+	if _, ok := gen.numbers["accounts"]; ok {
+		t.Error("numbers should be moved to the qualified key")
+	}
+	if msg, ok := gen.numbers["public.accounts"]; !ok || msg.Fields["balance"] != 2 {
+		t.Errorf("numbers of public.accounts: %+v", gen.numbers)
+	}
+}
+
+func TestProtoBufFieldNumbersRename(t *testing.T) {
+	gen := ProtoBuf{
+		numbers: ProtoBufFieldNumbers{
+			"users": &ProtoBufMessageNumbers{Fields: map[string]int{"id": 1, "usr_nm": 2}, Max: 2},
+		},
+	}
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "bigint"},
+			Column{Name: "usr_nm", DataType: "text"},
+		},
+	}
+	gen.config.ColumnRenames = map[string]string{"users.usr_nm": "user_name"}
+	members := gen.members(table)
+	if members[1].Name != "user_name" || members[1].Index != 2 {
+		t.Errorf("renamed field should keep its number: %+v", members[1])
+	}
+	msg := gen.numbers["users"]
+	if len(msg.Reserved) != 0 || msg.Max != 2 || msg.Names["usr_nm"] != "user_name" {
+		t.Errorf("renaming should reserve nothing: %+v", msg)
+	}
+
+	// the field name is reserved once the column is dropped
+	table.Columns = table.Columns[:1]
+	gen.members(table)
+	expected := []ProtoBufReservedField{ProtoBufReservedField{Name: "user_name", Number: 2}}
+	if !reflect.DeepEqual(msg.Reserved, expected) {
+		t.Errorf("expected %v, actual: %v", expected, msg.Reserved)
+	}
+
+	// numbers keyed by the renamed field name are taken over
+	gen.numbers = ProtoBufFieldNumbers{
+		"users": &ProtoBufMessageNumbers{Fields: map[string]int{"id": 1, "user_name": 2}, Max: 2},
+	}
+	table.Columns = append(table.Columns, Column{Name: "usr_nm", DataType: "text"})
+	if members := gen.members(table); members[1].Index != 2 || len(gen.numbers["users"].Reserved) != 0 {
+		t.Errorf("numbers of field names should be kept: %+v, %+v", members, gen.numbers["users"])
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type PydanticConfig struct {
+	Output       string   `json:"output"`
+	Templates    string   `json:"templates"`
+	IgnoreTables []string `json:"ignore_tables"`
+	// OrmMode adds model_config = ConfigDict(from_attributes=True) to read models from ORM objects
+	OrmMode bool `json:"orm_mode"`
+	// SkipEmptyTables skips tables without columns, which may exist while migrating
+	SkipEmptyTables bool `json:"skip_empty_tables"`
+	// StableOutput leaves files whose content is unchanged, ignoring generated timestamps
+	StableOutput bool `json:"stable_output"`
+	// IncludeTables restricts generation to tables matching the glob patterns like order_*, before IgnoreTables
+	IncludeTables []string `json:"include_tables"`
+}
+
+type Pydantic struct {
+	db       *sql.DB
+	config   PydanticConfig
+	ins      InspectResult
+	template *template.Template
+	root     string
+	opts     BuildOptions
+}
+
+type PydanticMember struct {
+	Name    string
+	Type    string
+	Default string
+	Comment string
+}
+
+type PydanticTypeMember struct {
+	Name    string
+	Comment string
+	Values  []PydanticEnumValue
+}
+
+type PydanticEnumValue struct {
+	Name  string
+	Value string
+}
+
+const PydanticTypeName = "pydantic"
+
+// pydanticEnumModule is the module of enum classes, imported relatively from models.
+const pydanticEnumModule = "enums"
+
+func NewPydantic(db *sql.DB, root string, raw json.RawMessage) (Generator, error) {
+	config, err := loadPydanticConfig(root, raw)
+	if err != nil {
+		return nil, err
+	}
+	ret := Pydantic{
+		db:     db,
+		config: config,
+		root:   root,
+	}
+
+	return &ret, nil
+}
+
+func (gen *Pydantic) GetType() string {
+	return PydanticTypeName
+}
+
+func (gen *Pydantic) Build(ins InspectResult, opts BuildOptions) error {
+	log.Printf("output: %s", opts.outputDir(gen.root, gen.config.Output))
+	log.Printf("templates: %s", filePathJoinRoot(gen.root, gen.config.Templates))
+	gen.ins = ins
+	gen.opts = opts
+	opts = opts.stableOutput(gen.config.StableOutput)
+
+	// Load templates
+	tdir := filepath.Join(filePathJoinRoot(gen.root, gen.config.Templates), "*.tmpl")
+	t := template.Must(template.New("").Funcs(templateFuncs()).ParseGlob(tdir))
+	gen.template = t
+
+	// Build tables and row types of functions
+	for _, table := range gen.ins.TablesAndFunctions() {
+		if skipTable(gen.config.IncludeTables, gen.config.IgnoreTables, table.Name) {
+			continue
+		}
+		if len(table.Columns) == 0 && gen.config.SkipEmptyTables {
+			log.Printf("skip %s: no columns", table.Name)
+			continue
+		}
+		fileName := table.Name + ".py"
+		if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), fileName), func(wr io.Writer) error {
+			return gen.buildTable(wr, table)
+		}); err != nil {
+			return errors.Wrap(err, "build write table")
+		}
+	}
+
+	// Build types
+	if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), pydanticEnumModule+".py"), func(wr io.Writer) error {
+		return gen.buildType(wr, gen.ins.Types)
+	}); err != nil {
+		return errors.Wrap(err, "build write type")
+	}
+
+	return nil
+}
+
+func (gen *Pydantic) buildTable(wr io.Writer, table Table) error {
+	return gen.template.ExecuteTemplate(wr, "model", map[string]interface{}{
+		"stamp":    gen.opts.Stamp,
+		"now":      time.Now().UTC().Format(time.RFC3339),
+		"comment":  strings.Replace(table.Comment.String, "\n", " ", -1),
+		"name":     SnakeToUpperCamel(table.Name),
+		"orm_mode": gen.config.OrmMode,
+		"imports":  gen.imports(table),
+		"member":   gen.members(table),
+	})
+}
+
+func (gen *Pydantic) members(table Table) []PydanticMember {
+	var ret []PydanticMember
+
+	for _, col := range table.Columns {
+		m := PydanticMember{
+			Name:    col.Name,
+			Type:    gen.convertType(col),
+			Comment: strings.Replace(col.Comment.String, "\n", " ", -1),
+		}
+		if !col.NotNull {
+			m.Type = "Optional[" + m.Type + "]"
+			m.Default = "None"
+		}
+		ret = append(ret, m)
+	}
+	return ret
+}
+
+// imports returns import statements required by the model of table.
+func (gen *Pydantic) imports(table Table) []string {
+	var datetimes, typings, enums []string
+	decimal := false
+	for _, col := range table.Columns {
+		typ := gen.convertType(col)
+		for _, name := range []string{"date", "datetime", "time"} {
+			if pydanticTypeUses(typ, name) && !contains(datetimes, name) {
+				datetimes = append(datetimes, name)
+			}
+		}
+		if pydanticTypeUses(typ, "Decimal") {
+			decimal = true
+		}
+		for _, name := range []string{"Any", "List"} {
+			if pydanticTypeUses(typ, name) && !contains(typings, name) {
+				typings = append(typings, name)
+			}
+		}
+		if !col.NotNull && !contains(typings, "Optional") {
+			typings = append(typings, "Optional")
+		}
+		if t, err := gen.ins.FindType(strings.Replace(col.DataType, "[]", "", 1)); err == nil {
+			if name := SnakeToUpperCamel(t.Name); !contains(enums, name) {
+				enums = append(enums, name)
+			}
+		}
+	}
+
+	var ret []string
+	if len(datetimes) > 0 {
+		sort.Strings(datetimes)
+		ret = append(ret, "from datetime import "+strings.Join(datetimes, ", "))
+	}
+	if decimal {
+		ret = append(ret, "from decimal import Decimal")
+	}
+	if len(typings) > 0 {
+		sort.Strings(typings)
+		ret = append(ret, "from typing import "+strings.Join(typings, ", "))
+	}
+	if gen.config.OrmMode {
+		ret = append(ret, "from pydantic import BaseModel, ConfigDict")
+	} else {
+		ret = append(ret, "from pydantic import BaseModel")
+	}
+	if len(enums) > 0 {
+		sort.Strings(enums)
+		ret = append(ret, "from ."+pydanticEnumModule+" import "+strings.Join(enums, ", "))
+	}
+	return ret
+}
+
+// pydanticTypeUses reports whether the type annotation typ refers to name, e.g. "List[Decimal]" uses "Decimal".
+func pydanticTypeUses(typ, name string) bool {
+	for _, s := range strings.FieldsFunc(typ, func(r rune) bool { return r == '[' || r == ']' || r == ',' || r == ' ' }) {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (gen *Pydantic) buildType(wr io.Writer, types []Type) error {
+	var members []PydanticTypeMember
+	for _, typ := range types {
+		m := PydanticTypeMember{
+			Name:    SnakeToUpperCamel(typ.Name),
+			Comment: strings.Replace(typ.Comment.String, "\n", " ", -1),
+		}
+		for _, val := range typ.Values {
+			name := SnakeToUpper(val)
+			if isNumber(val) {
+				name = "VALUE_" + name
+			}
+			m.Values = append(m.Values, PydanticEnumValue{Name: name, Value: strconv.Quote(val)})
+		}
+		members = append(members, m)
+	}
+
+	return gen.template.ExecuteTemplate(wr, "enum", map[string]interface{}{
+		"stamp":   gen.opts.Stamp,
+		"now":     time.Now().UTC().Format(time.RFC3339),
+		"members": members,
+	})
+}
+
+func (gen *Pydantic) convertType(col Column) string {
+	// https://docs.pydantic.dev/latest/concepts/types/
+	col = resolveDomain(gen.ins, col)
+	if strings.HasSuffix(col.DataType, "[]") {
+		col.DataType = strings.Replace(col.DataType, "[]", "", 1)
+		return "List[" + gen.convertType(col) + "]"
+	}
+
+	switch col.DataType {
+	case "text", "uuid":
+		return "str"
+	case "smallint", "int2", "int", "integer", "bigint", "smallserial", "serial", "bigserial":
+		return "int"
+	case "float", "double", "double precision":
+		return "float"
+	case "numeric":
+		return "Decimal"
+	case "boolean":
+		return "bool"
+	case "date":
+		return "date"
+	case "json", "jsonb":
+		return "dict"
+	case "bytea":
+		return "bytes"
+	case "int2vector", "oidvector":
+		// space separated numbers like "1 2"
+		return "str"
+	default:
+		if isTimestamp(col.DataType) {
+			return "datetime"
+		}
+		// "time", "timetz", "time(n) with time zone" are times of day like "10:30:00+09"
+		if strings.HasPrefix(col.DataType, "time") {
+			return "time"
+		}
+		if strings.HasPrefix(col.DataType, "numeric") {
+			return "Decimal"
+		}
+		if strings.HasPrefix(col.DataType, "character") {
+			return "str"
+		}
+
+		typ, err := gen.ins.FindType(col.DataType)
+		if err == nil {
+			return SnakeToUpperCamel(typ.Name)
+		}
+	}
+	// unknown types are not validated
+	return "Any"
+}
+
+func loadPydanticConfig(root string, raw json.RawMessage) (PydanticConfig, error) {
+	var pc PydanticConfig
+	if err := json.Unmarshal(raw, &pc); err != nil {
+		return pc, fmt.Errorf("pydantic config error: %s", err)
+	}
+	if err := applyEnvOverrides(PydanticTypeName, &pc); err != nil {
+		return pc, fmt.Errorf("pydantic config error: %s", err)
+	}
+	output := filePathJoinRoot(root, pc.Output)
+	if err := DirExists(output); err != nil {
+		return pc, fmt.Errorf("pydantic output is not exists: %s", pc.Output)
+	}
+	if err := checkGlobs(pc.IncludeTables); err != nil {
+		return pc, fmt.Errorf("pydantic include_tables: %s", err)
+	}
+	if err := checkIgnores(pc.IgnoreTables); err != nil {
+		return pc, fmt.Errorf("pydantic ignore_tables: %s", err)
+	}
+	return pc, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestPydanticConvertType(t *testing.T) {
+	gen := Pydantic{
+		ins: InspectResult{
+			Domains: []Type{Type{Name: "email", BaseType: "character varying(255)"}},
+		},
+	}
+	ff := [][]string{
+		[]string{"text", "str"},
+		[]string{"uuid", "str"},
+		[]string{"smallint", "int"},
+		[]string{"int2", "int"},
+		[]string{"smallserial", "int"},
+		[]string{"integer", "int"},
+		[]string{"bigint", "int"},
+		[]string{"numeric(10,2)", "Decimal"},
+		[]string{"boolean", "bool"},
+		[]string{"timestamp with time zone", "datetime"},
+		[]string{"timestamp", "datetime"},
+		[]string{"time", "time"},
+		[]string{"time without time zone", "time"},
+		[]string{"time with time zone", "time"},
+		[]string{"timetz", "time"},
+		[]string{"jsonb", "dict"},
+		[]string{"text[]", "List[str]"},
+		[]string{"email", "str"},
+		[]string{"email[]", "List[str]"},
+		[]string{"fooBar", "Any"},
+	}
+	for _, d := range ff {
+		col := Column{
+			DataType: d[0],
+		}
+		if actual := gen.convertType(col); actual != d[1] {
+			t.Errorf("%s: expected %s, actual: %s", d[0], d[1], actual)
+		}
+	}
+}
+
+func TestPydanticOptional(t *testing.T) {
+	gen := Pydantic{}
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "bigint", NotNull: true},
+			Column{Name: "nickname", DataType: "text"},
+			Column{Name: "tags", DataType: "text[]"},
+		},
+	}
+	ff := []struct {
+		typ string
+		def string
+	}{
+		{"int", ""},
+		{"Optional[str]", "None"},
+		{"Optional[List[str]]", "None"},
+	}
+	members := gen.members(table)
+	for i, f := range ff {
+		if members[i].Type != f.typ || members[i].Default != f.def {
+			t.Errorf("%s: expected %s = %q, actual: %s = %q", members[i].Name, f.typ, f.def, members[i].Type, members[i].Default)
+		}
+	}
+	if imports := gen.imports(table); !contains(imports, "from typing import List, Optional") {
+		t.Errorf("typing import is missing: %v", imports)
+	}
+}
+
+func TestPydanticTimeImport(t *testing.T) {
+	gen := Pydantic{}
+	table := Table{
+		Name: "shops",
+		Columns: []Column{
+			Column{Name: "created_at", DataType: "timestamp with time zone", NotNull: true},
+			Column{Name: "opens_at", DataType: "time without time zone", NotNull: true},
+		},
+	}
+	if imports := gen.imports(table); !contains(imports, "from datetime import datetime, time") {
+		t.Errorf("datetime import is missing: %v", imports)
+	}
+}
+
+func TestPydanticEnum(t *testing.T) {
+	gen := Pydantic{
+		config: PydanticConfig{OrmMode: true},
+		ins: InspectResult{
+			Types: []Type{
+				Type{Name: "user_status", Values: []string{"active", "on_hold"}},
+			},
+		},
+	}
+	gen.template = template.Must(template.ParseGlob("templates/pydantic/*.tmpl"))
+
+	var buf bytes.Buffer
+	if err := gen.buildType(&buf, gen.ins.Types); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"class UserStatus(str, Enum):",
+		`    ACTIVE = "active"`,
+		`    ON_HOLD = "on_hold"`,
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, buf.String())
+		}
+	}
+
+	buf.Reset()
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "status", DataType: "user_status", NotNull: true},
+		},
+	}
+	if err := gen.buildTable(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"from .enums import UserStatus",
+		"class Users(BaseModel):",
+		"model_config = ConfigDict(from_attributes=True)",
+		"    status: UserStatus\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestPydanticEmptyModel(t *testing.T) {
+	gen := Pydantic{}
+	gen.template = template.Must(template.ParseGlob("templates/pydantic/*.tmpl"))
+	var buf bytes.Buffer
+	if err := gen.buildTable(&buf, Table{Name: "migrating"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "class Migrating(BaseModel):\n    pass\n") {
+		t.Errorf("empty model should have a placeholder body:\n%s", buf.String())
+	}
+}
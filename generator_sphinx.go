@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"os"
 	"path/filepath"
 	"strings"
 	"text/template"
@@ -19,6 +18,13 @@ type SphinxConfig struct {
 	Output       string   `json:"output"`
 	Templates    string   `json:"templates"`
 	IgnoreTables []string `json:"ignore_tables"`
+	PiiColumns   []string `json:"pii_columns"`
+	// SkipEmptyTables skips tables without columns, which may exist while migrating
+	SkipEmptyTables bool `json:"skip_empty_tables"`
+	// StableOutput leaves files whose content is unchanged, ignoring generated timestamps
+	StableOutput bool `json:"stable_output"`
+	// IncludeTables restricts generation to tables matching the glob patterns like order_*, before IgnoreTables
+	IncludeTables []string `json:"include_tables"`
 }
 
 type Sphinx struct {
@@ -27,6 +33,7 @@ type Sphinx struct {
 	ins      InspectResult
 	template *template.Template
 	root     string
+	opts     BuildOptions
 }
 
 type SphinxMember struct {
@@ -34,6 +41,9 @@ type SphinxMember struct {
 	Type       string
 	Constraint string
 	Comment    string
+	Sensitive  bool
+	ArrayDims  int
+	Storage    string
 }
 
 type SphinxTypeMember struct {
@@ -62,45 +72,43 @@ func (gen *Sphinx) GetType() string {
 	return SphinxTypeName
 }
 
-func (gen *Sphinx) Build(ins InspectResult) error {
-	log.Printf("output: %s", filePathJoinRoot(gen.root, gen.config.Output))
+func (gen *Sphinx) Build(ins InspectResult, opts BuildOptions) error {
+	log.Printf("output: %s", opts.outputDir(gen.root, gen.config.Output))
 	log.Printf("templates: %s", filePathJoinRoot(gen.root, gen.config.Templates))
 	gen.ins = ins
+	gen.opts = opts
+	opts = opts.stableOutput(gen.config.StableOutput)
 
 	// Load templates
-	funcs := template.FuncMap{
-		"writeUnderLine": func(s, char string) string { return strings.Repeat(char, len(s)) },
-	}
+	funcs := templateFuncs()
+	funcs["writeUnderLine"] = func(s, char string) string { return strings.Repeat(char, len(s)) }
 	tdir := filepath.Join(filePathJoinRoot(gen.root, gen.config.Templates), "*.tmpl")
 	t := template.Must(template.New("").Funcs(funcs).ParseGlob(tdir))
 
 	gen.template = t
 
-	// Build tables
-	for _, table := range gen.ins.Tables {
-		if partContainsRegex(gen.config.IgnoreTables, table.Name) {
+	// Build tables and row types of functions
+	for _, table := range gen.ins.TablesAndFunctions() {
+		if skipTable(gen.config.IncludeTables, gen.config.IgnoreTables, table.Name) {
 			continue
 		}
-		fileName := SnakeToUpperCamel(table.Name) + ".rst"
-		file, err := os.Create(filepath.Join(filePathJoinRoot(gen.root, gen.config.Output), fileName))
-		if err != nil {
-			return errors.Wrap(err, "build create file")
+		if len(table.Columns) == 0 && gen.config.SkipEmptyTables {
+			log.Printf("skip %s: no columns", table.Name)
+			continue
 		}
-		if err := gen.buildTable(file, table); err != nil {
-			file.Close()
+		fileName := SnakeToUpperCamel(table.Name) + ".rst"
+		if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), fileName), func(wr io.Writer) error {
+			return gen.buildTable(wr, table)
+		}); err != nil {
 			return errors.Wrap(err, "build write table")
 		}
-		file.Close()
 	}
 
 	// Build types
 	enumFileName := "enum.rst"
-	file, err := os.Create(filepath.Join(filePathJoinRoot(gen.root, gen.config.Output), enumFileName))
-	defer file.Close()
-	if err != nil {
-		return errors.Wrap(err, "build create file")
-	}
-	if err := gen.buildType(file, gen.ins.Types); err != nil {
+	if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), enumFileName), func(wr io.Writer) error {
+		return gen.buildType(wr, gen.ins.Types)
+	}); err != nil {
 		return errors.Wrap(err, "build write type")
 	}
 
@@ -109,6 +117,7 @@ func (gen *Sphinx) Build(ins InspectResult) error {
 
 func (gen *Sphinx) buildTable(wr io.Writer, table Table) error {
 	return gen.template.ExecuteTemplate(wr, "table", map[string]interface{}{
+		"stamp":   gen.opts.Stamp,
 		"now":     time.Now().UTC().Format(time.RFC3339),
 		"comment": table.Comment.String,
 		"name":    table.Name,
@@ -130,6 +139,10 @@ func (gen *Sphinx) members(table Table) []SphinxMember {
 			cons = col.ConstraintSrc.String
 		}
 		dtype := col.DataType
+		dims := arrayDims(col)
+		if dims > 0 {
+			dtype = strings.TrimSuffix(dtype, "[]") + strings.Repeat("[]", dims)
+		}
 		if col.Serial {
 			dtype += "(serial)"
 		}
@@ -139,6 +152,9 @@ func (gen *Sphinx) members(table Table) []SphinxMember {
 			Type:       dtype,
 			Constraint: cons,
 			Comment:    strings.Replace(col.Comment.String, "\n", "", -1),
+			Sensitive:  isPii(gen.config.PiiColumns, table.Name, col),
+			ArrayDims:  dims,
+			Storage:    storageNote(col),
 		}
 		ret = append(ret, m)
 	}
@@ -161,20 +177,29 @@ func (gen *Sphinx) buildType(wr io.Writer, types []Type) error {
 	}
 
 	return gen.template.ExecuteTemplate(wr, "enum", map[string]interface{}{
+		"stamp":   gen.opts.Stamp,
 		"now":     time.Now().UTC().Format(time.RFC3339),
 		"members": members,
 	})
-	return nil
 }
 
 func loadSphinxConfig(root string, raw json.RawMessage) (SphinxConfig, error) {
 	var pbc SphinxConfig
 	if err := json.Unmarshal(raw, &pbc); err != nil {
-		return pbc, fmt.Errorf("protobuf config error: %s", err)
+		return pbc, fmt.Errorf("sphinx config error: %s", err)
+	}
+	if err := applyEnvOverrides(SphinxTypeName, &pbc); err != nil {
+		return pbc, fmt.Errorf("sphinx config error: %s", err)
 	}
 	output := filePathJoinRoot(root, pbc.Output)
 	if err := DirExists(output); err != nil {
-		return pbc, fmt.Errorf("protobuf output is not exists: %s", pbc.Output)
+		return pbc, fmt.Errorf("sphinx output is not exists: %s", pbc.Output)
+	}
+	if err := checkGlobs(pbc.IncludeTables); err != nil {
+		return pbc, fmt.Errorf("sphinx include_tables: %s", err)
+	}
+	if err := checkIgnores(pbc.IgnoreTables); err != nil {
+		return pbc, fmt.Errorf("sphinx ignore_tables: %s", err)
 	}
 	return pbc, nil
 }
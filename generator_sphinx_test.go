@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestSphinxSensitive(t *testing.T) {
+	gen := Sphinx{
+		config: SphinxConfig{
+			PiiColumns: []string{"email"},
+		},
+	}
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer"},
+			Column{Name: "email", DataType: "text"},
+			Column{Name: "address", DataType: "text", Comment: sql.NullString{String: "@pii", Valid: true}},
+		},
+	}
+	expected := []bool{false, true, true}
+	for i, m := range gen.members(table) {
+		if m.Sensitive != expected[i] {
+			t.Errorf("%s: expected %t, actual: %t", m.Name, expected[i], m.Sensitive)
+		}
+	}
+}
+
+func TestSphinxArray(t *testing.T) {
+	gen := Sphinx{
+		template: template.Must(template.New("").Funcs(template.FuncMap{
+			"writeUnderLine": writeUnderLine,
+		}).ParseGlob("templates/sphinx/*.tmpl")),
+	}
+	table := Table{
+		Name: "prices",
+		Columns: []Column{
+			Column{Name: "amount", DataType: "money"},
+			Column{Name: "history", DataType: "money[]", Array: true, ArrayDims: 1},
+			Column{Name: "tags", DataType: "text[]", Array: true},
+			Column{Name: "matrix", DataType: "integer[]", Array: true, ArrayDims: 2},
+		},
+	}
+	expected := []struct {
+		typ  string
+		dims int
+	}{
+		{"money", 0},
+		{"money[]", 1},
+		{"text[]", 1},
+		{"integer[][]", 2},
+	}
+	for i, m := range gen.members(table) {
+		if m.Type != expected[i].typ || m.ArrayDims != expected[i].dims {
+			t.Errorf("expected: %s %d, actual: %s %d", expected[i].typ, expected[i].dims, m.Type, m.ArrayDims)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gen.buildTable(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, s := range []string{"- money\n", "- money[] (array, dimensions: 1)\n", "- integer[][] (array, dimensions: 2)\n"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%q is missing:\n%s", s, out)
+		}
+	}
+}
+
+func TestSphinxStorage(t *testing.T) {
+	gen := Sphinx{}
+	table := Table{
+		Name: "articles",
+		Columns: []Column{
+			Column{Name: "id", DataType: "integer", Storage: "p", TypeStorage: "p"},
+			Column{Name: "body", DataType: "text", Storage: "x", TypeStorage: "x"},
+			Column{Name: "raw", DataType: "bytea", Storage: "e", TypeStorage: "x", Compression: sql.NullString{String: "l", Valid: true}},
+		},
+	}
+	expected := []string{"", "storage: EXTENDED", "storage: EXTERNAL, compression: lz4"}
+	for i, m := range gen.members(table) {
+		if m.Storage != expected[i] {
+			t.Errorf("expected: %s, actual: %s", expected[i], m.Storage)
+		}
+	}
+}
@@ -1,6 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -10,6 +19,38 @@ func TestSnakeToUpperCamel(t *testing.T) {
 	}
 }
 
+func TestCamelToSnake(t *testing.T) {
+	for src, expected := range map[string]string{"userName": "user_name", "UserName": "user_name", "user_name": "user_name", "id": "id"} {
+		if actual := camelToSnake(src); actual != expected {
+			t.Errorf("%s: expected %s, actual: %s", src, expected, actual)
+		}
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	for src, expected := range map[string]string{"user": "users", "category": "categories", "day": "days", "box": "boxes", "address": "addresses", "batch": "batches", "": ""} {
+		if actual := pluralize(src); actual != expected {
+			t.Errorf("%s: expected %s, actual: %s", src, expected, actual)
+		}
+	}
+}
+
+func TestCheckColumnRenames(t *testing.T) {
+	table := Table{
+		Name:    "users",
+		Columns: []Column{Column{Name: "usr_nm"}, Column{Name: "username"}, Column{Name: "a_b"}, Column{Name: "ab"}},
+	}
+	if err := checkColumnRenames(map[string]string{"users.usr_nm": "user_name"}, table); err == nil {
+		t.Error("user_name collides with username")
+	}
+	if err := checkColumnRenames(map[string]string{"users.usr_nm": "userName", "users.username": "login"}, table); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := checkColumnRenames(nil, table); err != nil {
+		t.Errorf("columns not renamed should be allowed: %s", err)
+	}
+}
+
 func TestPartContains(t *testing.T) {
 	s := []string{"aaa", "bbb", "abcde", "b$"}
 	if partContainsRegex(s, "a") != false {
@@ -31,3 +72,344 @@ func TestPartContains(t *testing.T) {
 		t.Error("should be true")
 	}
 }
+
+func TestParseForeignKey(t *testing.T) {
+	fk, err := parseForeignKey(`FOREIGN KEY (security_code) REFERENCES master_security(security_code)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fk.RefTable != "master_security" {
+		t.Errorf("unexpected table: %s", fk.RefTable)
+	}
+	if ref, ok := fk.Reference("security_code"); !ok || ref != "security_code" {
+		t.Errorf("unexpected reference: %s", ref)
+	}
+
+	fk, err = parseForeignKey(`FOREIGN KEY (tenant_id, "order") REFERENCES orders(tenant_id, id) ON DELETE CASCADE`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref, ok := fk.Reference("order"); !ok || ref != "id" {
+		t.Errorf("unexpected reference: %s", ref)
+	}
+	if _, ok := fk.Reference("foo"); ok {
+		t.Error("should not be found")
+	}
+
+	if _, err := parseForeignKey("CHECK (price > 0)"); err == nil {
+		t.Error("should be error")
+	}
+}
+
+func TestParseForeignKeyActions(t *testing.T) {
+	ff := []struct {
+		src      string
+		onDelete string
+		onUpdate string
+	}{
+		{"FOREIGN KEY (a) REFERENCES b(id)", "NO ACTION", "NO ACTION"},
+		{"FOREIGN KEY (a) REFERENCES b(id) ON DELETE CASCADE", "CASCADE", "NO ACTION"},
+		{"FOREIGN KEY (a) REFERENCES b(id) ON UPDATE CASCADE ON DELETE SET NULL", "SET NULL", "CASCADE"},
+		{"FOREIGN KEY (a) REFERENCES b(id) ON UPDATE RESTRICT ON DELETE SET DEFAULT", "SET DEFAULT", "RESTRICT"},
+	}
+	for _, f := range ff {
+		fk, err := parseForeignKey(f.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fk.OnDelete != f.onDelete {
+			t.Errorf("%s: expected %s, actual: %s", f.src, f.onDelete, fk.OnDelete)
+		}
+		if fk.OnUpdate != f.onUpdate {
+			t.Errorf("%s: expected %s, actual: %s", f.src, f.onUpdate, fk.OnUpdate)
+		}
+	}
+}
+
+func TestVersionStamp(t *testing.T) {
+	ins := InspectResult{
+		Tables: []Table{Table{Name: "users"}},
+	}
+	stamp := versionStamp(ins)
+	if !strings.HasPrefix(stamp, "pg2any "+toolVersion()+", ") {
+		t.Errorf("version is missing: %s", stamp)
+	}
+	if !strings.HasSuffix(stamp, "schema "+ins.Hash()[:12]) {
+		t.Errorf("schema hash is missing: %s", stamp)
+	}
+}
+
+func TestCheckFiles(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"same.txt":    "// Generated at 2024-01-02T03:04:05Z\nbody\n",
+		"drifted.txt": "// Generated at 2024-01-02T03:04:05Z\nold\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := &checkFiles{root: root}
+	for name, content := range map[string]string{
+		"same.txt":    "// Generated at 2025-06-07T08:09:10Z\nbody\n",
+		"drifted.txt": "// Generated at 2025-06-07T08:09:10Z\nnew\n",
+		"missing.txt": "body\n",
+	} {
+		content := content
+		if err := files.WriteFile(filepath.Join(root, name), func(wr io.Writer) error {
+			_, err := io.WriteString(wr, content)
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort.Strings(files.drifted)
+	if expected, actual := "drifted.txt,missing.txt", strings.Join(files.drifted, ","); actual != expected {
+		t.Errorf("expected %s, actual: %s", expected, actual)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(root, "drifted.txt")); !strings.Contains(string(b), "old") {
+		t.Error("check should not write files")
+	}
+	if _, err := os.Stat(filepath.Join(root, "missing.txt")); !os.IsNotExist(err) {
+		t.Error("check should not create files")
+	}
+}
+
+func TestCheckFilesBuild(t *testing.T) {
+	root := t.TempDir()
+	gen := ProtoBuf{
+		config: ProtoBufConfig{Output: ".", Templates: "templates/protobuf", PackageName: "example"},
+		root:   root,
+	}
+	gen.config.Templates, _ = filepath.Abs(gen.config.Templates)
+	ins := InspectResult{
+		Tables: []Table{
+			Table{Name: "users", Columns: []Column{Column{Name: "id", DataType: "bigint", NotNull: true}}},
+		},
+	}
+	if err := gen.Build(ins, BuildOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	files := &checkFiles{root: root}
+	if err := gen.Build(ins, BuildOptions{Files: files}); err != nil {
+		t.Fatal(err)
+	}
+	if len(files.drifted) != 0 {
+		t.Errorf("generated files should be in sync: %v", files.drifted)
+	}
+
+	file := filepath.Join(root, "enum.proto")
+	if err := ioutil.WriteFile(file, []byte("// edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files = &checkFiles{root: root}
+	if err := gen.Build(ins, BuildOptions{Files: files}); err != nil {
+		t.Fatal(err)
+	}
+	if expected, actual := "enum.proto", strings.Join(files.drifted, ","); actual != expected {
+		t.Errorf("expected %s, actual: %s", expected, actual)
+	}
+	if b, _ := ioutil.ReadFile(file); string(b) != "// edited\n" {
+		t.Error("check should not write files")
+	}
+}
+
+func TestNormalizeGenerated(t *testing.T) {
+	a := normalizeGenerated([]byte("// Generated by pg2any at 2024-01-02T03:04:05Z\nDEFAULT '2024-01-02 03:04:05'\n"))
+	b := normalizeGenerated([]byte("// Generated by pg2any at 2025-06-07T08:09:10Z\nDEFAULT '2024-01-02 03:04:05'\n"))
+	if string(a) != string(b) {
+		t.Errorf("generated timestamps should be ignored:\n%s\n%s", a, b)
+	}
+	c := normalizeGenerated([]byte("// Generated by pg2any at 2024-01-02T03:04:05Z\nDEFAULT '2025-01-02 03:04:05'\n"))
+	if string(a) == string(c) {
+		t.Error("other timestamps should be compared")
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	if err := writeFile(path, func(wr io.Writer) error {
+		_, err := io.WriteString(wr, "hello")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != "hello" {
+		t.Errorf("unexpected content: %q", b)
+	}
+
+	if err := writeFile(path, func(wr io.Writer) error {
+		return fmt.Errorf("broken")
+	}); err == nil || err.Error() != "broken" {
+		t.Errorf("write error should be returned: %v", err)
+	}
+	if err := writeFile(filepath.Join(path, "not_dir"), func(wr io.Writer) error {
+		return nil
+	}); err == nil {
+		t.Error("create error should be returned")
+	}
+}
+
+// openFiles returns the number of file descriptors of the process.
+func openFiles(t *testing.T) int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("file descriptors are not listed:", err)
+	}
+	return len(fds)
+}
+
+func TestBuildClosesFiles(t *testing.T) {
+	var ins InspectResult
+	for i := 0; i < 300; i++ {
+		ins.Tables = append(ins.Tables, Table{
+			Name:    fmt.Sprintf("table_%d", i),
+			Columns: []Column{Column{Name: "id", DataType: "integer", PrimaryKey: true}},
+		})
+		ins.Types = append(ins.Types, Type{Name: fmt.Sprintf("type_%d", i), Values: []string{"a"}})
+	}
+	output := t.TempDir()
+	generators := []Generator{
+		&Hibernate{config: HibernateConfig{Output: output, Templates: "templates/hibernate", PackageName: "com.example", GenerateMetamodel: true}},
+		&ProtoBuf{config: ProtoBufConfig{Output: output, Templates: "templates/protobuf", PackageName: "example", EnumFilePerType: true}},
+	}
+	for _, gen := range generators {
+		before := openFiles(t)
+		if err := gen.Build(ins, BuildOptions{}); err != nil {
+			t.Fatalf("%s: %s", gen.GetType(), err)
+		}
+		if after := openFiles(t); after != before {
+			t.Errorf("%s: %d files are left open", gen.GetType(), after-before)
+		}
+	}
+}
+
+func TestDryRunFiles(t *testing.T) {
+	files := dryRunFiles{root: "/out"}
+	if err := files.WriteFile("/out/a.txt", func(wr io.Writer) error {
+		return fmt.Errorf("template error")
+	}); err == nil || err.Error() != "template error" {
+		t.Errorf("should return the error of write: %v", err)
+	}
+}
+
+func TestSkipTable(t *testing.T) {
+	tests := []struct {
+		include []string
+		ignore  []string
+		table   string
+		skip    bool
+	}{
+		{nil, nil, "users", false},
+		{[]string{"order_*"}, nil, "order_items", false},
+		{[]string{"order_*"}, nil, "orders", true},
+		{[]string{"order_*", "users"}, nil, "users", false},
+		{[]string{"order_?"}, nil, "order_ab", true},
+		{[]string{"order_*"}, []string{"_archive$"}, "order_items", false},
+		{[]string{"order_*"}, []string{"_archive$"}, "order_items_archive", true},
+		{nil, []string{"_archive$"}, "users_archive", true},
+	}
+	for _, tt := range tests {
+		if actual := skipTable(tt.include, tt.ignore, tt.table); actual != tt.skip {
+			t.Errorf("include %v, ignore %v, %s: expected skip %t, actual: %t", tt.include, tt.ignore, tt.table, tt.skip, actual)
+		}
+	}
+}
+
+func TestMatchIgnore(t *testing.T) {
+	tests := []struct {
+		pattern string
+		table   string
+		match   bool
+	}{
+		{"users", "users", true},
+		{"users", "orders", false},
+		{"glob:*", "users", true},
+		{"glob:audit_*", "audit_user", true},
+		{"glob:audit_*", "user_audit", false},
+		{"glob:*_tmp", "orders_tmp", true},
+		{"glob:*_tmp", "orders_tmp_2", false},
+		{"glob:tmp_?", "tmp_1", true},
+		{"*", "users", true},
+		{"audit_*", "audit_user", true},
+		{"audit_*", "user_audit", false},
+		{"*_tmp", "orders_tmp", true},
+		{"*_tmp", "orders_tmp_2", false},
+		{"*_tmp_[0-9]", "orders_tmp_2", true},       // not a regular expression, but a glob
+		{"^flyway_", "flyway_schema_history", true}, // regular expressions keep working
+		{"history", "flyway_schema_history", true},
+		{"_v[0-9]+$", "users_v12", true},
+		{"tmp[0-9]", "user_tmp1_old", true},
+	}
+	for _, tt := range tests {
+		if actual := matchIgnore([]string{tt.pattern}, tt.table); actual != tt.match {
+			t.Errorf("%s, %s: expected %t, actual: %t", tt.pattern, tt.table, tt.match, actual)
+		}
+	}
+}
+
+func TestCheckIgnores(t *testing.T) {
+	if err := checkIgnores([]string{"users", "^flyway_", "audit_*", "*_tmp", "glob:tmp_?"}); err != nil {
+		t.Error(err)
+	}
+	for _, p := range []string{"[", "glob:order_[", "(a["} {
+		if err := checkIgnores([]string{p}); err == nil {
+			t.Errorf("%s: expected error", p)
+		}
+	}
+}
+
+// recordingFiles records written files in memory.
+type recordingFiles struct {
+	mu    sync.Mutex
+	paths []string
+	files map[string]string
+}
+
+func (files *recordingFiles) WriteFile(path string, write func(wr io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	files.mu.Lock()
+	defer files.mu.Unlock()
+	files.paths = append(files.paths, path)
+	if files.files == nil {
+		files.files = make(map[string]string)
+	}
+	files.files[path] = buf.String()
+	return nil
+}
+
+func TestWriteFiles(t *testing.T) {
+	var jobs []fileJob
+	for i := 0; i < 20; i++ {
+		i := i
+		jobs = append(jobs, fileJob{
+			path: fmt.Sprintf("f%d", i),
+			write: func(wr io.Writer) error {
+				if i == 12 || i == 15 {
+					return fmt.Errorf("failed %d", i)
+				}
+				_, err := fmt.Fprintf(wr, "content %d", i)
+				return err
+			},
+			wrap: fmt.Sprintf("job %d", i),
+		})
+	}
+	for _, concurrency := range []int{0, 1, 4, 30} {
+		files := &recordingFiles{}
+		err := BuildOptions{Files: files}.writeFiles(jobs, concurrency)
+		if err == nil || err.Error() != "job 12: failed 12" {
+			t.Errorf("concurrency %d: should return the first error: %v", concurrency, err)
+		}
+		if len(files.paths) != 12 {
+			t.Fatalf("concurrency %d: files before the error should be written: %v", concurrency, files.paths)
+		}
+		for i, path := range files.paths {
+			if path != fmt.Sprintf("f%d", i) || files.files[path] != fmt.Sprintf("content %d", i) {
+				t.Errorf("concurrency %d: unexpected file %d: %s %q", concurrency, i, path, files.files[path])
+			}
+		}
+	}
+}
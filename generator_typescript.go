@@ -0,0 +1,422 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type TypeScriptConfig struct {
+	Output       string   `json:"output"`
+	Templates    string   `json:"templates"`
+	IgnoreTables []string `json:"ignore_tables"`
+	// IgnoreColumns are columns ("column" or "table.column") left out of interfaces
+	IgnoreColumns []string `json:"ignore_columns"`
+	// SkipEmptyTables skips tables without columns, which may exist while migrating
+	SkipEmptyTables bool `json:"skip_empty_tables"`
+	// EnumStyle is how enums are written: "enum" (default), "union" of string literals or "const" objects
+	EnumStyle string `json:"enum_style"`
+	// EmitZod writes zod schemas like UsersSchema, and types inferred from them instead of interfaces
+	EmitZod bool `json:"emit_zod"`
+	// BrandedIds writes branded types like UsersId of single column primary keys, also used by foreign keys
+	BrandedIds bool `json:"branded_ids"`
+	// StableOutput leaves files whose content is unchanged, ignoring generated timestamps
+	StableOutput bool `json:"stable_output"`
+	// IncludeTables restricts generation to tables matching the glob patterns like order_*, before IgnoreTables
+	IncludeTables []string `json:"include_tables"`
+}
+
+type TypeScript struct {
+	db       *sql.DB
+	config   TypeScriptConfig
+	ins      InspectResult
+	template *template.Template
+	root     string
+	opts     BuildOptions
+}
+
+type TypeScriptMember struct {
+	Name     string
+	Type     string
+	Optional bool
+	Comment  string
+	// Zod is the zod schema of the field with emit_zod, like z.string().nullable().optional()
+	Zod string
+}
+
+// TypeScriptImport imports Names from the relative Module like "./users".
+type TypeScriptImport struct {
+	Names  string
+	Module string
+}
+
+// TypeScriptBrand is a branded type of a primary key, like type UsersId = number & { __brand: "UsersId" }.
+type TypeScriptBrand struct {
+	Name string
+	Type string
+}
+
+type TypeScriptTypeMember struct {
+	Name    string
+	Comment string
+	Values  []TypeScriptEnumValue
+}
+
+type TypeScriptEnumValue struct {
+	Name  string
+	Value string
+}
+
+const TypeScriptTypeName = "typescript"
+
+// styles of enums
+const (
+	TypeScriptEnumStyleEnum  = "enum"
+	TypeScriptEnumStyleUnion = "union"
+	TypeScriptEnumStyleConst = "const"
+)
+
+// typeScriptEnumModule is the module of enums, imported relatively from interfaces.
+const typeScriptEnumModule = "enums"
+
+func NewTypeScript(db *sql.DB, root string, raw json.RawMessage) (Generator, error) {
+	config, err := loadTypeScriptConfig(root, raw)
+	if err != nil {
+		return nil, err
+	}
+	ret := TypeScript{
+		db:     db,
+		config: config,
+		root:   root,
+	}
+
+	return &ret, nil
+}
+
+func (gen *TypeScript) GetType() string {
+	return TypeScriptTypeName
+}
+
+func (gen *TypeScript) Build(ins InspectResult, opts BuildOptions) error {
+	log.Printf("output: %s", opts.outputDir(gen.root, gen.config.Output))
+	log.Printf("templates: %s", filePathJoinRoot(gen.root, gen.config.Templates))
+	gen.ins = ins
+	gen.opts = opts
+	opts = opts.stableOutput(gen.config.StableOutput)
+
+	// Load templates
+	tdir := filepath.Join(filePathJoinRoot(gen.root, gen.config.Templates), "*.tmpl")
+	t := template.Must(template.New("").Funcs(templateFuncs()).ParseGlob(tdir))
+	gen.template = t
+
+	// Build tables and row types of functions
+	for _, table := range gen.ins.TablesAndFunctions() {
+		if skipTable(gen.config.IncludeTables, gen.config.IgnoreTables, table.Name) {
+			continue
+		}
+		if len(table.Columns) == 0 && gen.config.SkipEmptyTables {
+			log.Printf("skip %s: no columns", table.Name)
+			continue
+		}
+		fileName := SnakeToLowerCamel(table.Name) + ".ts"
+		if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), fileName), func(wr io.Writer) error {
+			return gen.buildTable(wr, table)
+		}); err != nil {
+			return errors.Wrap(err, "build write table")
+		}
+	}
+
+	// Build types
+	if err := opts.writeFile(filepath.Join(opts.outputDir(gen.root, gen.config.Output), typeScriptEnumModule+".ts"), func(wr io.Writer) error {
+		return gen.buildType(wr, gen.ins.Types)
+	}); err != nil {
+		return errors.Wrap(err, "build write type")
+	}
+
+	return nil
+}
+
+func (gen *TypeScript) buildTable(wr io.Writer, table Table) error {
+	var brand *TypeScriptBrand
+	if pk, ok := gen.brandedKey(table); ok {
+		brand = &TypeScriptBrand{Name: typeScriptBrandName(table.Name), Type: gen.convertType(pk)}
+	}
+	return gen.template.ExecuteTemplate(wr, "interface", map[string]interface{}{
+		"stamp":   gen.opts.Stamp,
+		"now":     time.Now().UTC().Format(time.RFC3339),
+		"comment": strings.Replace(table.Comment.String, "\n", " ", -1),
+		"name":    SnakeToUpperCamel(table.Name),
+		"enums":   strings.Join(gen.enums(table), ", "),
+		"module":  "./" + typeScriptEnumModule,
+		"zod":     gen.config.EmitZod,
+		"imports": gen.brandImports(table),
+		"brand":   brand,
+		"member":  gen.members(table),
+	})
+}
+
+func typeScriptBrandName(table string) string {
+	return SnakeToUpperCamel(table) + "Id"
+}
+
+// brandedKey returns the primary key column of table if it has a branded type, which needs
+// branded_ids and a primary key of one column.
+func (gen *TypeScript) brandedKey(table Table) (Column, bool) {
+	if !gen.config.BrandedIds {
+		return Column{}, false
+	}
+	var pks []Column
+	for _, col := range table.Columns {
+		if col.PrimaryKey {
+			pks = append(pks, col)
+		}
+	}
+	if len(pks) != 1 || pks[0].Array {
+		return Column{}, false
+	}
+	return pks[0], true
+}
+
+// brandReference returns the table whose branded key col references, if any. References to
+// tables of other schemas or tables not generated are left out.
+func (gen *TypeScript) brandReference(col Column) (string, bool) {
+	if !gen.config.BrandedIds || !col.ForeignKeySrc.Valid || col.Array {
+		return "", false
+	}
+	fk, err := parseForeignKey(col.ForeignKeySrc.String)
+	if err != nil || len(fk.Columns) != 1 || strings.Contains(fk.RefTable, ".") {
+		return "", false
+	}
+	if skipTable(gen.config.IncludeTables, gen.config.IgnoreTables, fk.RefTable) {
+		return "", false
+	}
+	for _, table := range gen.ins.Tables {
+		if table.Name != fk.RefTable {
+			continue
+		}
+		if pk, ok := gen.brandedKey(table); ok && pk.Name == fk.RefColumns[0] {
+			return table.Name, true
+		}
+	}
+	return "", false
+}
+
+// brandImports returns imports of branded types of other tables referenced by table.
+func (gen *TypeScript) brandImports(table Table) []TypeScriptImport {
+	var ret []TypeScriptImport
+	var seen []string
+	for _, col := range gen.columns(table) {
+		ref, ok := gen.brandReference(col)
+		if !ok || ref == table.Name || contains(seen, ref) {
+			continue
+		}
+		seen = append(seen, ref)
+		ret = append(ret, TypeScriptImport{Names: typeScriptBrandName(ref), Module: "./" + SnakeToLowerCamel(ref)})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Module < ret[j].Module
+	})
+	return ret
+}
+
+func (gen *TypeScript) columns(table Table) []Column {
+	var ret []Column
+	for _, col := range table.Columns {
+		if containsColumn(gen.config.IgnoreColumns, table.Name, col.Name) {
+			continue
+		}
+		ret = append(ret, col)
+	}
+	return ret
+}
+
+func (gen *TypeScript) members(table Table) []TypeScriptMember {
+	var ret []TypeScriptMember
+
+	for _, col := range gen.columns(table) {
+		m := TypeScriptMember{
+			Name:     SnakeToLowerCamel(col.Name),
+			Type:     gen.convertType(col),
+			Optional: !col.NotNull,
+			Comment:  strings.Replace(col.Comment.String, "\n", " ", -1),
+		}
+		if pk, ok := gen.brandedKey(table); ok && pk.Name == col.Name {
+			m.Type = typeScriptBrandName(table.Name)
+		} else if ref, ok := gen.brandReference(col); ok {
+			m.Type = typeScriptBrandName(ref)
+		}
+		if gen.config.EmitZod {
+			m.Zod = gen.zodType(col)
+			if m.Type != gen.convertType(col) {
+				// branded types are not validated, only asserted
+				m.Zod += fmt.Sprintf(".transform((v) => v as %s)", m.Type)
+			}
+			if m.Optional {
+				m.Zod += ".nullable().optional()"
+			}
+		}
+		ret = append(ret, m)
+	}
+	return ret
+}
+
+// enums returns names of enums used by the interface of table. zod schemas have their
+// values instead.
+func (gen *TypeScript) enums(table Table) []string {
+	if gen.config.EmitZod {
+		return nil
+	}
+	var ret []string
+	for _, col := range gen.columns(table) {
+		typ, err := gen.ins.FindType(strings.TrimSuffix(col.DataType, "[]"))
+		if err != nil {
+			continue
+		}
+		if name := SnakeToUpperCamel(typ.Name); !contains(ret, name) {
+			ret = append(ret, name)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (gen *TypeScript) buildType(wr io.Writer, types []Type) error {
+	var members []TypeScriptTypeMember
+	for _, typ := range types {
+		m := TypeScriptTypeMember{
+			Name:    SnakeToUpperCamel(typ.Name),
+			Comment: strings.Replace(typ.Comment.String, "\n", " ", -1),
+		}
+		for _, val := range typ.Values {
+			name := SnakeToUpperCamel(val)
+			if isNumber(val) {
+				name = "Value" + name
+			}
+			m.Values = append(m.Values, TypeScriptEnumValue{Name: name, Value: strconv.Quote(val)})
+		}
+		members = append(members, m)
+	}
+
+	style := gen.config.EnumStyle
+	if style == "" {
+		style = TypeScriptEnumStyleEnum
+	}
+	return gen.template.ExecuteTemplate(wr, "enum", map[string]interface{}{
+		"stamp":   gen.opts.Stamp,
+		"now":     time.Now().UTC().Format(time.RFC3339),
+		"style":   style,
+		"members": members,
+	})
+}
+
+func (gen *TypeScript) convertType(col Column) string {
+	col = resolveDomain(gen.ins, col)
+	if strings.HasSuffix(col.DataType, "[]") {
+		col.DataType = strings.Replace(col.DataType, "[]", "", 1)
+		return gen.convertType(col) + "[]"
+	}
+
+	switch col.DataType {
+	case "text", "uuid":
+		return "string"
+	case "smallint", "int2", "int", "integer", "bigint", "smallserial", "serial", "bigserial", "numeric", "float", "double", "double precision":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "date", "timestamp":
+		// ISO 8601 strings in JSON
+		return "string"
+	case "json", "jsonb":
+		return "unknown"
+	case "bytea":
+		// base64 in JSON
+		return "string"
+	case "int2vector", "oidvector":
+		// space separated numbers like "1 2"
+		return "string"
+	default:
+		// "timestamp with time zone", "timestamp without time zone", "timestamp(n) with time zone"
+		if strings.HasSuffix(col.DataType, "time zone") {
+			return "string"
+		}
+		if strings.HasPrefix(col.DataType, "numeric") {
+			return "number"
+		}
+		if strings.HasPrefix(col.DataType, "character") {
+			return "string"
+		}
+
+		typ, err := gen.ins.FindType(col.DataType)
+		if err == nil {
+			return SnakeToUpperCamel(typ.Name)
+		}
+	}
+	// unknown types must be narrowed before use
+	return "unknown"
+}
+
+// zodType returns the zod schema of values of col, like z.array(z.string()).
+func (gen *TypeScript) zodType(col Column) string {
+	if strings.HasSuffix(col.DataType, "[]") {
+		col.DataType = strings.Replace(col.DataType, "[]", "", 1)
+		return "z.array(" + gen.zodType(col) + ")"
+	}
+	switch col.DataType {
+	case "uuid":
+		return "z.string().uuid()"
+	case "smallint", "int2", "int", "integer", "bigint", "smallserial", "serial", "bigserial":
+		return "z.number().int()"
+	}
+	if typ, err := gen.ins.FindType(col.DataType); err == nil {
+		var values []string
+		for _, val := range typ.Values {
+			values = append(values, strconv.Quote(val))
+		}
+		return "z.enum([" + strings.Join(values, ", ") + "])"
+	}
+	switch gen.convertType(col) {
+	case "string":
+		return "z.string()"
+	case "number":
+		return "z.number()"
+	case "boolean":
+		return "z.boolean()"
+	}
+	return "z.unknown()"
+}
+
+func loadTypeScriptConfig(root string, raw json.RawMessage) (TypeScriptConfig, error) {
+	var tc TypeScriptConfig
+	if err := json.Unmarshal(raw, &tc); err != nil {
+		return tc, fmt.Errorf("typescript config error: %s", err)
+	}
+	if err := applyEnvOverrides(TypeScriptTypeName, &tc); err != nil {
+		return tc, fmt.Errorf("typescript config error: %s", err)
+	}
+	output := filePathJoinRoot(root, tc.Output)
+	if err := DirExists(output); err != nil {
+		return tc, fmt.Errorf("typescript output is not exists: %s", tc.Output)
+	}
+	if err := checkGlobs(tc.IncludeTables); err != nil {
+		return tc, fmt.Errorf("typescript include_tables: %s", err)
+	}
+	if err := checkIgnores(tc.IgnoreTables); err != nil {
+		return tc, fmt.Errorf("typescript ignore_tables: %s", err)
+	}
+	switch tc.EnumStyle {
+	case "", TypeScriptEnumStyleEnum, TypeScriptEnumStyleUnion, TypeScriptEnumStyleConst:
+	default:
+		return tc, fmt.Errorf("typescript enum_style must be enum, union or const: %s", tc.EnumStyle)
+	}
+	return tc, nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestTypeScriptConvertType(t *testing.T) {
+	gen := TypeScript{
+		ins: InspectResult{
+			Types:   []Type{Type{Name: "order_status", Values: []string{"open"}}},
+			Domains: []Type{Type{Name: "email", BaseType: "character varying(255)"}},
+		},
+	}
+	ff := [][]string{
+		[]string{"text", "string"},
+		[]string{"smallint", "number"},
+		[]string{"int2", "number"},
+		[]string{"smallserial", "number"},
+		[]string{"integer", "number"},
+		[]string{"bigint", "number"},
+		[]string{"numeric(10,2)", "number"},
+		[]string{"boolean", "boolean"},
+		[]string{"date", "string"},
+		[]string{"timestamp with time zone", "string"},
+		[]string{"text[]", "string[]"},
+		[]string{"order_status", "OrderStatus"},
+		[]string{"order_status[]", "OrderStatus[]"},
+		[]string{"email", "string"},
+		[]string{"email[]", "string[]"},
+		[]string{"fooBar", "unknown"},
+	}
+	for _, d := range ff {
+		col := Column{
+			DataType: d[0],
+		}
+		if actual := gen.convertType(col); actual != d[1] {
+			t.Errorf("%s: expected %s, actual: %s", d[0], d[1], actual)
+		}
+	}
+}
+
+func TestTypeScriptInterface(t *testing.T) {
+	gen := TypeScript{
+		config: TypeScriptConfig{IgnoreColumns: []string{"users.password_hash"}},
+		ins: InspectResult{
+			Types: []Type{Type{Name: "user_status", Values: []string{"active", "on_hold"}}},
+		},
+		template: template.Must(template.ParseGlob("templates/typescript/*.tmpl")),
+	}
+	table := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "bigint", NotNull: true},
+			Column{Name: "display_name", DataType: "text"},
+			Column{Name: "status", DataType: "user_status", NotNull: true},
+			Column{Name: "password_hash", DataType: "text", NotNull: true},
+		},
+	}
+	var buf bytes.Buffer
+	if err := gen.buildTable(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{
+		`import { UserStatus } from "./enums";`,
+		"export interface Users {",
+		"  id: number;\n",
+		"  displayName?: string;\n",
+		"  status: UserStatus;\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "passwordHash") {
+		t.Errorf("ignored column is generated:\n%s", out)
+	}
+
+	buf.Reset()
+	if err := gen.buildType(&buf, gen.ins.Types); err != nil {
+		t.Fatal(err)
+	}
+	expected := "export enum UserStatus {\n  Active = \"active\",\n  OnHold = \"on_hold\",\n}"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in:\n%s", expected, buf.String())
+	}
+}
+
+func TestTypeScriptBrandedIds(t *testing.T) {
+	users := Table{
+		Name:    "users",
+		Columns: []Column{Column{Name: "id", DataType: "bigint", NotNull: true, PrimaryKey: true}},
+	}
+	orders := Table{
+		Name: "orders",
+		Columns: []Column{
+			Column{Name: "id", DataType: "uuid", NotNull: true, PrimaryKey: true},
+			Column{Name: "user_id", DataType: "bigint", NotNull: true,
+				ForeignKeySrc: sql.NullString{String: "FOREIGN KEY (user_id) REFERENCES users(id)", Valid: true}},
+			Column{Name: "parent_id", DataType: "uuid",
+				ForeignKeySrc: sql.NullString{String: "FOREIGN KEY (parent_id) REFERENCES orders(id)", Valid: true}},
+		},
+	}
+	gen := TypeScript{
+		config:   TypeScriptConfig{BrandedIds: true},
+		ins:      InspectResult{Tables: []Table{users, orders}},
+		template: template.Must(template.ParseGlob("templates/typescript/*.tmpl")),
+	}
+	var buf bytes.Buffer
+	if err := gen.buildTable(&buf, orders); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{
+		"\n\nimport { UsersId } from \"./users\";\n\nexport type OrdersId = string & { __brand: \"OrdersId\" };\n",
+		"  id: OrdersId;\n",
+		"  userId: UsersId;\n",
+		"  parentId?: OrdersId;\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+
+	gen.config.BrandedIds = false
+	buf.Reset()
+	if err := gen.buildTable(&buf, orders); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); strings.Contains(out, "__brand") || !strings.Contains(out, "  userId: number;\n") {
+		t.Errorf("unexpected branded types:\n%s", out)
+	}
+}
+
+func TestTypeScriptEnumStyle(t *testing.T) {
+	types := []Type{
+		Type{Name: "user_status", Values: []string{"active", "on_hold"}},
+		Type{Name: "empty"},
+	}
+	tests := []struct {
+		style    string
+		expected []string
+	}{
+		{"", []string{"export enum UserStatus {\n  Active = \"active\",\n  OnHold = \"on_hold\",\n}\n"}},
+		{"enum", []string{"export enum UserStatus {\n  Active = \"active\",\n  OnHold = \"on_hold\",\n}\n"}},
+		{"union", []string{
+			"export type UserStatus = \"active\" | \"on_hold\";\n",
+			"export type Empty = never;\n",
+		}},
+		{"const", []string{
+			"export const UserStatus = {\n  Active: \"active\",\n  OnHold: \"on_hold\",\n} as const;\n" +
+				"export type UserStatus = (typeof UserStatus)[keyof typeof UserStatus];\n",
+		}},
+	}
+	for _, tt := range tests {
+		gen := TypeScript{
+			config:   TypeScriptConfig{EnumStyle: tt.style},
+			template: template.Must(template.ParseGlob("templates/typescript/*.tmpl")),
+		}
+		var buf bytes.Buffer
+		if err := gen.buildType(&buf, types); err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range tt.expected {
+			if !strings.Contains(buf.String(), expected) {
+				t.Errorf("%s: expected %q in:\n%s", tt.style, expected, buf.String())
+			}
+		}
+	}
+
+	if _, err := loadTypeScriptConfig(".", []byte(`{"output": ".", "enum_style": "object"}`)); err == nil {
+		t.Error("unknown enum_style should be an error")
+	}
+}
+
+func TestTypeScriptZod(t *testing.T) {
+	users := Table{
+		Name: "users",
+		Columns: []Column{
+			Column{Name: "id", DataType: "bigint", NotNull: true, PrimaryKey: true},
+			Column{Name: "display_name", DataType: "text"},
+			Column{Name: "status", DataType: "user_status", NotNull: true},
+			Column{Name: "tags", DataType: "text[]", NotNull: true},
+			Column{Name: "balance", DataType: "numeric(10,2)"},
+			Column{Name: "active", DataType: "boolean", NotNull: true},
+			Column{Name: "memo", DataType: "jsonb"},
+			Column{Name: "rank", DataType: "smallint", NotNull: true},
+		},
+	}
+	gen := TypeScript{
+		config: TypeScriptConfig{EmitZod: true},
+		ins: InspectResult{
+			Tables: []Table{users},
+			Types:  []Type{Type{Name: "user_status", Values: []string{"active", "on_hold"}}},
+		},
+		template: template.Must(template.ParseGlob("templates/typescript/*.tmpl")),
+	}
+	var buf bytes.Buffer
+	if err := gen.buildTable(&buf, users); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{
+		"\n\nimport { z } from \"zod\";\n\nexport const UsersSchema = z.object({\n",
+		"  id: z.number().int(),\n",
+		"  displayName: z.string().nullable().optional(),\n",
+		"  status: z.enum([\"active\", \"on_hold\"]),\n",
+		"  tags: z.array(z.string()),\n",
+		"  balance: z.number().nullable().optional(),\n",
+		"  active: z.boolean(),\n",
+		"  memo: z.unknown().nullable().optional(),\n",
+		"  rank: z.number().int(),\n",
+		"});\n\nexport type Users = z.infer<typeof UsersSchema>;\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "interface") || strings.Contains(out, "./enums") {
+		t.Errorf("zod schemas replace interfaces:\n%s", out)
+	}
+
+	gen.config.BrandedIds = true
+	buf.Reset()
+	if err := gen.buildTable(&buf, users); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "  id: z.number().int().transform((v) => v as UsersId),\n"; !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected %q in:\n%s", expected, buf.String())
+	}
+}
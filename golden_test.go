@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata/golden")
+
+func goldenFixture() InspectResult {
+	comment := func(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+	return InspectResult{
+		Tables: []Table{
+			Table{
+				Schema:  "public",
+				Name:    "users",
+				Comment: comment("users of the service"),
+				Columns: []Column{
+					Column{FieldOrdinal: 1, Name: "id", DataType: "bigint", NotNull: true, PrimaryKey: true, Serial: true,
+						DefaultValue: comment("nextval('users_id_seq'::regclass)"), Constraint: comment("p")},
+					Column{FieldOrdinal: 2, Name: "name", DataType: "text", NotNull: true, Comment: comment("display name")},
+					Column{FieldOrdinal: 3, Name: "email", DataType: "character varying(255)", Unique: true},
+					Column{FieldOrdinal: 4, Name: "status", DataType: "user_status", NotNull: true,
+						DefaultValue: comment("'active'::user_status")},
+					Column{FieldOrdinal: 5, Name: "tags", DataType: "text[]", Array: true, ArrayDims: 1},
+					Column{FieldOrdinal: 6, Name: "balance", DataType: "numeric(10,2)", NumericPrecision: 10, NumericScale: 2},
+					Column{FieldOrdinal: 7, Name: "created_at", DataType: "timestamp with time zone", NotNull: true,
+						DefaultValue: comment("now()")},
+				},
+			},
+			Table{
+				Schema: "public",
+				Name:   "orders",
+				Columns: []Column{
+					Column{FieldOrdinal: 1, Name: "id", DataType: "bigint", NotNull: true, PrimaryKey: true, Constraint: comment("p"),
+						IdentityKind: IdentityAlways},
+					Column{FieldOrdinal: 2, Name: "user_id", DataType: "bigint", NotNull: true,
+						ForeignKeySrc: comment("FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE")},
+					Column{FieldOrdinal: 3, Name: "memo", DataType: "jsonb"},
+					Column{FieldOrdinal: 4, Name: "shipping_address", DataType: "address"},
+				},
+				Indexs: []Index{
+					Index{Name: "orders_user_id_idx", Columns: []Column{Column{Name: "user_id"}}},
+				},
+			},
+			Table{
+				Schema:  "public",
+				Name:    "tenant_orders",
+				Comment: comment("orders numbered per tenant"),
+				Columns: []Column{
+					Column{FieldOrdinal: 1, Name: "tenant_id", DataType: "bigint", NotNull: true, PrimaryKey: true, Constraint: comment("p")},
+					Column{FieldOrdinal: 2, Name: "order_id", DataType: "bigint", NotNull: true, PrimaryKey: true, Constraint: comment("p")},
+					Column{FieldOrdinal: 3, Name: "total", DataType: "numeric(12,2)", NotNull: true, NumericPrecision: 12, NumericScale: 2},
+				},
+			},
+			Table{
+				Schema: "public",
+				Name:   "campaigns",
+				Columns: []Column{
+					Column{FieldOrdinal: 1, Name: "id", DataType: "bigint", NotNull: true, PrimaryKey: true, Constraint: comment("p")},
+					Column{FieldOrdinal: 2, Name: "start_date", DataType: "date", NotNull: true},
+					Column{FieldOrdinal: 3, Name: "end_date", DataType: "date", NotNull: true},
+				},
+				TableChecks: []Check{Check{Name: "campaigns_check", Expression: "start_date < end_date"}},
+			},
+		},
+		Views: []Table{
+			Table{
+				Schema:   "public",
+				Name:     "active_users",
+				DataType: "v",
+				IsView:   true,
+				Columns: []Column{
+					Column{FieldOrdinal: 1, Name: "id", DataType: "bigint", Source: "users.id"},
+					Column{FieldOrdinal: 2, Name: "name", DataType: "text", Source: "users.name"},
+				},
+			},
+		},
+		Types: []Type{
+			Type{Schema: "public", DataType: "e", Name: "user_status", Comment: comment("status of users"), Values: []string{"active", "banned"}},
+		},
+		Composites: []Type{
+			Type{Schema: "public", DataType: "c", Name: "address", Comment: comment("postal address"), Attributes: []Column{
+				Column{FieldOrdinal: 1, Name: "street", DataType: "text"},
+				Column{FieldOrdinal: 2, Name: "zip", DataType: "text"},
+			}},
+		},
+	}
+}
+
+func TestInspectResultRoundTrip(t *testing.T) {
+	ins := goldenFixture()
+	var buf bytes.Buffer
+	if err := DumpInspectResult(&buf, ins); err != nil {
+		t.Fatal(err)
+	}
+	compareGolden(t, filepath.Join("testdata", "golden", "schema.json"), buf.Bytes())
+
+	loaded, err := LoadInspectResult(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, ins) {
+		t.Errorf("expected %+v, actual: %+v", ins, loaded)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	file, err := os.Open(filepath.Join("testdata", "golden", "schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	ins, err := LoadInspectResult(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generators := map[string]func(output string) Generator{
+		HibernateTypeName: func(output string) Generator {
+			return &Hibernate{config: HibernateConfig{Output: output, Templates: "templates/hibernate", PackageName: "com.example.entity"}, root: "."}
+		},
+		ProtoBufTypeName: func(output string) Generator {
+			return &ProtoBuf{config: ProtoBufConfig{Output: output, Templates: "templates/protobuf", PackageName: "example"}, root: "."}
+		},
+		SphinxTypeName: func(output string) Generator {
+			return &Sphinx{config: SphinxConfig{Output: output, Templates: "templates/sphinx"}, root: "."}
+		},
+		PydanticTypeName: func(output string) Generator {
+			return &Pydantic{config: PydanticConfig{Output: output, Templates: "templates/pydantic"}, root: "."}
+		},
+		TypeScriptTypeName: func(output string) Generator {
+			return &TypeScript{config: TypeScriptConfig{Output: output, Templates: "templates/typescript"}, root: "."}
+		},
+		GraphQLTypeName: func(output string) Generator {
+			return &GraphQL{config: GraphQLConfig{Output: output, Templates: "templates/graphql"}, root: "."}
+		},
+		GoStructTypeName: func(output string) Generator {
+			return &GoStruct{config: GoStructConfig{Output: output, Templates: "templates/gostruct", PackageName: "model"}, root: "."}
+		},
+		KotlinTypeName: func(output string) Generator {
+			return &Kotlin{config: KotlinConfig{Output: output, Templates: "templates/kotlin", PackageName: "com.example.model"}, root: "."}
+		},
+		JSONSchemaTypeName: func(output string) Generator {
+			return &JSONSchema{config: JSONSchemaConfig{Output: output}, root: "."}
+		},
+	}
+	for typ, newGenerator := range generators {
+		output := t.TempDir()
+		if err := newGenerator(output).Build(ins, BuildOptions{}); err != nil {
+			t.Fatalf("%s: %s", typ, err)
+		}
+		dir := filepath.Join("testdata", "golden", typ)
+		files, err := ioutil.ReadDir(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range files {
+			b, err := ioutil.ReadFile(filepath.Join(output, f.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareGolden(t, filepath.Join(dir, f.Name()), b)
+		}
+		if golden, _ := ioutil.ReadDir(dir); len(golden) != len(files) {
+			t.Errorf("%s: expected %d files, actual: %d", typ, len(golden), len(files))
+		}
+	}
+}
+
+// compareGolden compares actual with the golden file, or rewrites the golden file with -update.
+func compareGolden(t *testing.T, golden string, actual []byte) {
+	t.Helper()
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(golden, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("%s differs, run go test -update to review:\n%s", golden, actual)
+	}
+}
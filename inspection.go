@@ -1,17 +1,30 @@
 package main
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"sort"
+	"strconv"
 	"strings"
-	"regexp"
 
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 )
 
+// InspectResult is shared by all generators of a run. Generators must treat it as read-only.
 type InspectResult struct {
-	Tables []Table
-	Types  []Type
+	Tables    []Table
+	Types     []Type
+	Functions []Table // row types of set-returning functions, read-only
+	Views     []Table // views and materialized views, read-only
+	// Composites are composite types created by CREATE TYPE ... AS (...), read-only
+	Composites []Type
+	// Domains are types created by CREATE DOMAIN, read-only
+	Domains []Type
 }
 
 type Table struct {
@@ -22,7 +35,15 @@ type Table struct {
 	AutoGenPk   bool
 	PrimaryKeys []Column
 	Columns     []Column
+	// Indexs are indexes other than the primary key index, unique or not
 	Indexs      []Index
+	TableChecks []Check // CHECK constraints spanning multiple columns
+	IsView      bool    // view or materialized view, which has no primary key
+}
+
+type Check struct {
+	Name       string
+	Expression string
 }
 
 type Column struct {
@@ -31,7 +52,7 @@ type Column struct {
 	Comment       sql.NullString // comment
 	DataType      string         // data type
 	NotNull       bool           // not null
-	DefaultValue  sql.NullString // default value
+	DefaultValue  sql.NullString // default expression, null if none
 	PrimaryKey    bool
 	Unique        bool
 	Serial        bool
@@ -39,17 +60,45 @@ type Column struct {
 	Array         bool
 	Constraint    sql.NullString
 	ConstraintSrc sql.NullString
-	ForignTable   sql.NullString
+	ForignTable   sql.NullString // referenced table of the foreign key, "schema.table" in other schemas
 	SerialSrc     sql.NullString
 	IndexDef      sql.NullString
+	ForeignKeySrc sql.NullString
+	ArrayDims     int            // declared number of array dimensions
+	Storage       string         // attstorage: p, e, m or x
+	TypeStorage   string         // default storage of the data type
+	Compression   sql.NullString // attcompression (PostgreSQL 14+)
+	// NumericPrecision and NumericScale are declared by numeric(p,s), zero if unconstrained
+	NumericPrecision int
+	NumericScale     int
+	// Generated is a stored generated column (PostgreSQL 12+), whose expression is DefaultValue
+	Generated bool
+	// IdentityKind is attidentity of identity columns (PostgreSQL 10+), IdentityAlways or IdentityByDefault
+	IdentityKind string
+	// Source is "table.column" a view column is read from, empty if it can not be attributed
+	Source string
 }
 
+// kinds of identity columns
+const (
+	IdentityAlways    = "a" // GENERATED ALWAYS AS IDENTITY
+	IdentityByDefault = "d" // GENERATED BY DEFAULT AS IDENTITY
+)
+
 type Type struct {
 	DataType string
 	Name     string
 	Comment  sql.NullString
 	NotNull  bool
 	Values   []string
+	// Attributes are fields of composite types
+	Attributes []Column
+	// BaseType is the underlying type of domains, like "character varying(255)"
+	BaseType string
+	// Check is the CHECK constraints of domains
+	Check sql.NullString
+	// Schema is the schema of the type, empty in snapshots taken before schemas were recorded
+	Schema string
 }
 
 type Index struct {
@@ -57,6 +106,7 @@ type Index struct {
 	Name     string
 	Columns  []Column
 	Comment  sql.NullString
+	Unique   bool
 }
 
 func (ins InspectResult) FindType(name string) (Type, error) {
@@ -68,14 +118,176 @@ func (ins InspectResult) FindType(name string) (Type, error) {
 	return Type{}, fmt.Errorf("not found")
 }
 
-func Inspect(db *sql.DB) (InspectResult, error) {
-	var ret InspectResult
+// FindDomain returns the domain of name.
+func (ins InspectResult) FindDomain(name string) (Type, error) {
+	for _, typ := range ins.Domains {
+		if typ.Name == name {
+			return typ, nil
+		}
+	}
+	return Type{}, fmt.Errorf("not found")
+}
+
+// FindComposite returns the composite type of name.
+func (ins InspectResult) FindComposite(name string) (Type, error) {
+	for _, typ := range ins.Composites {
+		if typ.Name == name {
+			return typ, nil
+		}
+	}
+	return Type{}, fmt.Errorf("not found")
+}
 
-	tables, err := getTables(db, "public")
+// Hash returns a stable hash of the inspected schema. It doesn't depend on the order of
+// tables, columns and types, and nil and empty slices hash the same.
+func (ins InspectResult) Hash() string {
+	canon := InspectResult{
+		Tables:     canonicalTables(ins.Tables),
+		Types:      canonicalTypes(ins.Types),
+		Functions:  canonicalTables(ins.Functions),
+		Views:      canonicalTables(ins.Views),
+		Composites: canonicalTypes(ins.Composites),
+		Domains:    canonicalTypes(ins.Domains),
+	}
+	b, err := json.Marshal(canon)
 	if err != nil {
-		return ret, errors.Wrap(err, "Inspect")
+		// all fields are plain values
+		panic(err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func canonicalTables(src []Table) []Table {
+	if len(src) == 0 {
+		return nil
+	}
+	ret := make([]Table, len(src))
+	for i, t := range src {
+		t.PrimaryKeys = canonicalColumns(t.PrimaryKeys, true)
+		t.Columns = canonicalColumns(t.Columns, true)
+		t.Indexs = canonicalIndexes(t.Indexs)
+		if len(t.TableChecks) > 0 {
+			checks := append([]Check{}, t.TableChecks...)
+			sort.Slice(checks, func(a, b int) bool {
+				return checks[a].Name+":"+checks[a].Expression < checks[b].Name+":"+checks[b].Expression
+			})
+			t.TableChecks = checks
+		} else {
+			t.TableChecks = nil
+		}
+		ret[i] = t
+	}
+	sort.Slice(ret, func(a, b int) bool {
+		return ret[a].Schema+"."+ret[a].Name < ret[b].Schema+"."+ret[b].Name
+	})
+	return ret
+}
+
+func canonicalColumns(src []Column, sorted bool) []Column {
+	if len(src) == 0 {
+		return nil
+	}
+	ret := make([]Column, len(src))
+	copy(ret, src)
+	if sorted {
+		sort.Slice(ret, func(a, b int) bool {
+			return ret[a].Name < ret[b].Name
+		})
+	}
+	return ret
+}
+
+func canonicalTypes(src []Type) []Type {
+	if len(src) == 0 {
+		return nil
+	}
+	ret := make([]Type, len(src))
+	for i, t := range src {
+		// order of enum values is meaningful
+		t.Values = canonicalStrings(t.Values, false)
+		t.Attributes = canonicalColumns(t.Attributes, true)
+		ret[i] = t
+	}
+	// types of different schemas may have the same name
+	sort.Slice(ret, func(a, b int) bool {
+		if ret[a].Schema != ret[b].Schema {
+			return ret[a].Schema < ret[b].Schema
+		}
+		return ret[a].Name < ret[b].Name
+	})
+	return ret
+}
+
+func canonicalStrings(src []string, sorted bool) []string {
+	if len(src) == 0 {
+		return nil
+	}
+	ret := make([]string, len(src))
+	copy(ret, src)
+	if sorted {
+		sort.Strings(ret)
+	}
+	return ret
+}
+
+func canonicalIndexes(src []Index) []Index {
+	if len(src) == 0 {
+		return nil
+	}
+	ret := make([]Index, len(src))
+	for i, idx := range src {
+		// order of index columns is meaningful
+		idx.Columns = canonicalColumns(idx.Columns, false)
+		ret[i] = idx
+	}
+	sort.Slice(ret, func(a, b int) bool {
+		return indexKey(ret[a]) < indexKey(ret[b])
+	})
+	return ret
+}
+
+func indexKey(idx Index) string {
+	var names []string
+	for _, c := range idx.Columns {
+		names = append(names, c.Name)
+	}
+	return idx.Name + "(" + strings.Join(names, ",") + ")"
+}
+
+// Inspect reads tables, views, functions and composite types of schemas, "public" if none.
+// Enums and domains of every schema are read, as columns may use them.
+func Inspect(db *sql.DB, schemas []string) (InspectResult, error) {
+	var ret InspectResult
+	if len(schemas) == 0 {
+		schemas = []string{"public"}
+	}
+
+	for _, schema := range schemas {
+		tables, err := getTables(db, schema)
+		if err != nil {
+			return ret, errors.Wrap(err, "Inspect")
+		}
+		ret.Tables = append(ret.Tables, tables...)
+
+		functions, err := getFunctions(db, schema)
+		if err != nil {
+			return ret, errors.Wrap(err, "Inspect")
+		}
+		ret.Functions = append(ret.Functions, functions...)
+
+		views, err := getViews(db, schema)
+		if err != nil {
+			return ret, errors.Wrap(err, "Inspect")
+		}
+		ret.Views = append(ret.Views, views...)
+
+		composites, err := getComposites(db, schema)
+		if err != nil {
+			return ret, errors.Wrap(err, "Inspect")
+		}
+		ret.Composites = append(ret.Composites, composites...)
 	}
-	ret.Tables = tables
 
 	types, err := getTypes(db)
 	if err != nil {
@@ -83,10 +295,141 @@ func Inspect(db *sql.DB) (InspectResult, error) {
 	}
 	ret.Types = types
 
+	domains, err := getDomains(db)
+	if err != nil {
+		return ret, errors.Wrap(err, "Inspect")
+	}
+	ret.Domains = domains
+
+	return ret, nil
+}
+
+// InSchema returns the part of ins generated into the directory of schema: its tables, views,
+// functions and types. Types of schemas other than schemas, like extensions in public, are
+// shared by all of them, as are types of snapshots without schemas of types.
+func (ins InspectResult) InSchema(schema string, schemas []string) InspectResult {
+	tables := func(src []Table) []Table {
+		var ret []Table
+		for _, t := range src {
+			if t.Schema == schema {
+				ret = append(ret, t)
+			}
+		}
+		return ret
+	}
+	types := func(src []Type) []Type {
+		var ret []Type
+		for _, t := range src {
+			if t.Schema == schema || !contains(schemas, t.Schema) {
+				ret = append(ret, t)
+			}
+		}
+		return ret
+	}
+	return InspectResult{
+		Tables:     tables(ins.Tables),
+		Types:      types(ins.Types),
+		Functions:  tables(ins.Functions),
+		Views:      tables(ins.Views),
+		Composites: types(ins.Composites),
+		Domains:    types(ins.Domains),
+	}
+}
+
+// DumpInspectResult writes ins as a JSON snapshot, which LoadInspectResult reads back.
+func DumpInspectResult(w io.Writer, ins InspectResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ins)
+}
+
+// LoadInspectResult reads a JSON snapshot written by DumpInspectResult,
+// to generate without a database.
+func LoadInspectResult(r io.Reader) (InspectResult, error) {
+	var snap snapshot
+	if err := json.NewDecoder(r).Decode(&snap); err != nil {
+		return InspectResult{}, errors.Wrap(err, "LoadInspectResult")
+	}
+	ret := snap.InspectResult
+	var err error
+	if ret.Tables, err = snapshotTables(snap.Tables); err != nil {
+		return ret, errors.Wrap(err, "LoadInspectResult")
+	}
+	if ret.Functions, err = snapshotTables(snap.Functions); err != nil {
+		return ret, errors.Wrap(err, "LoadInspectResult")
+	}
+	if ret.Views, err = snapshotTables(snap.Views); err != nil {
+		return ret, errors.Wrap(err, "LoadInspectResult")
+	}
 	return ret, nil
 }
 
+// snapshot is an InspectResult as written by any version of DumpInspectResult.
+type snapshot struct {
+	InspectResult
+	Tables    []snapshotTable
+	Functions []snapshotTable
+	Views     []snapshotTable
+}
+
+// snapshotTable is a Table whose TableChecks may be bare expressions, written before
+// checks were named, or named checks in Checks, written after.
+type snapshotTable struct {
+	Table
+	TableChecks []json.RawMessage
+	Checks      []Check
+}
+
+// snapshotTables converts tables of older snapshots to the current Table.
+func snapshotTables(src []snapshotTable) ([]Table, error) {
+	if src == nil {
+		return nil, nil
+	}
+	ret := make([]Table, len(src))
+	for i, st := range src {
+		t := st.Table
+		t.TableChecks = st.Checks
+		if t.TableChecks == nil && st.TableChecks != nil {
+			t.TableChecks = []Check{}
+			for _, raw := range st.TableChecks {
+				var check Check
+				if err := json.Unmarshal(raw, &check.Expression); err != nil {
+					if err := json.Unmarshal(raw, &check); err != nil {
+						return nil, errors.Wrap(err, "checks of "+t.Name)
+					}
+				}
+				t.TableChecks = append(t.TableChecks, check)
+			}
+		}
+		for j, idx := range t.Indexs {
+			// snapshots taken before indexes were named only have unique indexes
+			if idx.Name == "" {
+				t.Indexs[j].Unique = true
+			}
+		}
+		ret[i] = t
+	}
+	return ret, nil
+}
+
+// TablesAndFunctions returns tables followed by row types of functions.
+func (ins InspectResult) TablesAndFunctions() []Table {
+	ret := make([]Table, 0, len(ins.Tables)+len(ins.Functions))
+	ret = append(ret, ins.Tables...)
+	return append(ret, ins.Functions...)
+}
+
 func getTables(db *sql.DB, schema string) ([]Table, error) {
+	return getRelations(db, schema, "r")
+}
+
+// getViews returns views (v) and materialized views (m), which have columns like tables.
+func getViews(db *sql.DB, schema string) ([]Table, error) {
+	return getRelations(db, schema, "vm")
+}
+
+// getRelations returns relations whose relkind is one of the characters of kinds.
+func getRelations(db *sql.DB, schema, kinds string) ([]Table, error) {
 	// https://github.com/achiku/dgw/blob/master/dgw.go
 	q := `SELECT
 c.relkind AS type,
@@ -95,10 +438,10 @@ obj_description(c.oid)
 FROM pg_class c
 JOIN ONLY pg_namespace n ON n.oid = c.relnamespace
 WHERE n.nspname = $1
-AND c.relkind = 'r'
+AND position(c.relkind::text IN $2) > 0
 ORDER BY c.relname
 `
-	rows, err := db.Query(q, schema)
+	rows, err := db.Query(q, schema, kinds)
 	if err != nil {
 		return nil, err
 	}
@@ -110,37 +453,210 @@ ORDER BY c.relname
 		if err := rows.Scan(&t.DataType, &t.Name, &t.Comment); err != nil {
 			return nil, errors.Wrap(err, "failed to scan of "+t.Name)
 		}
-		t.Indexs, err = getUniqueIndexes(db, schema, t.Name)
+		t.IsView = t.DataType == "v" || t.DataType == "m"
+		t.Indexs, err = getIndexes(db, schema, t.Name)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to get indexes of %s", t.Name))
+		}
+		t.TableChecks, err = getTableChecks(db, schema, t.Name)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to get checks of %s", t.Name))
+		}
 		cols, err := getColumns(db, schema, t.Name, false)
 		if err != nil {
 			return nil, errors.Wrap(err, fmt.Sprintf("failed to get columns of %s", t.Name))
 		}
+		if t.IsView {
+			sources, err := getViewColumnSources(db, schema, t.Name)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("failed to get column sources of %s", t.Name))
+			}
+			attributeViewColumns(cols, sources)
+		}
 		t.Columns = cols
 		tbs = append(tbs, t)
 	}
 	return tbs, nil
 }
 
-func getUniqueIndexes(db *sql.DB, schema string, table string) ([]Index, error) {
-	const sqlstr = `SELECT pg_catalog.Pg_get_indexdef(i.indexrelid, 0, true) AS indexdef 
-FROM   pg_catalog.pg_class c, 
-       pg_catalog.pg_class c2, 
-       pg_catalog.pg_namespace n, 
-       pg_catalog.pg_index i 
-       LEFT JOIN pg_catalog.pg_constraint con 
-              ON ( conrelid = i.indrelid 
-                   AND conindid = i.indexrelid 
-                   AND contype IN ( 'p', 'u', 'x' ) ) 
-WHERE  c.oid = i.indrelid 
-       AND i.indexrelid = c2.oid 
-       AND n.oid = c.relnamespace 
-       AND n.nspname = $1
-       AND c.relname = $2
-       AND i.indisunique = true 
-       AND i.indisprimary = false 
-ORDER  BY i.indisprimary DESC, 
-          i.indisunique DESC, 
-          c2.relname`
+// getViewColumnSources returns "table.column" of base table columns read by columns of the
+// view, keyed by their attnum. They are read from resorigtbl and resorigcol of the target list
+// of the view rule, which are only set for plain column references, so computed columns like
+// o.total * 2 AS total have none.
+func getViewColumnSources(db *sql.DB, schema string, view string) (map[int]string, error) {
+	const actionsql = `SELECT r.ev_action::text
+FROM pg_catalog.pg_rewrite r
+JOIN pg_catalog.pg_class v ON v.oid = r.ev_class
+JOIN pg_catalog.pg_namespace n ON n.oid = v.relnamespace
+WHERE r.rulename = '_RETURN'
+AND n.nspname = $1
+AND v.relname = $2`
+
+	var action string
+	err := db.QueryRow(actionsql, schema, view).Scan(&action)
+	if err == sql.ErrNoRows {
+		return map[int]string{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "view rule query")
+	}
+
+	var resnos, tables, attnums []int64
+	for _, te := range viewTargetEntries(action) {
+		if te.resorigtbl != 0 {
+			resnos = append(resnos, int64(te.resno))
+			tables = append(tables, te.resorigtbl)
+			attnums = append(attnums, int64(te.resorigcol))
+		}
+	}
+	sources := map[int]string{}
+	if len(resnos) == 0 {
+		return sources, nil
+	}
+
+	const sqlstr = `SELECT e.resno,
+CASE WHEN scn.nspname = $1 THEN sc.relname ELSE scn.nspname || '.' || sc.relname END,
+sa.attname
+FROM unnest($2::int[], $3::oid[], $4::int[]) AS e(resno, tbl, col)
+JOIN pg_catalog.pg_class sc ON sc.oid = e.tbl
+JOIN pg_catalog.pg_namespace scn ON scn.oid = sc.relnamespace
+JOIN pg_catalog.pg_attribute sa ON sa.attrelid = sc.oid AND sa.attnum = e.col`
+
+	q, err := db.Query(sqlstr, schema, pq.Array(resnos), pq.Array(tables), pq.Array(attnums))
+	if err != nil {
+		return nil, errors.Wrap(err, "view column sources query")
+	}
+
+	for q.Next() {
+		var resno int
+		var table, column string
+		if err := q.Scan(&resno, &table, &column); err != nil {
+			return nil, errors.Wrap(err, "view column sources scan")
+		}
+		sources[resno] = table + "." + column
+	}
+	return sources, nil
+}
+
+// viewTargetEntry is a TARGETENTRY node of a view rule.
+type viewTargetEntry struct {
+	resno      int
+	resorigtbl int64
+	resorigcol int
+}
+
+// viewTargetEntries returns the entries of the top-level target list of action, the node tree
+// of a view rule like ({QUERY ... :targetList ({TARGETENTRY :expr {VAR ...} :resno 1 ...}) ...}).
+// Target lists of subqueries in FROM, scalar subqueries and HAVING are nested deeper and left
+// out, and so are junk entries like ORDER BY expressions which are not columns of the view.
+func viewTargetEntries(action string) []viewTargetEntry {
+	const (
+		queryDepth = 2 // ( {QUERY
+		entryDepth = 4 // :targetList ( {TARGETENTRY
+	)
+	tokens := nodeTokens(action)
+	var entries []viewTargetEntry
+	var te viewTargetEntry
+	var junk bool
+	depth := 0
+	inList := false
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "(", "{":
+			depth++
+			continue
+		case ")", "}":
+			if inList && depth == entryDepth && tokens[i] == "}" && !junk {
+				entries = append(entries, te)
+			}
+			depth--
+			if inList && depth < queryDepth+1 {
+				return entries
+			}
+			continue
+		}
+		if depth == queryDepth && tokens[i] == ":targetList" && i+1 < len(tokens) && tokens[i+1] == "(" {
+			inList = true
+			continue
+		}
+		if !inList || depth != entryDepth || i+1 >= len(tokens) {
+			continue
+		}
+		switch tokens[i] {
+		case "TARGETENTRY":
+			te, junk = viewTargetEntry{}, false
+		case ":resno":
+			te.resno, _ = strconv.Atoi(tokens[i+1])
+		case ":resorigtbl":
+			te.resorigtbl, _ = strconv.ParseInt(tokens[i+1], 10, 64)
+		case ":resorigcol":
+			te.resorigcol, _ = strconv.Atoi(tokens[i+1])
+		case ":resjunk":
+			junk = tokens[i+1] == "true"
+		}
+	}
+	return entries
+}
+
+// nodeTokens splits a node tree into words and parens. Parens, braces and spaces in names are
+// escaped by backslashes.
+func nodeTokens(s string) []string {
+	var tokens []string
+	var tok strings.Builder
+	flush := func() {
+		if tok.Len() > 0 {
+			tokens = append(tokens, tok.String())
+			tok.Reset()
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				tok.WriteByte(s[i])
+			}
+		case ' ', '\t', '\n':
+			flush()
+		case '(', ')', '{', '}':
+			flush()
+			tokens = append(tokens, string(c))
+		default:
+			tok.WriteByte(c)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// attributeViewColumns sets Source of view columns from sources keyed by attnum.
+// Computed columns are left unattributed.
+func attributeViewColumns(cols []Column, sources map[int]string) {
+	for i := range cols {
+		cols[i].Source = sources[cols[i].FieldOrdinal]
+	}
+}
+
+// getIndexes returns indexes of table except the primary key index. Indexes on expressions
+// and partial indexes are left out, as they can't be written by column names, and so are
+// INCLUDE columns, which are not part of the key.
+func getIndexes(db *sql.DB, schema string, table string) ([]Index, error) {
+	const sqlstr = `SELECT c2.relname,
+i.indisunique,
+(SELECT string_agg(a.attname, ',' ORDER BY k.ord)
+  FROM unnest(i.indkey) WITH ORDINALITY AS k(attnum, ord)
+  JOIN pg_catalog.pg_attribute a ON a.attrelid = c.oid AND a.attnum = k.attnum
+  WHERE k.ord <= i.indnkeyatts)
+FROM pg_catalog.pg_index i
+JOIN pg_catalog.pg_class c ON c.oid = i.indrelid
+JOIN pg_catalog.pg_class c2 ON c2.oid = i.indexrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = $1
+AND c.relname = $2
+AND i.indisprimary = false
+AND i.indexprs IS NULL
+AND i.indpred IS NULL
+ORDER BY c2.relname`
 
 	q, err := db.Query(sqlstr, schema, table)
 	if err != nil {
@@ -148,25 +664,72 @@ ORDER  BY i.indisprimary DESC,
 	}
 
 	var indexes []Index
-
-	reg := regexp.MustCompile(`.*\(([^\)]+).*`)
-	indexdef := ""
-	// loop: index
 	for q.Next() {
-		err = q.Scan(&indexdef)
-		if err != nil {
+		var idx Index
+		var cols string
+		if err := q.Scan(&idx.Name, &idx.Unique, &cols); err != nil {
 			return nil, errors.Wrap(err, "indexes scan")
 		}
-		var uniqConstraintColumns []Column
-		// loop: column
-		for _, s := range strings.Split(reg.FindStringSubmatch(indexdef)[1], ",") {
-			uniqConstraintColumns = append(uniqConstraintColumns, Column { Name: strings.TrimSpace(s) })
+		for _, name := range strings.Split(cols, ",") {
+			idx.Columns = append(idx.Columns, Column{Name: name})
 		}
-		indexes = append(indexes, Index {Columns: uniqConstraintColumns})
+		indexes = append(indexes, idx)
 	}
 	return indexes, nil
 }
 
+func getTableChecks(db *sql.DB, schema string, table string) ([]Check, error) {
+	const sqlstr = `SELECT ct.conname, pg_catalog.pg_get_constraintdef(ct.oid, true)
+FROM pg_catalog.pg_constraint ct
+JOIN pg_catalog.pg_class c ON c.oid = ct.conrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = $1
+AND c.relname = $2
+AND ct.contype = 'c'
+AND array_length(ct.conkey, 1) > 1
+ORDER BY ct.conname`
+
+	q, err := db.Query(sqlstr, schema, table)
+	if err != nil {
+		return nil, errors.Wrap(err, "checks query")
+	}
+
+	var checks []Check
+	for q.Next() {
+		var name, def string
+		if err := q.Scan(&name, &def); err != nil {
+			return nil, errors.Wrap(err, "checks scan")
+		}
+		checks = append(checks, Check{Name: name, Expression: checkExpression(def)})
+	}
+	return checks, nil
+}
+
+// checkExpression returns the expression of a constraint definition like "CHECK ((a < b))".
+func checkExpression(def string) string {
+	expr := strings.TrimSpace(strings.TrimPrefix(def, "CHECK "))
+	for strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") && enclosedByParen(expr) {
+		expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	}
+	return expr
+}
+
+// enclosedByParen reports whether the first paren of s is closed at the end of s.
+func enclosedByParen(s string) bool {
+	depth := 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i == len(s)-1
+			}
+		}
+	}
+	return false
+}
 
 func getColumns(db *sql.DB, schema, table string, sys bool) ([]Column, error) {
 	// https://github.com/xo/xo/blob/master/models/column.xo.go#L21
@@ -176,17 +739,25 @@ a.attname,
 col_description(c.oid, a.attnum),
 format_type(a.atttypid, a.atttypmod),
 a.attnotnull,
-COALESCE(pg_get_expr(ad.adbin, ad.adrelid), ''),
+pg_get_expr(ad.adbin, ad.adrelid),
 ct.contype,
 pg_catalog.pg_get_constraintdef(ct.oid, true),
-cc.relname,
-pg_get_serial_sequence($2, a.attname)
+CASE WHEN ccn.nspname = n.nspname THEN cc.relname ELSE ccn.nspname || '.' || cc.relname END,
+pg_get_serial_sequence(c.oid::regclass::text, a.attname),
+a.attndims,
+a.attstorage,
+t.typstorage,
+NULLIF(to_jsonb(a)->>'attcompression', ''),
+COALESCE(to_jsonb(a)->>'attgenerated', '') = 's',
+COALESCE(to_jsonb(a)->>'attidentity', '')
 FROM pg_attribute a
 JOIN ONLY pg_class c ON c.oid = a.attrelid
+JOIN pg_type t ON t.oid = a.atttypid
 JOIN ONLY pg_namespace n ON n.oid = c.relnamespace
 LEFT JOIN pg_constraint ct ON ct.conrelid = c.oid AND a.attnum = ANY(ct.conkey)
 LEFT JOIN pg_attrdef ad ON ad.adrelid = c.oid AND ad.adnum = a.attnum
-LEFT JOIN pg_class cc ON cc.oid = ct.conrelid
+LEFT JOIN pg_class cc ON cc.oid = ct.confrelid
+LEFT JOIN pg_namespace ccn ON ccn.oid = cc.relnamespace
 WHERE a.attisdropped = false AND n.nspname = $1 AND c.relname = $2 AND ($3 OR a.attnum > 0)
 ORDER BY a.attnum`
 	q, err := db.Query(sqlstr, schema, table, sys)
@@ -209,6 +780,12 @@ ORDER BY a.attnum`
 			&c.ConstraintSrc,
 			&c.ForignTable,
 			&c.SerialSrc,
+			&c.ArrayDims,
+			&c.Storage,
+			&c.TypeStorage,
+			&c.Compression,
+			&c.Generated,
+			&c.IdentityKind,
 		)
 		if err != nil {
 			return nil, errors.Wrap(err, "columns scan")
@@ -218,6 +795,8 @@ ORDER BY a.attnum`
 			c.PrimaryKey = true
 		//case "u":
 		//	c.Unique = true
+		case "f":
+			c.ForeignKeySrc = c.ConstraintSrc
 		}
 		if c.SerialSrc.Valid {
 			c.Serial = true
@@ -225,6 +804,9 @@ ORDER BY a.attnum`
 		if strings.HasSuffix(c.DataType, "[]") {
 			c.Array = true
 		}
+		if p, s, ok := numericPrecisionScale(c.DataType); ok {
+			c.NumericPrecision, c.NumericScale = p, s
+		}
 
 		o, exists := tmp[c.Name]
 		if !exists {
@@ -232,9 +814,14 @@ ORDER BY a.attnum`
 		} else {
 			o.PrimaryKey = o.PrimaryKey || c.PrimaryKey
 			o.Unique = o.Unique || c.Unique
-			o.ForignTable = c.ForignTable
+			if c.ForignTable.Valid {
+				o.ForignTable = c.ForignTable
+			}
 			o.Serial = c.Serial
 			o.ConstraintSrc = c.ConstraintSrc
+			if c.ForeignKeySrc.Valid {
+				o.ForeignKeySrc = c.ForeignKeySrc
+			}
 		}
 
 		tmp[c.Name] = o
@@ -251,15 +838,112 @@ ORDER BY a.attnum`
 	return ret, nil
 }
 
+func getFunctions(db *sql.DB, schema string) ([]Table, error) {
+	q := `SELECT
+p.oid,
+p.proname,
+obj_description(p.oid, 'pg_proc')
+FROM pg_proc p
+JOIN ONLY pg_namespace n ON n.oid = p.pronamespace
+WHERE n.nspname = $1
+AND p.proretset
+AND p.prokind = 'f'
+ORDER BY p.proname, p.oid
+`
+	rows, err := db.Query(q, schema)
+	if err != nil {
+		return nil, errors.Wrap(err, "functions query")
+	}
+	var oids []int64
+	var fns []Table
+	for rows.Next() {
+		var oid int64
+		t := Table{
+			Schema: schema,
+		}
+		if err := rows.Scan(&oid, &t.Name, &t.Comment); err != nil {
+			return nil, errors.Wrap(err, "functions scan")
+		}
+		oids = append(oids, oid)
+		fns = append(fns, t)
+	}
+
+	var ret []Table
+	for i, t := range fns {
+		cols, err := getFunctionColumns(db, oids[i])
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to get columns of function %s", t.Name))
+		}
+		if len(cols) == 0 {
+			// returns a scalar set
+			continue
+		}
+		t.Columns = cols
+		ret = append(ret, t)
+	}
+	return ret, nil
+}
+
+func getFunctionColumns(db *sql.DB, oid int64) ([]Column, error) {
+	// OUT, INOUT and TABLE parameters
+	const sqlstr = `SELECT
+a.ordinality,
+COALESCE(a.name, ''),
+format_type(a.type, NULL)
+FROM pg_proc p,
+unnest(p.proallargtypes, p.proargmodes, p.proargnames) WITH ORDINALITY AS a(type, mode, name, ordinality)
+WHERE p.oid = $1
+AND a.mode IN ('o', 'b', 't')
+ORDER BY a.ordinality`
+	q, err := db.Query(sqlstr, oid)
+	if err != nil {
+		return nil, errors.Wrap(err, "function columns query")
+	}
+	var cols []Column
+	for q.Next() {
+		var c Column
+		if err := q.Scan(&c.FieldOrdinal, &c.Name, &c.DataType); err != nil {
+			return nil, errors.Wrap(err, "function columns scan")
+		}
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("column%d", c.FieldOrdinal)
+		}
+		c.Array = strings.HasSuffix(c.DataType, "[]")
+		cols = append(cols, c)
+	}
+	if len(cols) > 0 {
+		return cols, nil
+	}
+
+	// RETURNS SETOF a table or composite type
+	const relstr = `SELECT n.nspname, c.relname
+FROM pg_proc p
+JOIN pg_type t ON t.oid = p.prorettype
+JOIN pg_class c ON c.oid = t.typrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE p.oid = $1`
+	var schema, rel string
+	if err := db.QueryRow(relstr, oid).Scan(&schema, &rel); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "function return type query")
+	}
+	return getColumns(db, schema, rel, false)
+}
+
 func getTypes(db *sql.DB) ([]Type, error) {
 	q := `
 SELECT
+n.nspname,
+t.typtype,
 t.typname as type,
 obj_description(t.oid),
 t.typnotnull
 FROM        pg_type t
 LEFT JOIN   pg_catalog.pg_namespace n ON n.oid = t.typnamespace
-WHERE       (t.typrelid = 0 OR (SELECT c.relkind = 'c' FROM pg_catalog.pg_class c WHERE c.oid = t.typrelid))
+WHERE       t.typrelid = 0
+AND     t.typtype <> 'd'
 AND     NOT EXISTS(SELECT 1 FROM pg_catalog.pg_type el WHERE el.oid = t.typelem AND el.typarray = t.oid)
 AND     n.nspname NOT IN ('pg_catalog', 'information_schema')
 `
@@ -271,11 +955,11 @@ AND     n.nspname NOT IN ('pg_catalog', 'information_schema')
 	var typs []Type
 	for rows.Next() {
 		var t Type
-		if err := rows.Scan(&t.Name, &t.Comment, &t.NotNull); err != nil {
+		if err := rows.Scan(&t.Schema, &t.DataType, &t.Name, &t.Comment, &t.NotNull); err != nil {
 			return nil, errors.Wrap(err, "type scan")
 		}
 
-		values, err := getEnum(db, t.Name)
+		values, err := getEnum(db, t.Schema, t.Name)
 		if err != nil {
 			return nil, errors.Wrap(err, "get Enum")
 		}
@@ -285,17 +969,94 @@ AND     n.nspname NOT IN ('pg_catalog', 'information_schema')
 	return typs, nil
 }
 
-func getEnum(db *sql.DB, typName string) ([]string, error) {
+// getComposites returns composite types of schema, whose attributes are read like columns.
+// Row types of tables and views are not composite types here.
+func getComposites(db *sql.DB, schema string) ([]Type, error) {
+	q := `
+SELECT
+t.typtype,
+t.typname,
+obj_description(t.oid)
+FROM pg_type t
+JOIN ONLY pg_namespace n ON n.oid = t.typnamespace
+JOIN pg_class c ON c.oid = t.typrelid
+WHERE n.nspname = $1
+AND t.typtype = 'c'
+AND c.relkind = 'c'
+ORDER BY t.typname
+`
+	rows, err := db.Query(q, schema)
+	if err != nil {
+		return nil, errors.Wrap(err, "composite query")
+	}
+	var typs []Type
+	for rows.Next() {
+		t := Type{
+			Schema: schema,
+		}
+		if err := rows.Scan(&t.DataType, &t.Name, &t.Comment); err != nil {
+			return nil, errors.Wrap(err, "composite scan")
+		}
+		typs = append(typs, t)
+	}
+
+	for i, t := range typs {
+		attrs, err := getColumns(db, schema, t.Name, false)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to get attributes of %s", t.Name))
+		}
+		typs[i].Attributes = attrs
+	}
+	return typs, nil
+}
+
+// getDomains returns domains with their base types. CHECK constraints of a domain are joined with AND.
+func getDomains(db *sql.DB) ([]Type, error) {
+	q := `
+SELECT
+n.nspname,
+t.typtype,
+t.typname,
+format_type(t.typbasetype, t.typtypmod),
+obj_description(t.oid),
+t.typnotnull,
+(SELECT string_agg(pg_get_constraintdef(c.oid, true), ' AND ' ORDER BY c.conname)
+ FROM pg_catalog.pg_constraint c WHERE c.contypid = t.oid AND c.contype = 'c')
+FROM        pg_type t
+LEFT JOIN   pg_catalog.pg_namespace n ON n.oid = t.typnamespace
+WHERE       t.typtype = 'd'
+AND     n.nspname NOT IN ('pg_catalog', 'information_schema')
+ORDER BY t.typname
+`
+	rows, err := db.Query(q)
+	if err != nil {
+		return nil, errors.Wrap(err, "domain query")
+	}
+	var typs []Type
+	for rows.Next() {
+		var t Type
+		if err := rows.Scan(&t.Schema, &t.DataType, &t.Name, &t.BaseType, &t.Comment, &t.NotNull, &t.Check); err != nil {
+			return nil, errors.Wrap(err, "domain scan")
+		}
+		typs = append(typs, t)
+	}
+	return typs, nil
+}
+
+func getEnum(db *sql.DB, schema, typName string) ([]string, error) {
 	q := `
 SELECT pg_enum.enumlabel AS enumlabel
 FROM pg_type
+JOIN pg_namespace
+     ON pg_namespace.oid = pg_type.typnamespace
 JOIN pg_enum
      ON pg_enum.enumtypid = pg_type.oid
 WHERE
-     pg_type.typname = $1
+     pg_namespace.nspname = $1
+AND  pg_type.typname = $2
 ORDER BY pg_enum.enumsortorder
 `
-	rows, err := db.Query(q, typName)
+	rows, err := db.Query(q, schema, typName)
 	if err != nil {
 		return nil, errors.Wrap(err, "enum query")
 	}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckExpression(t *testing.T) {
+	ff := [][]string{
+		[]string{"CHECK (start_date < end_date)", "start_date < end_date"},
+		[]string{"CHECK ((start_date < end_date))", "start_date < end_date"},
+		[]string{"CHECK ((a > 0) AND (b > 0))", "(a > 0) AND (b > 0)"},
+	}
+	for _, d := range ff {
+		if actual := checkExpression(d[0]); actual != d[1] {
+			t.Errorf("%s: expected %s, actual: %s", d[0], d[1], actual)
+		}
+	}
+}
+
+func TestLoadInspectResultChecks(t *testing.T) {
+	for _, snapshot := range []string{
+		// expressions without names
+		`{"Tables": [{"Name": "t", "TableChecks": ["a > 0"]}]}`,
+		// expressions with named checks
+		`{"Tables": [{"Name": "t", "TableChecks": ["a > 0"], "Checks": [{"Name": "t_a_check", "Expression": "a > 0"}]}]}`,
+		`{"Tables": [{"Name": "t", "TableChecks": [{"Name": "t_a_check", "Expression": "a > 0"}]}]}`,
+	} {
+		ins, err := LoadInspectResult(strings.NewReader(snapshot))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := ins.Tables[0].TableChecks; len(actual) != 1 || actual[0].Expression != "a > 0" {
+			t.Errorf("%s: unexpected checks: %v", snapshot, actual)
+		} else if strings.Contains(snapshot, "t_a_check") && actual[0].Name != "t_a_check" {
+			t.Errorf("%s: name is lost: %v", snapshot, actual)
+		}
+	}
+}
+
+func TestInSchema(t *testing.T) {
+	ins := InspectResult{
+		Tables: []Table{
+			Table{Schema: "billing", Name: "accounts"},
+			Table{Schema: "catalog", Name: "accounts"},
+		},
+		Views: []Table{Table{Schema: "catalog", Name: "titles"}},
+		Types: []Type{
+			Type{Schema: "billing", Name: "invoice_status"},
+			Type{Schema: "catalog", Name: "item_status"},
+			Type{Schema: "public", Name: "citext"},
+			Type{Name: "legacy"},
+		},
+	}
+	actual := ins.InSchema("billing", []string{"billing", "catalog"})
+	if len(actual.Tables) != 1 || actual.Tables[0].Schema != "billing" {
+		t.Errorf("tables: %v", actual.Tables)
+	}
+	if len(actual.Views) != 0 {
+		t.Errorf("views: %v", actual.Views)
+	}
+	var types []string
+	for _, typ := range actual.Types {
+		types = append(types, typ.Name)
+	}
+	if strings.Join(types, ",") != "invoice_status,citext,legacy" {
+		t.Errorf("types of billing and shared types are expected: %v", types)
+	}
+}
+
+func TestAttributeViewColumns(t *testing.T) {
+	cols := []Column{
+		Column{FieldOrdinal: 1, Name: "id"},
+		Column{FieldOrdinal: 2, Name: "total"},
+		Column{FieldOrdinal: 3, Name: "order_total"},
+	}
+	// total is o.total * 2 AS total, which has no source
+	attributeViewColumns(cols, map[int]string{1: "orders.id", 3: "orders.total"})
+	expected := []string{"orders.id", "", "orders.total"}
+	for i, col := range cols {
+		if col.Source != expected[i] {
+			t.Errorf("%s: expected %q, actual: %q", col.Name, expected[i], col.Source)
+		}
+	}
+}
+
+func TestViewTargetEntries(t *testing.T) {
+	// SELECT o.id, o.total, (SELECT u.name FROM users u WHERE u.id = o.user_id) AS "user (name)"
+	// FROM (SELECT * FROM orders) o ORDER BY o.user_id
+	action := `({QUERY :commandType 1 :querySource 0 :canSetTag true :utilityStmt <> ` +
+		`:rtable ({RTE :alias {ALIAS :aliasname o :colnames <>} :eref {ALIAS :aliasname o :colnames ("id" "user_id" "total")} ` +
+		`:rtekind 1 :subquery {QUERY :commandType 1 :targetList (` +
+		`{TARGETENTRY :expr {VAR :varno 1 :varattno 1} :resno 1 :resname id :ressortgroupref 0 :resorigtbl 16384 :resorigcol 1 :resjunk false} ` +
+		`{TARGETENTRY :expr {VAR :varno 1 :varattno 2} :resno 2 :resname user_id :ressortgroupref 0 :resorigtbl 16384 :resorigcol 2 :resjunk false} ` +
+		`{TARGETENTRY :expr {VAR :varno 1 :varattno 3} :resno 3 :resname total :ressortgroupref 0 :resorigtbl 16384 :resorigcol 3 :resjunk false}` +
+		`)}}) ` +
+		`:jointree {FROMEXPR :fromlist ({RANGETBLREF :rtindex 1}) :quals <>} :targetList (` +
+		`{TARGETENTRY :expr {VAR :varno 1 :varattno 1} :resno 1 :resname id :ressortgroupref 0 :resorigtbl 16384 :resorigcol 1 :resjunk false} ` +
+		`{TARGETENTRY :expr {VAR :varno 1 :varattno 3} :resno 2 :resname total :ressortgroupref 0 :resorigtbl 16384 :resorigcol 3 :resjunk false} ` +
+		`{TARGETENTRY :expr {SUBLINK :subLinkType 4 :subselect {QUERY :commandType 1 :targetList (` +
+		`{TARGETENTRY :expr {VAR :varno 1 :varattno 2} :resno 1 :resname name :ressortgroupref 0 :resorigtbl 16390 :resorigcol 2 :resjunk false}` +
+		`)}} :resno 3 :resname user\ \(name\) :ressortgroupref 0 :resorigtbl 0 :resorigcol 0 :resjunk false} ` +
+		`{TARGETENTRY :expr {VAR :varno 1 :varattno 2} :resno 4 :resname <> :ressortgroupref 1 :resorigtbl 16384 :resorigcol 2 :resjunk true}` +
+		`) :havingQual <> :sortClause ({SORTGROUPCLAUSE :tleSortGroupRef 1})})`
+	expected := []viewTargetEntry{
+		viewTargetEntry{resno: 1, resorigtbl: 16384, resorigcol: 1},
+		viewTargetEntry{resno: 2, resorigtbl: 16384, resorigcol: 3},
+		viewTargetEntry{resno: 3},
+	}
+	actual := viewTargetEntries(action)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, actual: %v", expected, actual)
+	}
+}
+
+func hashFixture() InspectResult {
+	return InspectResult{
+		Tables: []Table{
+			Table{
+				Name: "users",
+				Columns: []Column{
+					Column{FieldOrdinal: 1, Name: "id", DataType: "integer", PrimaryKey: true},
+					Column{FieldOrdinal: 2, Name: "name", DataType: "text", Comment: sql.NullString{String: "name", Valid: true}},
+				},
+			},
+			Table{
+				Name: "orders",
+				Columns: []Column{
+					Column{FieldOrdinal: 1, Name: "id", DataType: "integer", PrimaryKey: true},
+				},
+				TableChecks: []Check{},
+			},
+		},
+		Types: []Type{
+			Type{Name: "status", Values: []string{"open", "closed"}},
+			Type{Name: "color", Values: []string{"red"}},
+		},
+	}
+}
+
+func TestHashStable(t *testing.T) {
+	ins := hashFixture()
+	h := ins.Hash()
+	if h != hashFixture().Hash() {
+		t.Error("hash should be deterministic")
+	}
+
+	reordered := hashFixture()
+	reordered.Tables[0], reordered.Tables[1] = reordered.Tables[1], reordered.Tables[0]
+	reordered.Types[0], reordered.Types[1] = reordered.Types[1], reordered.Types[0]
+	cols := reordered.Tables[1].Columns
+	cols[0], cols[1] = cols[1], cols[0]
+	reordered.Tables[0].TableChecks = nil
+	if actual := reordered.Hash(); actual != h {
+		t.Errorf("hash should not depend on order: %s, %s", h, actual)
+	}
+	if ins.Tables[0].Name != "users" || ins.Tables[0].Columns[0].Name != "id" {
+		t.Error("Hash should not modify the inspect result")
+	}
+
+	// same-named types in two schemas
+	schemas := hashFixture()
+	schemas.Types = []Type{
+		Type{Schema: "billing", Name: "status", Values: []string{"open", "closed"}},
+		Type{Schema: "catalog", Name: "status", Values: []string{"draft", "published"}},
+	}
+	swapped := hashFixture()
+	swapped.Types = []Type{schemas.Types[1], schemas.Types[0]}
+	if a, b := schemas.Hash(), swapped.Hash(); a != b {
+		t.Errorf("hash should not depend on order of types in schemas: %s, %s", a, b)
+	}
+}
+
+func TestHashChanges(t *testing.T) {
+	h := hashFixture().Hash()
+	changes := []func(ins *InspectResult){
+		func(ins *InspectResult) { ins.Tables[0].Columns[1].NotNull = true },
+		func(ins *InspectResult) { ins.Tables[0].Columns[1].Unique = true },
+		func(ins *InspectResult) { ins.Tables[0].Columns[1].Comment.Valid = false },
+		func(ins *InspectResult) { ins.Tables[0].Columns[1].DataType = "character varying(10)" },
+		func(ins *InspectResult) { ins.Types[0].Values = []string{"closed", "open"} },
+		func(ins *InspectResult) { ins.Composites = []Type{Type{Name: "address"}} },
+		func(ins *InspectResult) { ins.Domains = []Type{Type{Name: "email", BaseType: "text"}} },
+		func(ins *InspectResult) {
+			ins.Tables[0].Indexs = []Index{Index{Name: "users_name_idx", Columns: []Column{Column{Name: "name"}}}}
+		},
+	}
+	for i, change := range changes {
+		ins := hashFixture()
+		change(&ins)
+		if ins.Hash() == h {
+			t.Errorf("%d: hash should change", i)
+		}
+	}
+}
+
+func TestLoadInspectResultUnnamedIndexes(t *testing.T) {
+	// snapshots taken before indexes were named only have unique indexes
+	snapshot := `{"Tables": [{"Name": "users", "Indexs": [{"Columns": [{"Name": "email"}]}, {"Name": "users_name_idx", "Columns": [{"Name": "name"}]}]}]}`
+	ins, err := LoadInspectResult(strings.NewReader(snapshot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx := ins.Tables[0].Indexs; !idx[0].Unique || idx[1].Unique {
+		t.Errorf("unexpected indexes: %+v", idx)
+	}
+}
@@ -3,18 +3,31 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
 func main() {
 	var confFile string
 	var target string
+	var stamp bool
+	var schema string
+	var dump string
+	var dryRun bool
+	var check bool
 	flag.StringVar(&confFile, "c", "", "config file path")
 	flag.StringVar(&target, "t", "", "target build")
+	flag.BoolVar(&stamp, "version-stamp", false, "record pg2any version and schema hash in generated files")
+	flag.StringVar(&schema, "schema", "", "generate from a schema snapshot instead of the database")
+	flag.StringVar(&dump, "dump-schema", "", "write the inspected schema snapshot to the file")
+	flag.BoolVar(&dryRun, "dry-run", false, "log the files to generate without writing them")
+	flag.BoolVar(&check, "check", false, "fail if files on the disk differ from the generated ones, without writing them")
 	flag.Parse()
 	if confFile == "" {
 		path, err := os.Executable()
@@ -34,21 +47,107 @@ func main() {
 		log.Fatal(fmt.Errorf("config file error: %s", err))
 	}
 
-	ins, err := Inspect(config.db)
-	if err != nil {
+	if err := generate(config, target, schema, dump, stamp, dryRun, check); err != nil {
 		log.Fatal(err)
 	}
+}
+
+// generate inspects the database once, or loads the schema snapshot if schema is given, and
+// passes the same result to the generators of target. Generators share the result, so they
+// must not modify it. With schemas in config, generators build each schema into its
+// subdirectory of their outputs. The result is written to dump if given. With dryRun, the
+// files are only logged, the templates are still executed to return the same errors. With
+// check, the files are compared with the disk instead of written, and an error lists the
+// files which differ.
+func generate(config *Config, target, schema, dump string, stamp, dryRun, check bool) error {
+	if dryRun && check {
+		return fmt.Errorf("-dry-run and -check can't be used together")
+	}
+	ins, err := loadSchema(config, schema)
+	if err != nil {
+		return err
+	}
+
+	var opts BuildOptions
+	if stamp {
+		opts.Stamp = versionStamp(ins)
+	}
+	if dryRun {
+		opts.Files = dryRunFiles{root: config.root}
+	}
+	var checked *checkFiles
+	if check {
+		checked = &checkFiles{root: config.root}
+		opts.Files = checked
+	}
+
+	if len(config.Schemas) > 0 {
+		if err := checkSchemas(ins); err != nil {
+			return err
+		}
+	}
+
+	if dump != "" {
+		if err := dumpSchema(opts, dump, ins); err != nil {
+			return errors.Wrap(err, "dump schema")
+		}
+	}
 
 	for _, gen := range config.generators {
 		if target != "" && target != gen.GetType() {
 			continue
 		}
 		log.Printf("Generate: %s", gen.GetType())
-		if err := gen.Build(ins); err != nil {
-			log.Fatal(err)
+		if len(config.Schemas) == 0 {
+			if err := gen.Build(ins, opts); err != nil {
+				return err
+			}
+		}
+		for _, schema := range config.Schemas {
+			log.Printf("schema: %s", schema)
+			sopts := opts
+			sopts.Schema = schema
+			if err := gen.Build(ins.InSchema(schema, config.Schemas), sopts); err != nil {
+				return errors.Wrap(err, schema)
+			}
 		}
 		log.Printf("done")
 	}
+	if checked != nil && len(checked.drifted) > 0 {
+		return fmt.Errorf("generated files drifted: %s", strings.Join(checked.drifted, ", "))
+	}
+	return nil
+}
+
+// checkSchemas returns an error if ins has tables without schemas, like snapshots taken before
+// schemas were recorded, which no schema would generate.
+func checkSchemas(ins InspectResult) error {
+	for _, tables := range [][]Table{ins.Tables, ins.Functions, ins.Views} {
+		for _, t := range tables {
+			if t.Schema == "" {
+				return fmt.Errorf("%s has no schema, take a new schema snapshot to generate schemas", t.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func loadSchema(config *Config, schema string) (InspectResult, error) {
+	if schema == "" {
+		return Inspect(config.db, config.Schemas)
+	}
+	file, err := os.Open(schema)
+	if err != nil {
+		return InspectResult{}, errors.Wrap(err, "open schema")
+	}
+	defer file.Close()
+	return LoadInspectResult(file)
+}
+
+func dumpSchema(opts BuildOptions, dump string, ins InspectResult) error {
+	return opts.writeFile(dump, func(wr io.Writer) error {
+		return DumpInspectResult(wr, ins)
+	})
 }
 
 func searchConfigFile(dir string) (string, error) {
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// countingDriver is a database driver returning no rows, counting queries.
+type countingDriver struct {
+	queries int64
+}
+
+func (d *countingDriver) Open(name string) (driver.Conn, error) {
+	return &countingConn{d}, nil
+}
+
+type countingConn struct {
+	d *countingDriver
+}
+
+func (c *countingConn) Prepare(query string) (driver.Stmt, error) {
+	return &countingStmt{c.d}, nil
+}
+
+func (c *countingConn) Close() error {
+	return nil
+}
+
+func (c *countingConn) Begin() (driver.Tx, error) {
+	return nil, driver.ErrSkip
+}
+
+type countingStmt struct {
+	d *countingDriver
+}
+
+func (s *countingStmt) Close() error {
+	return nil
+}
+
+func (s *countingStmt) NumInput() int {
+	return -1
+}
+
+func (s *countingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (s *countingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	atomic.AddInt64(&s.d.queries, 1)
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string {
+	return nil
+}
+
+func (emptyRows) Close() error {
+	return nil
+}
+
+func (emptyRows) Next(dest []driver.Value) error {
+	return io.EOF
+}
+
+var testDriver = &countingDriver{}
+
+func init() {
+	sql.Register("pg2any-counting", testDriver)
+}
+
+type recordingGenerator struct {
+	typ    string
+	builds []InspectResult
+	stamps []string
+}
+
+func (gen *recordingGenerator) GetType() string {
+	return gen.typ
+}
+
+func (gen *recordingGenerator) Build(ins InspectResult, opts BuildOptions) error {
+	gen.builds = append(gen.builds, ins)
+	gen.stamps = append(gen.stamps, opts.Stamp)
+	return nil
+}
+
+func TestGenerateInspectsOnce(t *testing.T) {
+	db, err := sql.Open("pg2any-counting", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	start := atomic.LoadInt64(&testDriver.queries)
+	if _, err := Inspect(db, nil); err != nil {
+		t.Fatal(err)
+	}
+	perInspection := atomic.LoadInt64(&testDriver.queries) - start
+	if perInspection == 0 {
+		t.Fatal("inspection should query the database")
+	}
+
+	first := &recordingGenerator{typ: "first"}
+	second := &recordingGenerator{typ: "second"}
+	config := &Config{
+		db:         db,
+		generators: []Generator{first, second},
+	}
+	start = atomic.LoadInt64(&testDriver.queries)
+	if err := generate(config, "", "", "", false, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if actual := atomic.LoadInt64(&testDriver.queries) - start; actual != perInspection {
+		t.Errorf("expected %d queries of a single inspection, actual: %d", perInspection, actual)
+	}
+	if len(first.builds) != 1 || len(second.builds) != 1 {
+		t.Fatalf("each generator should be built once: %d, %d", len(first.builds), len(second.builds))
+	}
+	if first.builds[0].Hash() != second.builds[0].Hash() {
+		t.Error("generators should get the same inspect result")
+	}
+}
+
+func TestGenerateDryRun(t *testing.T) {
+	root := t.TempDir()
+	output := filepath.Join(root, "proto")
+	if err := os.Mkdir(output, 0755); err != nil {
+		t.Fatal(err)
+	}
+	templates, err := filepath.Abs("templates/protobuf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{
+		generators: []Generator{
+			&ProtoBuf{config: ProtoBufConfig{Output: "proto", Templates: templates, PackageName: "example"}, root: root},
+		},
+		root: root,
+	}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	dump := filepath.Join(root, "schema.json")
+	if err := generate(config, "", filepath.Join("testdata", "golden", "schema.json"), dump, false, true, false); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ioutil.ReadDir(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("dry-run should not write files: %d", len(files))
+	}
+	if _, err := os.Stat(dump); !os.IsNotExist(err) {
+		t.Error("dry-run should not dump the schema")
+	}
+	for _, expected := range []string{"dry-run: proto/UsersMessage.proto (", "dry-run: proto/enum.proto (", "dry-run: schema.json ("} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected %q in log:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestGenerateCheck(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "proto"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	templates, err := filepath.Abs("templates/protobuf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{
+		generators: []Generator{
+			&ProtoBuf{config: ProtoBufConfig{Output: "proto", Templates: templates, PackageName: "example"}, root: root},
+		},
+		root: root,
+	}
+	snapshot := filepath.Join("testdata", "golden", "schema.json")
+	if err := generate(config, "", snapshot, "", false, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(config, "", snapshot, "", false, false, true); err != nil {
+		t.Errorf("generated files should be in sync: %v", err)
+	}
+
+	file := filepath.Join(root, "proto", "enum.proto")
+	if err := ioutil.WriteFile(file, []byte("// edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = generate(config, "", snapshot, "", false, false, true)
+	if err == nil || !strings.Contains(err.Error(), "proto/enum.proto") || strings.Contains(err.Error(), "UsersMessage.proto") {
+		t.Errorf("expected an error listing only the drifted file: %v", err)
+	}
+	if b, _ := ioutil.ReadFile(file); string(b) != "// edited\n" {
+		t.Error("check should not write files")
+	}
+}
+
+func TestGenerateSchemas(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"proto", "java"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	protoTemplates, err := filepath.Abs("templates/protobuf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	javaTemplates, err := filepath.Abs("templates/hibernate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ins := InspectResult{
+		Tables: []Table{
+			Table{Schema: "billing", Name: "accounts", Columns: []Column{
+				Column{Name: "balance", DataType: "bigint"},
+				Column{Name: "status", DataType: "account_status"},
+			}},
+			Table{Schema: "catalog", Name: "accounts", Columns: []Column{Column{Name: "title", DataType: "text"}}},
+		},
+		Types: []Type{Type{Schema: "billing", DataType: "e", Name: "account_status", Values: []string{"open"}}},
+	}
+	snapshot := filepath.Join(root, "schema.json")
+	if err := writeFile(snapshot, func(wr io.Writer) error { return DumpInspectResult(wr, ins) }); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{
+		Schemas: []string{"billing", "catalog"},
+		generators: []Generator{
+			&ProtoBuf{config: ProtoBufConfig{Output: "proto", Templates: protoTemplates, PackageName: "example"}, root: root},
+			&Hibernate{config: HibernateConfig{Output: "java", Templates: javaTemplates, PackageName: "com.example"}, root: root},
+		},
+		root: root,
+	}
+	if err := generate(config, "", snapshot, "", false, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	ff := []struct {
+		file     string
+		expected []string
+	}{
+		{"proto/billing/AccountsMessage.proto", []string{"package example.billing;", `import "billing/enum.proto";`, "int64 balance = 1;"}},
+		{"proto/catalog/AccountsMessage.proto", []string{"package example.catalog;", `import "catalog/enum.proto";`, "string title = 1;"}},
+		{"proto/billing/enum.proto", []string{"package example.billing;", "enum AccountStatus {"}},
+		{"java/billing/Accounts.java", []string{"package com.example.billing;", `,schema="billing"`, "getBalance()"}},
+		{"java/catalog/Accounts.java", []string{"package com.example.catalog;", `,schema="catalog"`, "getTitle()"}},
+	}
+	for _, f := range ff {
+		b, err := ioutil.ReadFile(filepath.Join(root, f.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range f.expected {
+			if !strings.Contains(string(b), expected) {
+				t.Errorf("%s: expected %q:\n%s", f.file, expected, b)
+			}
+		}
+	}
+	for _, file := range []string{"proto/AccountsMessage.proto", "java/Accounts.java"} {
+		if _, err := os.Stat(filepath.Join(root, file)); !os.IsNotExist(err) {
+			t.Errorf("tables of schemas should not be written to the output: %s", file)
+		}
+	}
+}
+
+func TestGenerateSchemasStamp(t *testing.T) {
+	types := []Type{
+		Type{Schema: "billing", DataType: "e", Name: "status", Values: []string{"open", "closed"}},
+		Type{Schema: "catalog", DataType: "e", Name: "status", Values: []string{"draft", "published"}},
+	}
+	var stamps []string
+	// schemas may be inspected in any order
+	for _, order := range [][]Type{[]Type{types[0], types[1]}, []Type{types[1], types[0]}} {
+		root := t.TempDir()
+		snapshot := filepath.Join(root, "schema.json")
+		ins := InspectResult{
+			Tables: []Table{
+				Table{Schema: "billing", Name: "accounts", Columns: []Column{Column{Name: "status", DataType: "status"}}},
+				Table{Schema: "catalog", Name: "accounts", Columns: []Column{Column{Name: "status", DataType: "status"}}},
+			},
+			Types: order,
+		}
+		if err := writeFile(snapshot, func(wr io.Writer) error { return DumpInspectResult(wr, ins) }); err != nil {
+			t.Fatal(err)
+		}
+		gen := &recordingGenerator{typ: "first"}
+		config := &Config{Schemas: []string{"billing", "catalog"}, generators: []Generator{gen}, root: root}
+		if err := generate(config, "", snapshot, "", true, false, false); err != nil {
+			t.Fatal(err)
+		}
+		stamps = append(stamps, gen.stamps...)
+	}
+	if len(stamps) != 4 {
+		t.Fatalf("expected a build per schema: %v", stamps)
+	}
+	for _, stamp := range stamps[1:] {
+		if stamp != stamps[0] {
+			t.Errorf("stamp should not depend on order of types: %v", stamps)
+			break
+		}
+	}
+}
+
+func TestGenerateSchemasWithoutTableSchema(t *testing.T) {
+	root := t.TempDir()
+	snapshot := filepath.Join(root, "schema.json")
+	ins := InspectResult{Tables: []Table{Table{Name: "accounts"}}}
+	if err := writeFile(snapshot, func(wr io.Writer) error { return DumpInspectResult(wr, ins) }); err != nil {
+		t.Fatal(err)
+	}
+	gen := &recordingGenerator{typ: "first"}
+	config := &Config{Schemas: []string{"public"}, generators: []Generator{gen}, root: root}
+	if err := generate(config, "", snapshot, "", false, false, false); err == nil || !strings.Contains(err.Error(), "accounts has no schema") {
+		t.Errorf("expected an error of the table without schema: %v", err)
+	}
+	if len(gen.builds) != 0 {
+		t.Error("nothing should be generated")
+	}
+}